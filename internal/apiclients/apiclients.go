@@ -0,0 +1,105 @@
+// Package apiclients stores the named API clients that call /v1 with their
+// own key, and the scopes that limit which routes each may use. Only a hash
+// of each key is stored; the key itself is shown once, when it is issued.
+package apiclients
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a client does not exist or has been revoked.
+	ErrNotFound = errors.New("apiclients: client not found")
+	// ErrNameTaken is returned when creating a client with the name of an
+	// unrevoked one. Names are unique among unrevoked clients, as client
+	// certificates name their client.
+	ErrNameTaken = errors.New("apiclients: client name already in use")
+)
+
+// Scopes grant access to groups of /v1 routes.
+const (
+	ScopeSessionCreate     = "session:create"
+	ScopeCardsCreate       = "cards:create"
+	ScopeCardsRead         = "cards:read"
+	ScopeCardsDelete       = "cards:delete"
+	ScopeChargeCreate      = "charge:create"
+	ScopeRefundCreate      = "refund:create"
+	ScopeTransactionsRead  = "transactions:read"
+	ScopeUPGRead           = "upg:read"
+	ScopeCredentialsManage = "credentials:manage"
+	ScopeReservationsRead  = "reservations:read"
+	ScopeReservationsWrite = "reservations:write"
+	ScopeAdmin             = "admin"
+)
+
+// Scopes lists every scope a client can be granted.
+var Scopes = []string{
+	ScopeSessionCreate, ScopeCardsCreate, ScopeCardsRead, ScopeCardsDelete, ScopeChargeCreate,
+	ScopeRefundCreate, ScopeTransactionsRead, ScopeUPGRead, ScopeCredentialsManage,
+	ScopeReservationsRead, ScopeReservationsWrite, ScopeAdmin,
+}
+
+// ValidScope reports whether scope is one of Scopes.
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// Client is a named API caller.
+type Client struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// KeyPrefix is the start of the client's key, to tell keys apart.
+	KeyPrefix string     `json:"key_prefix"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Store persists API clients by the hash of their key.
+type Store interface {
+	// Create stores c, or returns ErrNameTaken if an unrevoked client has its
+	// name.
+	Create(ctx context.Context, c *Client, keyHash string) error
+	// FindByKeyHash returns the unrevoked client with the key hash.
+	FindByKeyHash(ctx context.Context, keyHash string) (*Client, error)
+	// FindByName returns the unrevoked client named name.
+	FindByName(ctx context.Context, name string) (*Client, error)
+	// List returns every client, revoked ones included, newest first.
+	List(ctx context.Context) ([]Client, error)
+	// Revoke stops the client's key from being accepted.
+	Revoke(ctx context.Context, id string) error
+}
+
+// keyPrefix starts every issued key, so that keys are recognizable in
+// configuration and secret scanners.
+const keyPrefix = "pk_"
+
+// NewKey returns a new random API key and its hash.
+func NewKey() (key, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("apiclients: generate key: %w", err)
+	}
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashKey(key), nil
+}
+
+// HashKey returns the stored hash of key. Keys are random and long, so an
+// unsalted SHA-256 is enough to keep them from being recovered.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Prefix returns the part of key shown as Client.KeyPrefix.
+func Prefix(key string) string {
+	return key[:min(len(key), len(keyPrefix)+6)]
+}
@@ -0,0 +1,90 @@
+package apiclients
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store held in process memory, for tests and development
+// without a database.
+type MemoryStore struct {
+	mu      sync.Mutex
+	clients map[string]Client
+	hashes  map[string]string // key hash to client ID
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{clients: map[string]Client{}, hashes: map[string]string{}}
+}
+
+func (m *MemoryStore) Create(_ context.Context, c *Client, keyHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.clients {
+		if other.Name == c.Name && other.RevokedAt == nil {
+			return ErrNameTaken
+		}
+	}
+	c.CreatedAt = time.Now()
+	stored := *c
+	stored.Scopes = slices.Clone(c.Scopes)
+	m.clients[c.ID] = stored
+	m.hashes[keyHash] = c.ID
+	return nil
+}
+
+func (m *MemoryStore) FindByKeyHash(_ context.Context, keyHash string) (*Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.clients[m.hashes[keyHash]]
+	if !ok || c.RevokedAt != nil {
+		return nil, ErrNotFound
+	}
+	return &c, nil
+}
+
+func (m *MemoryStore) FindByName(_ context.Context, name string) (*Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.clients {
+		if c.Name == name && c.RevokedAt == nil {
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryStore) List(_ context.Context) ([]Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clients := []Client{}
+	for _, c := range m.clients {
+		clients = append(clients, c)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		if !clients[i].CreatedAt.Equal(clients[j].CreatedAt) {
+			return clients[i].CreatedAt.After(clients[j].CreatedAt)
+		}
+		return clients[i].ID < clients[j].ID
+	})
+	return clients, nil
+}
+
+func (m *MemoryStore) Revoke(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.clients[id]
+	if !ok || c.RevokedAt != nil {
+		return ErrNotFound
+	}
+	now := time.Now()
+	c.RevokedAt = &now
+	m.clients[id] = c
+	return nil
+}
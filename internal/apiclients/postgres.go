@@ -0,0 +1,109 @@
+package apiclients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore is a Store backed by the api_clients table (see
+// migrations/0012_api_clients.sql and 0014_api_client_unique_names.sql).
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+// NewPostgresStore creates a PostgresStore backed by the given pool.
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+const clientColumns = `id, name, scopes, key_prefix, created_at, revoked_at`
+
+func (p *PostgresStore) Create(ctx context.Context, c *Client, keyHash string) error {
+	err := p.pool.QueryRow(ctx,
+		`INSERT INTO api_clients (id, name, key_prefix, key_hash, scopes)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (name) WHERE revoked_at IS NULL DO NOTHING
+		 RETURNING created_at`,
+		c.ID, c.Name, c.KeyPrefix, keyHash, c.Scopes,
+	).Scan(&c.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNameTaken
+	}
+	if err != nil {
+		return fmt.Errorf("apiclients: insert client: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) FindByKeyHash(ctx context.Context, keyHash string) (*Client, error) {
+	row := p.pool.QueryRow(ctx,
+		`SELECT `+clientColumns+` FROM api_clients WHERE key_hash = $1 AND revoked_at IS NULL`, keyHash)
+	c, err := scanClient(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("apiclients: find client: %w", err)
+	}
+	return c, nil
+}
+
+func (p *PostgresStore) FindByName(ctx context.Context, name string) (*Client, error) {
+	row := p.pool.QueryRow(ctx,
+		`SELECT `+clientColumns+` FROM api_clients WHERE name = $1 AND revoked_at IS NULL`, name)
+	c, err := scanClient(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("apiclients: find client: %w", err)
+	}
+	return c, nil
+}
+
+func (p *PostgresStore) List(ctx context.Context) ([]Client, error) {
+	rows, err := p.pool.Query(ctx, `SELECT `+clientColumns+` FROM api_clients ORDER BY created_at DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("apiclients: list clients: %w", err)
+	}
+	defer rows.Close()
+
+	clients := []Client{}
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("apiclients: scan client: %w", err)
+		}
+		clients = append(clients, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("apiclients: list clients: %w", err)
+	}
+	return clients, nil
+}
+
+func (p *PostgresStore) Revoke(ctx context.Context, id string) error {
+	tag, err := p.pool.Exec(ctx,
+		`UPDATE api_clients SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("apiclients: revoke client: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanClient(row pgx.Row) (*Client, error) {
+	var c Client
+	if err := row.Scan(&c.ID, &c.Name, &c.Scopes, &c.KeyPrefix, &c.CreatedAt, &c.RevokedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
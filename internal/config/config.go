@@ -1,6 +1,10 @@
 package config
 
-import "github.com/kelseyhightower/envconfig"
+import (
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+)
 
 // AppConfig holds general application settings.
 type AppConfig struct {
@@ -48,28 +52,132 @@ type PCIBookingConfig struct {
 	BaseURL string `envconfig:"BASE_URL" default:"https://service.pcibooking.net"`
 }
 
+// SandboxConfig holds the settings for the in-process sandbox processor.
+type SandboxConfig struct {
+	// Enabled registers the sandbox processor for properties routed to it. It
+	// is also registered when PROCESSOR_NAME or PROCESSOR_SECONDARY selects
+	// it, and never when APP_ENV=production.
+	Enabled bool `envconfig:"ENABLED" default:"false"`
+	// Store selects where sandbox card tokens are kept: "memory" or "redis".
+	Store    string        `envconfig:"STORE" default:"memory"`
+	TokenTTL time.Duration `envconfig:"TOKEN_TTL" default:"24h"`
+}
+
 // ProcessorConfig holds the active processor selection.
 type ProcessorConfig struct {
-	Name string `envconfig:"NAME" default:"vaultera"` // "vaultera", "pcibooking", or "pci_booking_upg"
+	Name string `envconfig:"NAME" default:"vaultera"` // "vaultera", "pcibooking", "pci_booking_upg", or "sandbox"
+	// Secondary optionally names a processor to fail over to for relay charges
+	// and session tokens when the primary is unavailable.
+	Secondary string `envconfig:"SECONDARY"`
+}
+
+// ResilienceConfig holds the timeout, retry and circuit breaker settings applied
+// to every processor client.
+type ResilienceConfig struct {
+	// Timeout bounds read and management calls; ChargeTimeout bounds SendCard
+	// and ChargeUPG.
+	Timeout       time.Duration `envconfig:"TIMEOUT" default:"15s"`
+	ChargeTimeout time.Duration `envconfig:"CHARGE_TIMEOUT" default:"30s"`
+	// RetryMax is the number of retries for idempotent calls (GetCard,
+	// GetPaymentGateways, GetCredentialsStructure). Other calls are never retried.
+	RetryMax       int           `envconfig:"RETRY_MAX" default:"2"`
+	RetryBaseDelay time.Duration `envconfig:"RETRY_BASE_DELAY" default:"200ms"`
+	RetryMaxDelay  time.Duration `envconfig:"RETRY_MAX_DELAY" default:"2s"`
+	// BreakerThreshold consecutive upstream failures open the circuit for
+	// BreakerCooldown, after which a single trial call is let through.
+	BreakerThreshold int           `envconfig:"BREAKER_THRESHOLD" default:"5"`
+	BreakerCooldown  time.Duration `envconfig:"BREAKER_COOLDOWN" default:"30s"`
+}
+
+// IdempotencyConfig holds the Idempotency-Key settings for charges.
+type IdempotencyConfig struct {
+	// TTL is how long a completed response is replayed for a repeated key.
+	TTL time.Duration `envconfig:"TTL" default:"24h"`
+	// LockTTL bounds how long a key stays locked by an in-flight request, so
+	// a crashed request does not block its key forever. It should exceed the
+	// processor charge timeout.
+	LockTTL time.Duration `envconfig:"LOCK_TTL" default:"2m"`
+}
+
+// RateLimitConfig holds the request limits of the tokenize and charge routes.
+// Each limit is a number of requests per Window from one caller, for one
+// property or with one card token; 0 disables it.
+type RateLimitConfig struct {
+	Window              time.Duration `envconfig:"WINDOW" default:"1m"`
+	TokenizePerCaller   int           `envconfig:"TOKENIZE_PER_CALLER" default:"120"`
+	TokenizePerProperty int           `envconfig:"TOKENIZE_PER_PROPERTY" default:"60"`
+	ChargePerCaller     int           `envconfig:"CHARGE_PER_CALLER" default:"120"`
+	ChargePerProperty   int           `envconfig:"CHARGE_PER_PROPERTY" default:"60"`
+	ChargePerCard       int           `envconfig:"CHARGE_PER_CARD" default:"5"`
 }
 
 // AuthConfig holds the server-to-server shared secret auth settings.
 type AuthConfig struct {
+	// Mode selects how /v1 callers authenticate: shared_secret (the header),
+	// hmac (signed requests), api_key (per-client keys with scopes), mtls
+	// (client certificates) or either.
+	Mode         string `envconfig:"MODE" default:"shared_secret"`
 	SharedSecret string `envconfig:"SHARED_SECRET"`
-	HeaderName   string `envconfig:"HEADER_NAME" default:"X-Payment-Service-Auth"`
-	Require      bool   `envconfig:"REQUIRE" default:"true"`
+	// PreviousSharedSecret is also accepted while callers move to a rotated
+	// SharedSecret, until PreviousSharedSecretExpiresAt (RFC 3339) if set.
+	PreviousSharedSecret          string    `envconfig:"PREVIOUS_SHARED_SECRET"`
+	PreviousSharedSecretExpiresAt time.Time `envconfig:"PREVIOUS_SHARED_SECRET_EXPIRES_AT"`
+	HeaderName                    string    `envconfig:"HEADER_NAME" default:"X-Payment-Service-Auth"`
+	Require                       bool      `envconfig:"REQUIRE" default:"true"`
+	// HMACMaxSkew is how far a signed request's timestamp may be from the
+	// server's clock. Nonces are kept for twice as long.
+	HMACMaxSkew time.Duration `envconfig:"HMAC_MAX_SKEW" default:"5m"`
+}
+
+// MTLSConfig holds the settings of the mutual TLS listener.
+type MTLSConfig struct {
+	// Enabled serves HTTPS and requires a client certificate signed by
+	// ClientCAFile on every connection, instead of plain HTTP.
+	Enabled      bool   `envconfig:"ENABLED" default:"false"`
+	CertFile     string `envconfig:"CERT_FILE"`
+	KeyFile      string `envconfig:"KEY_FILE"`
+	ClientCAFile string `envconfig:"CLIENT_CA_FILE"`
+	// ReloadInterval is how often the files are checked for changes; 0
+	// disables reloading.
+	ReloadInterval time.Duration `envconfig:"RELOAD_INTERVAL" default:"1m"`
+}
+
+// CredentialsConfig holds the settings for stored BYOK gateway credentials.
+type CredentialsConfig struct {
+	// MasterKey is the base64-encoded 32-byte AES key that wraps the data key
+	// of every stored credential. Credential storage is disabled without it.
+	MasterKey string `envconfig:"MASTER_KEY"`
+	// MasterKeyVersion numbers MasterKey in the key ring. Raise it with each
+	// new key and keep the replaced one in PreviousKeys until the stored
+	// credentials are re-wrapped.
+	MasterKeyVersion int `envconfig:"MASTER_KEY_VERSION" default:"1"`
+	// PreviousKeys are retired base64 master keys by version
+	// ("1:base64,2:base64"), used only to read records they still wrap.
+	PreviousKeys map[int]string `envconfig:"PREVIOUS_KEYS"`
+	// RotationBatchSize is how many records the key rotation job re-wraps
+	// per batch.
+	RotationBatchSize int `envconfig:"ROTATION_BATCH_SIZE" default:"100"`
+	// SchemaTTL is how long a UPG gateway's credential structure, used to
+	// validate saved credentials, is cached.
+	SchemaTTL time.Duration `envconfig:"SCHEMA_TTL" default:"1h"`
 }
 
 // Config aggregates all service configuration.
 type Config struct {
-	App        AppConfig
-	Database   DatabaseConfig
-	ARIDB      ARIDBConfig
-	Redis      RedisConfig
-	Vaultera   VaulteraConfig
-	PCIBooking PCIBookingConfig
-	Processor  ProcessorConfig
-	Auth       AuthConfig
+	App         AppConfig
+	Database    DatabaseConfig
+	ARIDB       ARIDBConfig
+	Redis       RedisConfig
+	Vaultera    VaulteraConfig
+	PCIBooking  PCIBookingConfig
+	Sandbox     SandboxConfig
+	Processor   ProcessorConfig
+	Resilience  ResilienceConfig
+	Idempotency IdempotencyConfig
+	RateLimit   RateLimitConfig
+	Auth        AuthConfig
+	MTLS        MTLSConfig
+	Credentials CredentialsConfig
 }
 
 // Load reads configuration from environment variables.
@@ -94,12 +202,30 @@ func Load() (*Config, error) {
 	if err := envconfig.Process("PCI_BOOKING", &cfg.PCIBooking); err != nil {
 		return nil, err
 	}
+	if err := envconfig.Process("SANDBOX", &cfg.Sandbox); err != nil {
+		return nil, err
+	}
 	if err := envconfig.Process("PROCESSOR", &cfg.Processor); err != nil {
 		return nil, err
 	}
+	if err := envconfig.Process("PROCESSOR", &cfg.Resilience); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process("IDEMPOTENCY", &cfg.Idempotency); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process("RATE_LIMIT", &cfg.RateLimit); err != nil {
+		return nil, err
+	}
 	if err := envconfig.Process("AUTH", &cfg.Auth); err != nil {
 		return nil, err
 	}
+	if err := envconfig.Process("MTLS", &cfg.MTLS); err != nil {
+		return nil, err
+	}
+	if err := envconfig.Process("CREDENTIALS", &cfg.Credentials); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
@@ -0,0 +1,377 @@
+// Package credentials stores hotels' own (BYOK) gateway credentials in the
+// primary database, encrypted at rest with envelope encryption.
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/google/uuid"
+)
+
+// ErrNotFound is returned when a credential does not exist for the property.
+var ErrNotFound = errors.New("credentials: credential not found")
+
+// Mode is how the credentials are used to reach the gateway.
+type Mode string
+
+const (
+	// ModeUPG credentials are for a gateway reached through a processor's
+	// Universal Payment Gateway, and are validated against its credential
+	// structure.
+	ModeUPG Mode = "upg"
+	// ModeRelay credentials are for a gateway adapter that calls the gateway
+	// over the processor's relay.
+	ModeRelay Mode = "relay"
+)
+
+// Credential is a property's credentials for one payment gateway.
+type Credential struct {
+	ID         string `json:"id"`
+	PropertyID int64  `json:"property_id"`
+	Mode       Mode   `json:"mode"`
+	// Gateway names the gateway the credentials are for: a UPG gateway name
+	// or a gateway adapter name, depending on Mode.
+	Gateway string `json:"gateway"`
+	Label   string `json:"label,omitempty"`
+	// Fields lists the names of the secret fields held. Secrets themselves are
+	// never serialized.
+	Fields  []string          `json:"fields"`
+	Secrets map[string]string `json:"-"`
+	// LastTest is the outcome of the most recent connection test, if any.
+	LastTest *TestResult `json:"last_test,omitempty"`
+	// Registration is set once UPG credentials are registered with a
+	// processor.
+	Registration *Registration `json:"registration,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// Registration records the credentials ID under which a processor holds a
+// copy of UPG credentials. UPG operations take this ID instead of the secrets.
+type Registration struct {
+	Processor     string    `json:"processor"`
+	CredentialsID string    `json:"credentials_id"`
+	RegisteredAt  time.Time `json:"registered_at"`
+}
+
+// TestResult is the outcome of a connection test of a credential against its
+// gateway.
+type TestResult struct {
+	Passed   bool      `json:"passed"`
+	Reason   string    `json:"reason,omitempty"`
+	TestedAt time.Time `json:"tested_at"`
+}
+
+// Record is a Credential as stored, with its secrets sealed.
+type Record struct {
+	ID         string
+	PropertyID int64
+	Mode       Mode
+	Gateway    string
+	Label      string
+	Fields     []string
+	// Ciphertext holds the JSON-encoded secrets encrypted under the data key,
+	// and WrappedKey the data key encrypted under master key KeyVersion.
+	Ciphertext   []byte
+	WrappedKey   []byte
+	KeyVersion   int
+	LastTest     *TestResult
+	Registration *Registration
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Store persists sealed credential records. Records are always looked up
+// within a property.
+type Store interface {
+	// Create inserts rec, setting its timestamps. The ID is assigned by the
+	// caller because it is bound into the ciphertext.
+	Create(ctx context.Context, rec *Record) error
+	// Get returns a record or ErrNotFound.
+	Get(ctx context.Context, propertyID int64, id string) (*Record, error)
+	// List returns a property's records, newest first.
+	List(ctx context.Context, propertyID int64) ([]Record, error)
+	// Update replaces the label, fields, sealed secrets and registration of
+	// rec, setting its UpdatedAt, or returns ErrNotFound.
+	Update(ctx context.Context, rec *Record) error
+	// Delete removes a record or returns ErrNotFound.
+	Delete(ctx context.Context, propertyID int64, id string) error
+	// RecordTest stores the outcome of a connection test, or returns
+	// ErrNotFound.
+	RecordTest(ctx context.Context, propertyID int64, id string, r TestResult) error
+	// SetRegistration stores or, when reg is nil, clears the processor
+	// registration of a record, or returns ErrNotFound.
+	SetRegistration(ctx context.Context, propertyID int64, id string, reg *Registration) error
+
+	// ListStale returns up to limit records of any property whose data key
+	// is not wrapped by master key version, in ID order starting after the
+	// ID after.
+	ListStale(ctx context.Context, version int, after string, limit int) ([]Record, error)
+	// Rewrap replaces the wrapped data key and key version of rec if it is
+	// still wrapped by master key version from, or returns ErrNotFound when
+	// it was deleted or resealed since it was read.
+	Rewrap(ctx context.Context, rec *Record, from int) error
+	// CountKeyVersions returns the number of records by master key version.
+	CountKeyVersions(ctx context.Context) (map[int]int, error)
+}
+
+// Service encrypts credentials on the way into a Store and decrypts them on
+// the way out.
+type Service struct {
+	store  Store
+	sealer *Sealer
+}
+
+// NewService creates a Service that seals secrets with sealer.
+func NewService(store Store, sealer *Sealer) *Service {
+	return &Service{store: store, sealer: sealer}
+}
+
+// Update is a partial update of a credential. A nil Label is left unchanged.
+// Secrets are merged into the stored secrets; an empty value removes the field.
+type Update struct {
+	Label   *string
+	Secrets map[string]string
+	// Check, when set, is called with the credential after the merge and
+	// before it is stored; an error aborts the update.
+	Check func(*Credential) error
+}
+
+// Create seals and stores c, assigning its ID, fields and timestamps.
+func (s *Service) Create(ctx context.Context, c *Credential) error {
+	c.ID = uuid.NewString()
+	rec := &Record{ID: c.ID, PropertyID: c.PropertyID, Mode: c.Mode, Gateway: c.Gateway, Label: c.Label}
+	if err := s.seal(rec, c.Secrets); err != nil {
+		return err
+	}
+	if err := s.store.Create(ctx, rec); err != nil {
+		return err
+	}
+	c.Fields, c.CreatedAt, c.UpdatedAt = rec.Fields, rec.CreatedAt, rec.UpdatedAt
+	return nil
+}
+
+// Get returns a credential with its secrets decrypted.
+func (s *Service) Get(ctx context.Context, propertyID int64, id string) (*Credential, error) {
+	rec, err := s.store.Get(ctx, propertyID, id)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := s.open(rec)
+	if err != nil {
+		return nil, err
+	}
+	c := credentialOf(*rec)
+	c.Secrets = secrets
+	return &c, nil
+}
+
+// List returns a property's credentials without their secrets.
+func (s *Service) List(ctx context.Context, propertyID int64) ([]Credential, error) {
+	recs, err := s.store.List(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	creds := make([]Credential, len(recs))
+	for i, rec := range recs {
+		creds[i] = credentialOf(rec)
+	}
+	return creds, nil
+}
+
+// Update applies u to a credential and reseals its secrets under a new data
+// key. Changing the secrets clears the credential's registration, which no
+// longer matches them; Check still sees the old registration, so the caller
+// can remove the processor's copy.
+func (s *Service) Update(ctx context.Context, propertyID int64, id string, u Update) (*Credential, error) {
+	c, err := s.Get(ctx, propertyID, id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Label != nil {
+		c.Label = *u.Label
+	}
+	for k, v := range u.Secrets {
+		if v == "" {
+			delete(c.Secrets, k)
+		} else {
+			c.Secrets[k] = v
+		}
+	}
+	if u.Check != nil {
+		if err := u.Check(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(u.Secrets) > 0 {
+		c.Registration = nil
+	}
+
+	rec := &Record{ID: c.ID, PropertyID: c.PropertyID, Mode: c.Mode, Gateway: c.Gateway, Label: c.Label,
+		Registration: c.Registration, CreatedAt: c.CreatedAt}
+	if err := s.seal(rec, c.Secrets); err != nil {
+		return nil, err
+	}
+	if err := s.store.Update(ctx, rec); err != nil {
+		return nil, err
+	}
+	c.Fields, c.UpdatedAt = rec.Fields, rec.UpdatedAt
+	return c, nil
+}
+
+// Delete removes a credential.
+func (s *Service) Delete(ctx context.Context, propertyID int64, id string) error {
+	return s.store.Delete(ctx, propertyID, id)
+}
+
+// RecordTest stores the outcome of a connection test of a credential.
+func (s *Service) RecordTest(ctx context.Context, propertyID int64, id string, r TestResult) error {
+	return s.store.RecordTest(ctx, propertyID, id, r)
+}
+
+// Register registers the secrets of c, as returned by Get, with proc as UPG
+// credentials and records the credentials ID proc returns on c.
+func (s *Service) Register(ctx context.Context, proc processor.Processor, c *Credential) error {
+	if c.Mode != ModeUPG {
+		return fmt.Errorf("credentials: %s credentials cannot be registered with a processor", c.Mode)
+	}
+	upg, err := processor.As[processor.UPG](proc, processor.CapabilityUPGCredentials)
+	if err != nil {
+		return err
+	}
+	id, err := upg.CreateUPGCredentials(ctx, c.Gateway, c.Secrets)
+	if err != nil {
+		var pe *processor.Error
+		if !errors.As(err, &pe) {
+			err = &processor.Error{Processor: proc.Name(), Err: err}
+		}
+		return err
+	}
+	reg := &Registration{Processor: proc.Name(), CredentialsID: id, RegisteredAt: time.Now().UTC()}
+	if err := s.store.SetRegistration(ctx, c.PropertyID, c.ID, reg); err != nil {
+		return err
+	}
+	c.Registration = reg
+	return nil
+}
+
+// CredentialsID returns the ID under which proc holds the secrets of c, as
+// returned by Get, registering them with proc first if it does not.
+func (s *Service) CredentialsID(ctx context.Context, proc processor.Processor, c *Credential) (string, error) {
+	if r := c.Registration; r != nil && r.Processor == proc.Name() {
+		return r.CredentialsID, nil
+	}
+	if err := s.Register(ctx, proc, c); err != nil {
+		return "", err
+	}
+	return c.Registration.CredentialsID, nil
+}
+
+// ResolveUPG returns the ID under which proc holds the property's UPG
+// credentials for gateway, registering them on first use. When the property
+// holds several for the gateway the newest is used; without any it returns
+// ErrNotFound.
+func (s *Service) ResolveUPG(ctx context.Context, proc processor.Processor, propertyID int64, gateway string) (string, error) {
+	creds, err := s.List(ctx, propertyID)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range creds {
+		if c.Mode != ModeUPG || !strings.EqualFold(c.Gateway, gateway) {
+			continue
+		}
+		if r := c.Registration; r != nil && r.Processor == proc.Name() {
+			return r.CredentialsID, nil
+		}
+		full, err := s.Get(ctx, propertyID, c.ID)
+		if err != nil {
+			return "", err
+		}
+		return s.CredentialsID(ctx, proc, full)
+	}
+	return "", ErrNotFound
+}
+
+// ResolveRelay returns the decrypted secrets of the property's relay
+// credentials for gateway. When the property holds several for the gateway
+// the newest is used; without any it returns ErrNotFound.
+func (s *Service) ResolveRelay(ctx context.Context, propertyID int64, gateway string) (map[string]string, error) {
+	creds, err := s.List(ctx, propertyID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range creds {
+		if c.Mode != ModeRelay || !strings.EqualFold(c.Gateway, gateway) {
+			continue
+		}
+		full, err := s.Get(ctx, propertyID, c.ID)
+		if err != nil {
+			return nil, err
+		}
+		return full.Secrets, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *Service) seal(rec *Record, secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return fmt.Errorf("credentials: encode secrets: %w", err)
+	}
+	rec.Ciphertext, rec.WrappedKey, rec.KeyVersion, err = s.sealer.Seal(plaintext, recordAAD(rec))
+	if err != nil {
+		return err
+	}
+	rec.Fields = fieldNames(secrets)
+	return nil
+}
+
+func (s *Service) open(rec *Record) (map[string]string, error) {
+	plaintext, err := s.sealer.Open(rec.Ciphertext, rec.WrappedKey, rec.KeyVersion, recordAAD(rec))
+	if err != nil {
+		return nil, err
+	}
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("credentials: decode secrets: %w", err)
+	}
+	return secrets, nil
+}
+
+// recordAAD binds a record's ciphertext to its property and ID, so sealed
+// secrets copied to another row fail to decrypt.
+func recordAAD(rec *Record) []byte {
+	return []byte(fmt.Sprintf("%d/%s", rec.PropertyID, rec.ID))
+}
+
+func credentialOf(rec Record) Credential {
+	return Credential{
+		ID:           rec.ID,
+		PropertyID:   rec.PropertyID,
+		Mode:         rec.Mode,
+		Gateway:      rec.Gateway,
+		Label:        rec.Label,
+		Fields:       rec.Fields,
+		LastTest:     rec.LastTest,
+		Registration: rec.Registration,
+		CreatedAt:    rec.CreatedAt,
+		UpdatedAt:    rec.UpdatedAt,
+	}
+}
+
+func fieldNames(secrets map[string]string) []string {
+	names := make([]string, 0, len(secrets))
+	for k := range secrets {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
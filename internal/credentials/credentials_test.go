@@ -0,0 +1,251 @@
+package credentials_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/credentials"
+	"github.com/CentraGlobal/backend-payment-go/internal/sandbox"
+)
+
+func newSealer(t *testing.T) *credentials.Sealer {
+	t.Helper()
+	key := make([]byte, credentials.KeySize)
+	rand.Read(key)
+	s, err := credentials.NewSealer(key)
+	if err != nil {
+		t.Fatalf("NewSealer: %v", err)
+	}
+	return s
+}
+
+func TestSealer_RoundTrip(t *testing.T) {
+	s := newSealer(t)
+	ciphertext, wrapped, version, err := s.Seal([]byte("sk_live_secret"), []byte("1/a"))
+	if err != nil || version != 1 {
+		t.Fatalf("Seal: version %d, %v", version, err)
+	}
+	if bytes.Contains(ciphertext, []byte("sk_live_secret")) {
+		t.Fatal("expected ciphertext not to contain the plaintext")
+	}
+
+	got, err := s.Open(ciphertext, wrapped, version, []byte("1/a"))
+	if err != nil || string(got) != "sk_live_secret" {
+		t.Fatalf("Open: %q %v", got, err)
+	}
+	if _, err := s.Open(ciphertext, wrapped, version, []byte("2/a")); !errors.Is(err, credentials.ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt for another record's aad, got %v", err)
+	}
+	if _, err := newSealer(t).Open(ciphertext, wrapped, version, []byte("1/a")); !errors.Is(err, credentials.ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt under another master key, got %v", err)
+	}
+	if _, err := s.Open(ciphertext, wrapped, 2, []byte("1/a")); !errors.Is(err, credentials.ErrUnknownKeyVersion) {
+		t.Errorf("expected ErrUnknownKeyVersion, got %v", err)
+	}
+}
+
+func TestSealer_Rewrap(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, credentials.KeySize), bytes.Repeat([]byte{2}, credentials.KeySize)
+	old, _ := credentials.NewKeyRing(1, map[int][]byte{1: oldKey})
+	ring, err := credentials.NewKeyRing(2, map[int][]byte{1: oldKey, 2: newKey})
+	if err != nil {
+		t.Fatalf("NewKeyRing: %v", err)
+	}
+	if _, err := credentials.NewKeyRing(3, map[int][]byte{1: oldKey}); err == nil {
+		t.Error("expected an error when the current version is missing")
+	}
+
+	ciphertext, wrapped, _, _ := old.Seal([]byte("secret"), []byte("1/a"))
+	if got, err := ring.Open(ciphertext, wrapped, 1, []byte("1/a")); err != nil || string(got) != "secret" {
+		t.Fatalf("expected the ring to open version 1 records, got %q %v", got, err)
+	}
+	rewrapped, version, err := ring.Rewrap(wrapped, 1, []byte("1/a"))
+	if err != nil || version != 2 {
+		t.Fatalf("Rewrap: version %d, %v", version, err)
+	}
+	retired, _ := credentials.NewKeyRing(2, map[int][]byte{2: newKey})
+	if got, err := retired.Open(ciphertext, rewrapped, 2, []byte("1/a")); err != nil || string(got) != "secret" {
+		t.Errorf("expected the rewrapped record to open without the old key, got %q %v", got, err)
+	}
+}
+
+func TestParseMasterKey(t *testing.T) {
+	if _, err := credentials.ParseMasterKey(base64.StdEncoding.EncodeToString(make([]byte, 32))); err != nil {
+		t.Errorf("expected 32-byte key to parse, got %v", err)
+	}
+	if _, err := credentials.ParseMasterKey(base64.StdEncoding.EncodeToString(make([]byte, 16))); err == nil {
+		t.Error("expected error for a 16-byte key")
+	}
+	if _, err := credentials.ParseMasterKey("not base64!"); err == nil {
+		t.Error("expected error for invalid base64")
+	}
+}
+
+func TestService_CRUD(t *testing.T) {
+	ctx := context.Background()
+	store := credentials.NewMemoryStore()
+	svc := credentials.NewService(store, newSealer(t))
+
+	cred := &credentials.Credential{PropertyID: 1, Gateway: "Stripe", Secrets: map[string]string{"secret_key": "sk_1", "account": "acct_1"}}
+	if err := svc.Create(ctx, cred); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if cred.ID == "" || len(cred.Fields) != 2 || cred.Fields[0] != "account" {
+		t.Fatalf("unexpected created credential %+v", cred)
+	}
+
+	rec, _ := store.Get(ctx, 1, cred.ID)
+	if bytes.Contains(rec.Ciphertext, []byte("sk_1")) || len(rec.WrappedKey) == 0 {
+		t.Fatalf("expected sealed secrets at rest, got %+v", rec)
+	}
+
+	got, err := svc.Get(ctx, 1, cred.ID)
+	if err != nil || got.Secrets["secret_key"] != "sk_1" {
+		t.Fatalf("Get: %+v %v", got, err)
+	}
+	if _, err := svc.Get(ctx, 2, cred.ID); !errors.Is(err, credentials.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for another property, got %v", err)
+	}
+
+	label := "live"
+	updated, err := svc.Update(ctx, 1, cred.ID, credentials.Update{Label: &label, Secrets: map[string]string{"secret_key": "sk_2", "account": ""}})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Label != "live" || len(updated.Fields) != 1 || updated.Secrets["secret_key"] != "sk_2" {
+		t.Errorf("unexpected updated credential %+v", updated)
+	}
+	if after, _ := store.Get(ctx, 1, cred.ID); bytes.Equal(after.WrappedKey, rec.WrappedKey) {
+		t.Error("expected update to reseal under a new data key")
+	}
+
+	list, _ := svc.List(ctx, 1)
+	if len(list) != 1 || list[0].Secrets != nil {
+		t.Errorf("expected one credential without secrets, got %+v", list)
+	}
+
+	if err := svc.Delete(ctx, 1, cred.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := svc.Delete(ctx, 1, cred.ID); !errors.Is(err, credentials.ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestService_ResolveUPG(t *testing.T) {
+	ctx := context.Background()
+	svc := credentials.NewService(credentials.NewMemoryStore(), newSealer(t))
+	proc := sandbox.NewClient(sandbox.NewMemoryStore())
+
+	if _, err := svc.ResolveUPG(ctx, proc, 1, "Stripe"); !errors.Is(err, credentials.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound without credentials, got %v", err)
+	}
+	svc.Create(ctx, &credentials.Credential{PropertyID: 1, Mode: credentials.ModeRelay, Gateway: "stripe", Secrets: map[string]string{"secret_key": "sk_0"}})
+	cred := &credentials.Credential{PropertyID: 1, Mode: credentials.ModeUPG, Gateway: "Stripe", Secrets: map[string]string{"secret_key": "sk_1"}}
+	svc.Create(ctx, cred)
+
+	id, err := svc.ResolveUPG(ctx, proc, 1, "stripe")
+	if err != nil || id == "" {
+		t.Fatalf("ResolveUPG: %q %v", id, err)
+	}
+	got, _ := svc.Get(ctx, 1, cred.ID)
+	if got.Registration == nil || got.Registration.CredentialsID != id || got.Registration.Processor != "sandbox" {
+		t.Fatalf("expected the registration to be stored, got %+v", got.Registration)
+	}
+	if again, _ := svc.ResolveUPG(ctx, proc, 1, "Stripe"); again != id {
+		t.Errorf("expected the stored registration to be reused, got %q", again)
+	}
+
+	updated, _ := svc.Update(ctx, 1, cred.ID, credentials.Update{Secrets: map[string]string{"secret_key": "sk_2"}})
+	if updated.Registration != nil {
+		t.Errorf("expected new secrets to clear the registration, got %+v", updated.Registration)
+	}
+	if again, _ := svc.ResolveUPG(ctx, proc, 1, "Stripe"); again == id {
+		t.Error("expected new secrets to be registered again")
+	}
+}
+
+func TestService_ResolveRelay(t *testing.T) {
+	ctx := context.Background()
+	svc := credentials.NewService(credentials.NewMemoryStore(), newSealer(t))
+
+	svc.Create(ctx, &credentials.Credential{PropertyID: 1, Mode: credentials.ModeUPG, Gateway: "stripe", Secrets: map[string]string{"secret_key": "sk_upg"}})
+	if _, err := svc.ResolveRelay(ctx, 1, "stripe"); !errors.Is(err, credentials.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound without relay credentials, got %v", err)
+	}
+	svc.Create(ctx, &credentials.Credential{PropertyID: 1, Mode: credentials.ModeRelay, Gateway: "Stripe", Secrets: map[string]string{"secret_key": "sk_relay"}})
+
+	secrets, err := svc.ResolveRelay(ctx, 1, "stripe")
+	if err != nil {
+		t.Fatalf("ResolveRelay: %v", err)
+	}
+	if secrets["secret_key"] != "sk_relay" {
+		t.Errorf("expected the relay secrets, got %v", secrets)
+	}
+	if _, err := svc.ResolveRelay(ctx, 2, "stripe"); !errors.Is(err, credentials.ErrNotFound) {
+		t.Errorf("expected another property's credentials to be ignored, got %v", err)
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	schema := credentials.ParseSchema(map[string]any{
+		"merchant_id": "Merchant ID",
+		"amount_cap":  map[string]any{"type": "Number"},
+		"live":        map[string]any{"type": "boolean", "required": true},
+	})
+	if spec := schema["merchant_id"]; !spec.Required || spec.Type != "string" {
+		t.Errorf("expected a non-object field to be a required string, got %+v", spec)
+	}
+
+	if err := schema.Validate(map[string]string{"merchant_id": "m", "live": "false"}); err != nil {
+		t.Errorf("expected valid secrets, got %v", err)
+	}
+	err := schema.Validate(map[string]string{"amount_cap": "ten", "live": "yes", "other": "x"})
+	var verr *credentials.ValidationError
+	if !errors.As(err, &verr) || len(verr.Fields) != 4 {
+		t.Fatalf("expected 4 field errors, got %v", err)
+	}
+	if f := verr.Fields[0]; f.Field != "amount_cap" || f.Code != credentials.CodeInvalidType {
+		t.Errorf("unexpected first field error %+v", f)
+	}
+}
+
+func TestRotator_RewrapsToCurrentVersion(t *testing.T) {
+	ctx := context.Background()
+	store := credentials.NewMemoryStore()
+	oldKey, newKey := bytes.Repeat([]byte{1}, credentials.KeySize), bytes.Repeat([]byte{2}, credentials.KeySize)
+	v1, _ := credentials.NewKeyRing(1, map[int][]byte{1: oldKey})
+	var ids []string
+	for i := 0; i < 5; i++ {
+		cred := &credentials.Credential{PropertyID: 1, Gateway: "Stripe", Secrets: map[string]string{"secret_key": "sk"}}
+		credentials.NewService(store, v1).Create(ctx, cred)
+		ids = append(ids, cred.ID)
+	}
+
+	ring, _ := credentials.NewKeyRing(2, map[int][]byte{1: oldKey, 2: newKey})
+	rotator := credentials.NewRotator(credentials.NewService(store, ring), 2)
+	status, err := rotator.Start(ctx)
+	if err != nil || status.Total != 5 || status.TargetVersion != 2 {
+		t.Fatalf("Start: %+v %v", status, err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for status.State == credentials.RotationRunning && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		status, _ = rotator.Status(ctx)
+	}
+	if status.State != credentials.RotationCompleted || status.Rewrapped != 5 || status.KeyVersions[2] != 5 || status.KeyVersions[1] != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	v2only, _ := credentials.NewKeyRing(2, map[int][]byte{2: newKey})
+	for _, id := range ids {
+		if got, err := credentials.NewService(store, v2only).Get(ctx, 1, id); err != nil || got.Secrets["secret_key"] != "sk" {
+			t.Errorf("expected %s to open with the new key only, got %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// KeySize is the size in bytes of the master key and of each data key
+// (AES-256).
+const KeySize = 32
+
+// ErrDecrypt is returned when a sealed record cannot be opened: the master
+// key is wrong or the record was tampered with or moved to another row.
+var ErrDecrypt = errors.New("credentials: decryption failed")
+
+// ErrUnknownKeyVersion is returned when a record's data key is wrapped by a
+// master key version that is not in the key ring.
+var ErrUnknownKeyVersion = errors.New("credentials: unknown master key version")
+
+// Sealer encrypts credential secrets with envelope encryption. Each record is
+// encrypted under its own random data key with AES-256-GCM, and the data key
+// is stored wrapped (encrypted) by the master key, so the master key never
+// touches the secrets directly and can be rotated by re-wrapping data keys.
+//
+// A Sealer holds a key ring of numbered master key versions: data keys are
+// wrapped with the current version and unwrapped with whichever version
+// wrapped them, so records sealed under a retired key stay readable until
+// they are re-wrapped.
+type Sealer struct {
+	current int
+	masters map[int]cipher.AEAD
+}
+
+// ParseMasterKey decodes a base64-encoded master key, as configured in
+// CREDENTIALS_MASTER_KEY.
+func ParseMasterKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("credentials: master key is not valid base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("credentials: master key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// NewSealer creates a Sealer with masterKey as its only key, version 1.
+func NewSealer(masterKey []byte) (*Sealer, error) {
+	return NewKeyRing(1, map[int][]byte{1: masterKey})
+}
+
+// NewKeyRing creates a Sealer over the master keys by version. current is the
+// version that wraps new data keys and must be among keys.
+func NewKeyRing(current int, keys map[int][]byte) (*Sealer, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("credentials: current master key version %d is not in the key ring", current)
+	}
+	s := &Sealer{current: current, masters: make(map[int]cipher.AEAD, len(keys))}
+	for version, key := range keys {
+		if version < 1 {
+			return nil, fmt.Errorf("credentials: master key version must be positive, got %d", version)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("credentials: master key version %d must be %d bytes, got %d", version, KeySize, len(key))
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		s.masters[version] = aead
+	}
+	return s, nil
+}
+
+// CurrentVersion returns the master key version that wraps new data keys.
+func (s *Sealer) CurrentVersion() int {
+	return s.current
+}
+
+// Versions returns the master key versions in the key ring, in ascending
+// order.
+func (s *Sealer) Versions() []int {
+	versions := make([]int, 0, len(s.masters))
+	for v := range s.masters {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Seal encrypts plaintext under a new data key and returns the ciphertext,
+// the data key wrapped by the current master key, and that key's version.
+// aad binds both to their record; the same aad must be passed to Open.
+func (s *Sealer) Seal(plaintext, aad []byte) (ciphertext, wrappedKey []byte, version int, err error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, 0, fmt.Errorf("credentials: generate data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if ciphertext, err = seal(aead, plaintext, aad); err != nil {
+		return nil, nil, 0, err
+	}
+	if wrappedKey, err = seal(s.masters[s.current], dataKey, aad); err != nil {
+		return nil, nil, 0, err
+	}
+	return ciphertext, wrappedKey, s.current, nil
+}
+
+// Open unwraps the data key with the master key version that wrapped it and
+// decrypts ciphertext.
+func (s *Sealer) Open(ciphertext, wrappedKey []byte, version int, aad []byte) ([]byte, error) {
+	dataKey, err := s.unwrap(wrappedKey, version, aad)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, ciphertext, aad)
+}
+
+// Rewrap unwraps a data key wrapped by master key version and wraps it again
+// with the current master key, returning the new wrapped key and its version.
+// The record's ciphertext is unchanged.
+func (s *Sealer) Rewrap(wrappedKey []byte, version int, aad []byte) ([]byte, int, error) {
+	dataKey, err := s.unwrap(wrappedKey, version, aad)
+	if err != nil {
+		return nil, 0, err
+	}
+	rewrapped, err := seal(s.masters[s.current], dataKey, aad)
+	if err != nil {
+		return nil, 0, err
+	}
+	return rewrapped, s.current, nil
+}
+
+func (s *Sealer) unwrap(wrappedKey []byte, version int, aad []byte) ([]byte, error) {
+	master, ok := s.masters[version]
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownKeyVersion, version)
+	}
+	return open(master, wrappedKey, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("credentials: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("credentials: %w", err)
+	}
+	return aead, nil
+}
+
+// seal encrypts plaintext with a random nonce, which is prepended to the
+// returned ciphertext.
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("credentials: generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,153 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store held in process memory, for tests and development
+// without a database.
+type MemoryStore struct {
+	mu   sync.Mutex
+	recs map[string]Record
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{recs: map[string]Record{}}
+}
+
+func (m *MemoryStore) Create(_ context.Context, rec *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	rec.CreatedAt, rec.UpdatedAt = now, now
+	m.recs[rec.ID] = *rec
+	return nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, propertyID int64, id string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.recs[id]
+	if !ok || rec.PropertyID != propertyID {
+		return nil, ErrNotFound
+	}
+	return &rec, nil
+}
+
+func (m *MemoryStore) List(_ context.Context, propertyID int64) ([]Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	recs := []Record{}
+	for _, rec := range m.recs {
+		if rec.PropertyID == propertyID {
+			recs = append(recs, rec)
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if !recs[i].CreatedAt.Equal(recs[j].CreatedAt) {
+			return recs[i].CreatedAt.After(recs[j].CreatedAt)
+		}
+		return recs[i].ID < recs[j].ID
+	})
+	return recs, nil
+}
+
+func (m *MemoryStore) Update(_ context.Context, rec *Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.recs[rec.ID]
+	if !ok || stored.PropertyID != rec.PropertyID {
+		return ErrNotFound
+	}
+	stored.Label = rec.Label
+	stored.Fields = rec.Fields
+	stored.Ciphertext = rec.Ciphertext
+	stored.WrappedKey = rec.WrappedKey
+	stored.KeyVersion = rec.KeyVersion
+	stored.Registration = rec.Registration
+	stored.UpdatedAt = time.Now()
+	m.recs[rec.ID] = stored
+	rec.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, propertyID int64, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.recs[id]
+	if !ok || rec.PropertyID != propertyID {
+		return ErrNotFound
+	}
+	delete(m.recs, id)
+	return nil
+}
+
+func (m *MemoryStore) RecordTest(_ context.Context, propertyID int64, id string, r TestResult) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.recs[id]
+	if !ok || rec.PropertyID != propertyID {
+		return ErrNotFound
+	}
+	rec.LastTest = &r
+	m.recs[id] = rec
+	return nil
+}
+
+func (m *MemoryStore) SetRegistration(_ context.Context, propertyID int64, id string, reg *Registration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec, ok := m.recs[id]
+	if !ok || rec.PropertyID != propertyID {
+		return ErrNotFound
+	}
+	rec.Registration = reg
+	m.recs[id] = rec
+	return nil
+}
+
+func (m *MemoryStore) ListStale(_ context.Context, version int, after string, limit int) ([]Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	recs := []Record{}
+	for _, rec := range m.recs {
+		if rec.KeyVersion != version && rec.ID > after {
+			recs = append(recs, rec)
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].ID < recs[j].ID })
+	if len(recs) > limit {
+		recs = recs[:limit]
+	}
+	return recs, nil
+}
+
+func (m *MemoryStore) Rewrap(_ context.Context, rec *Record, from int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.recs[rec.ID]
+	if !ok || stored.PropertyID != rec.PropertyID || stored.KeyVersion != from || !bytes.Equal(stored.Ciphertext, rec.Ciphertext) {
+		return ErrNotFound
+	}
+	stored.WrappedKey = rec.WrappedKey
+	stored.KeyVersion = rec.KeyVersion
+	m.recs[rec.ID] = stored
+	return nil
+}
+
+func (m *MemoryStore) CountKeyVersions(_ context.Context) (map[int]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := map[int]int{}
+	for _, rec := range m.recs {
+		counts[rec.KeyVersion]++
+	}
+	return counts, nil
+}
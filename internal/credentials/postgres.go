@@ -0,0 +1,249 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore is a Store backed by the gateway_credentials table (see
+// migrations/0006_gateway_credentials.sql through
+// 0010_credential_key_versions.sql).
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+// NewPostgresStore creates a PostgresStore backed by the given pool.
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+const recordColumns = `id, property_id, mode, gateway, label, fields, ciphertext, wrapped_key, key_version,
+	last_tested_at, last_test_passed, last_test_reason,
+	upg_processor, upg_credentials_id, upg_registered_at, created_at, updated_at`
+
+func (p *PostgresStore) Create(ctx context.Context, rec *Record) error {
+	err := p.pool.QueryRow(ctx,
+		`INSERT INTO gateway_credentials (id, property_id, mode, gateway, label, fields, ciphertext, wrapped_key, key_version)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING created_at, updated_at`,
+		rec.ID, rec.PropertyID, rec.Mode, rec.Gateway, rec.Label, rec.Fields, rec.Ciphertext, rec.WrappedKey, rec.KeyVersion,
+	).Scan(&rec.CreatedAt, &rec.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("credentials: insert credential: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) Get(ctx context.Context, propertyID int64, id string) (*Record, error) {
+	row := p.pool.QueryRow(ctx,
+		`SELECT `+recordColumns+` FROM gateway_credentials WHERE property_id = $1 AND id = $2`,
+		propertyID, id)
+	rec, err := scanRecord(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("credentials: get credential: %w", err)
+	}
+	return rec, nil
+}
+
+func (p *PostgresStore) List(ctx context.Context, propertyID int64) ([]Record, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+recordColumns+` FROM gateway_credentials WHERE property_id = $1 ORDER BY created_at DESC, id`,
+		propertyID)
+	if err != nil {
+		return nil, fmt.Errorf("credentials: list credentials: %w", err)
+	}
+	defer rows.Close()
+
+	recs := []Record{}
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("credentials: scan credential: %w", err)
+		}
+		recs = append(recs, *rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("credentials: list credentials: %w", err)
+	}
+	return recs, nil
+}
+
+func (p *PostgresStore) Update(ctx context.Context, rec *Record) error {
+	upgProcessor, upgCredentialsID, upgRegisteredAt := registrationColumns(rec.Registration)
+	err := p.pool.QueryRow(ctx,
+		`UPDATE gateway_credentials SET
+		     label              = $3,
+		     fields             = $4,
+		     ciphertext         = $5,
+		     wrapped_key        = $6,
+		     key_version        = $7,
+		     upg_processor      = $8,
+		     upg_credentials_id = $9,
+		     upg_registered_at  = $10,
+		     updated_at         = now()
+		 WHERE property_id = $1 AND id = $2
+		 RETURNING updated_at`,
+		rec.PropertyID, rec.ID, rec.Label, rec.Fields, rec.Ciphertext, rec.WrappedKey, rec.KeyVersion,
+		upgProcessor, upgCredentialsID, upgRegisteredAt,
+	).Scan(&rec.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("credentials: update credential: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) Delete(ctx context.Context, propertyID int64, id string) error {
+	tag, err := p.pool.Exec(ctx,
+		`DELETE FROM gateway_credentials WHERE property_id = $1 AND id = $2`, propertyID, id)
+	if err != nil {
+		return fmt.Errorf("credentials: delete credential: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) RecordTest(ctx context.Context, propertyID int64, id string, r TestResult) error {
+	tag, err := p.pool.Exec(ctx,
+		`UPDATE gateway_credentials SET
+		     last_tested_at   = $3,
+		     last_test_passed = $4,
+		     last_test_reason = $5
+		 WHERE property_id = $1 AND id = $2`,
+		propertyID, id, r.TestedAt, r.Passed, r.Reason)
+	if err != nil {
+		return fmt.Errorf("credentials: record test: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) SetRegistration(ctx context.Context, propertyID int64, id string, reg *Registration) error {
+	upgProcessor, upgCredentialsID, upgRegisteredAt := registrationColumns(reg)
+	tag, err := p.pool.Exec(ctx,
+		`UPDATE gateway_credentials SET
+		     upg_processor      = $3,
+		     upg_credentials_id = $4,
+		     upg_registered_at  = $5
+		 WHERE property_id = $1 AND id = $2`,
+		propertyID, id, upgProcessor, upgCredentialsID, upgRegisteredAt)
+	if err != nil {
+		return fmt.Errorf("credentials: set registration: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) ListStale(ctx context.Context, version int, after string, limit int) ([]Record, error) {
+	var rows pgx.Rows
+	var err error
+	if after == "" {
+		rows, err = p.pool.Query(ctx,
+			`SELECT `+recordColumns+` FROM gateway_credentials WHERE key_version <> $1 ORDER BY id LIMIT $2`,
+			version, limit)
+	} else {
+		rows, err = p.pool.Query(ctx,
+			`SELECT `+recordColumns+` FROM gateway_credentials WHERE key_version <> $1 AND id > $2 ORDER BY id LIMIT $3`,
+			version, after, limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("credentials: list stale credentials: %w", err)
+	}
+	defer rows.Close()
+
+	recs := []Record{}
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("credentials: scan credential: %w", err)
+		}
+		recs = append(recs, *rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("credentials: list stale credentials: %w", err)
+	}
+	return recs, nil
+}
+
+func (p *PostgresStore) Rewrap(ctx context.Context, rec *Record, from int) error {
+	tag, err := p.pool.Exec(ctx,
+		`UPDATE gateway_credentials SET wrapped_key = $5, key_version = $6
+		 WHERE property_id = $1 AND id = $2 AND key_version = $3 AND ciphertext = $4`,
+		rec.PropertyID, rec.ID, from, rec.Ciphertext, rec.WrappedKey, rec.KeyVersion)
+	if err != nil {
+		return fmt.Errorf("credentials: rewrap credential: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) CountKeyVersions(ctx context.Context) (map[int]int, error) {
+	rows, err := p.pool.Query(ctx, `SELECT key_version, count(*) FROM gateway_credentials GROUP BY key_version`)
+	if err != nil {
+		return nil, fmt.Errorf("credentials: count key versions: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[int]int{}
+	for rows.Next() {
+		var version, n int
+		if err := rows.Scan(&version, &n); err != nil {
+			return nil, fmt.Errorf("credentials: count key versions: %w", err)
+		}
+		counts[version] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("credentials: count key versions: %w", err)
+	}
+	return counts, nil
+}
+
+// registrationColumns returns the column values of reg; a nil reg clears them.
+func registrationColumns(reg *Registration) (string, string, *time.Time) {
+	if reg == nil {
+		return "", "", nil
+	}
+	return reg.Processor, reg.CredentialsID, &reg.RegisteredAt
+}
+
+func scanRecord(row pgx.Row) (*Record, error) {
+	var r Record
+	var testedAt, registeredAt *time.Time
+	var test TestResult
+	var reg Registration
+	err := row.Scan(&r.ID, &r.PropertyID, &r.Mode, &r.Gateway, &r.Label, &r.Fields, &r.Ciphertext, &r.WrappedKey, &r.KeyVersion,
+		&testedAt, &test.Passed, &test.Reason,
+		&reg.Processor, &reg.CredentialsID, &registeredAt, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if testedAt != nil {
+		test.TestedAt = *testedAt
+		r.LastTest = &test
+	}
+	if registeredAt != nil {
+		reg.RegisteredAt = *registeredAt
+		r.Registration = &reg
+	}
+	return &r, nil
+}
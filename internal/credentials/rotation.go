@@ -0,0 +1,183 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRotationRunning is returned when a key rotation is started while one is
+// already running.
+var ErrRotationRunning = errors.New("credentials: key rotation already running")
+
+// RotationState is the state of a key rotation run.
+type RotationState string
+
+const (
+	RotationIdle      RotationState = "idle"
+	RotationRunning   RotationState = "running"
+	RotationCompleted RotationState = "completed"
+	RotationFailed    RotationState = "failed"
+)
+
+// RotationStatus reports the progress of re-wrapping stored data keys with
+// the current master key.
+type RotationStatus struct {
+	State RotationState `json:"state"`
+	// TargetVersion is the master key version records are re-wrapped with.
+	TargetVersion int `json:"target_version"`
+	// Total is the number of records under other versions when the run
+	// started.
+	Total     int `json:"total"`
+	Rewrapped int `json:"rewrapped"`
+	// Skipped counts records deleted or resealed by an update during the run.
+	Skipped int `json:"skipped"`
+	// Failed counts records whose data key could not be unwrapped, such as
+	// records under a version missing from the key ring. They are left as
+	// they are.
+	Failed int `json:"failed"`
+	// Error describes the last record failure, or why the run stopped.
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// KeyVersions counts the stored records by master key version.
+	KeyVersions map[int]int `json:"key_versions"`
+}
+
+// Rotator re-wraps the data keys of stored credentials with the current
+// master key, so that a retired key can be removed from the key ring. Only
+// the wrapped data keys change; the secrets are not decrypted. Progress is
+// tracked in memory by the instance running the rotation, while the record
+// counts by key version in its status are read from the store.
+type Rotator struct {
+	store     Store
+	sealer    *Sealer
+	batchSize int
+
+	mu     sync.Mutex
+	status RotationStatus
+}
+
+// NewRotator creates a Rotator for the credentials of s that re-wraps
+// batchSize records at a time.
+func NewRotator(s *Service, batchSize int) *Rotator {
+	if batchSize < 1 {
+		batchSize = 100
+	}
+	return &Rotator{
+		store:     s.store,
+		sealer:    s.sealer,
+		batchSize: batchSize,
+		status:    RotationStatus{State: RotationIdle, TargetVersion: s.sealer.CurrentVersion()},
+	}
+}
+
+// Start begins re-wrapping, in the background, every record whose data key
+// is not wrapped by the current master key, and returns the initial status.
+// The run outlives ctx. It returns ErrRotationRunning while a run is in
+// progress.
+func (r *Rotator) Start(ctx context.Context) (RotationStatus, error) {
+	r.mu.Lock()
+	if r.status.State == RotationRunning {
+		r.mu.Unlock()
+		return RotationStatus{}, ErrRotationRunning
+	}
+	r.status = RotationStatus{State: RotationRunning, TargetVersion: r.sealer.CurrentVersion()}
+	r.mu.Unlock()
+
+	counts, err := r.store.CountKeyVersions(ctx)
+	if err != nil {
+		r.finish(err)
+		return RotationStatus{}, err
+	}
+	now := time.Now().UTC()
+	r.mu.Lock()
+	for version, n := range counts {
+		if version != r.status.TargetVersion {
+			r.status.Total += n
+		}
+	}
+	r.status.StartedAt = &now
+	r.mu.Unlock()
+
+	go r.run(context.WithoutCancel(ctx))
+	return r.Status(ctx)
+}
+
+// Status returns the progress of the current or last run with the current
+// record counts by key version.
+func (r *Rotator) Status(ctx context.Context) (RotationStatus, error) {
+	counts, err := r.store.CountKeyVersions(ctx)
+	if err != nil {
+		return RotationStatus{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := r.status
+	status.KeyVersions = counts
+	return status, nil
+}
+
+func (r *Rotator) run(ctx context.Context) {
+	target := r.sealer.CurrentVersion()
+	after := ""
+	for {
+		recs, err := r.store.ListStale(ctx, target, after, r.batchSize)
+		if err != nil {
+			r.finish(err)
+			return
+		}
+		if len(recs) == 0 {
+			r.finish(nil)
+			return
+		}
+		for i := range recs {
+			after = recs[i].ID
+			if err := r.rewrap(ctx, &recs[i]); err != nil {
+				r.finish(err)
+				return
+			}
+		}
+	}
+}
+
+// rewrap re-wraps the data key of one record. Records that cannot be
+// unwrapped are counted as failed; only store errors stop the run.
+func (r *Rotator) rewrap(ctx context.Context, rec *Record) error {
+	from := rec.KeyVersion
+	wrapped, version, err := r.sealer.Rewrap(rec.WrappedKey, from, recordAAD(rec))
+	if err != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.status.Failed++
+		r.status.Error = fmt.Sprintf("credential %s: %v", rec.ID, err)
+		return nil
+	}
+	rec.WrappedKey, rec.KeyVersion = wrapped, version
+	err = r.store.Rewrap(ctx, rec, from)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.status.Skipped++
+	} else {
+		r.status.Rewrapped++
+	}
+	return nil
+}
+
+func (r *Rotator) finish(err error) {
+	now := time.Now().UTC()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.FinishedAt = &now
+	r.status.State = RotationCompleted
+	if err != nil {
+		r.status.State = RotationFailed
+		r.status.Error = err.Error()
+	}
+}
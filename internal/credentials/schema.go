@@ -0,0 +1,164 @@
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+)
+
+// Field error codes reported by Schema.Validate.
+const (
+	CodeRequired     = "required"
+	CodeUnknownField = "unknown_field"
+	CodeInvalidType  = "invalid_type"
+	// CodeUnknownGateway is reported on the gateway field when the processor
+	// has no such UPG gateway.
+	CodeUnknownGateway = "unknown_gateway"
+)
+
+// FieldError describes a problem with one submitted credential field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem found in a credential payload.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "credentials: invalid credentials: " + strings.Join(msgs, "; ")
+}
+
+// FieldSpec is one field of a gateway's credential structure.
+type FieldSpec struct {
+	// Type is the declared field type, such as "string", "number", "integer"
+	// or "boolean". Values of unrecognized types are not type checked.
+	Type     string
+	Required bool
+}
+
+// Schema is the credential structure of a UPG gateway, keyed by field name.
+type Schema map[string]FieldSpec
+
+// ParseSchema reads the structure returned by
+// processor.UPG.GetCredentialsStructure: a map of field name to an
+// object with optional "type" and "required" keys. A field given as anything
+// other than an object is treated as a required string.
+func ParseSchema(structure map[string]any) Schema {
+	s := make(Schema, len(structure))
+	for name, v := range structure {
+		spec := FieldSpec{Type: "string", Required: true}
+		if m, ok := v.(map[string]any); ok {
+			spec.Required = false
+			if t, ok := m["type"].(string); ok && t != "" {
+				spec.Type = strings.ToLower(t)
+			}
+			if r, ok := m["required"].(bool); ok {
+				spec.Required = r
+			}
+		}
+		s[name] = spec
+	}
+	return s
+}
+
+// Validate checks secrets against the schema: every required field present
+// and non-empty, no fields the gateway does not define, and values that parse
+// as their declared type. It returns a *ValidationError listing every problem,
+// sorted by field name.
+func (s Schema) Validate(secrets map[string]string) error {
+	var errs []FieldError
+	for name, spec := range s {
+		v, ok := secrets[name]
+		if !ok || v == "" {
+			if spec.Required {
+				errs = append(errs, FieldError{name, CodeRequired, "is required"})
+			}
+			continue
+		}
+		if !validType(spec.Type, v) {
+			errs = append(errs, FieldError{name, CodeInvalidType, "must be of type " + spec.Type})
+		}
+	}
+	for name := range secrets {
+		if _, ok := s[name]; !ok {
+			errs = append(errs, FieldError{name, CodeUnknownField, "is not a credential field of this gateway"})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return &ValidationError{Fields: errs}
+}
+
+func validType(typ, v string) bool {
+	var err error
+	switch typ {
+	case "number", "float", "double", "decimal":
+		_, err = strconv.ParseFloat(v, 64)
+	case "integer", "int", "long":
+		_, err = strconv.ParseInt(v, 10, 64)
+	case "boolean", "bool":
+		_, err = strconv.ParseBool(v)
+	}
+	return err == nil
+}
+
+// SchemaCache caches gateway credential structures per processor and gateway,
+// since they change rarely and are needed on every credential save.
+type SchemaCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]schemaEntry
+}
+
+type schemaEntry struct {
+	schema  Schema
+	expires time.Time
+}
+
+// NewSchemaCache creates a SchemaCache that keeps structures for ttl.
+func NewSchemaCache(ttl time.Duration) *SchemaCache {
+	return &SchemaCache{ttl: ttl, entries: map[string]schemaEntry{}}
+}
+
+// Get returns the credential schema of gatewayName from proc, fetching it on a
+// miss. Errors are not cached.
+func (c *SchemaCache) Get(ctx context.Context, proc processor.UPG, gatewayName string) (Schema, error) {
+	key := proc.Name() + "/" + strings.ToLower(gatewayName)
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.schema, nil
+	}
+
+	structure, err := proc.GetCredentialsStructure(ctx, gatewayName)
+	if err != nil {
+		return nil, err
+	}
+	if len(structure) == 0 {
+		return nil, fmt.Errorf("credentials: empty credential structure for gateway %q", gatewayName)
+	}
+	schema := ParseSchema(structure)
+
+	c.mu.Lock()
+	c.entries[key] = schemaEntry{schema: schema, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return schema, nil
+}
@@ -0,0 +1,155 @@
+// Package gateway defines adapters that charge and refund a vaulted card at a
+// payment gateway through the vault's detokenization relay (processor.SendCard).
+//
+// An adapter builds the gateway-specific request with the vault's card
+// placeholders, sends it via the resolved processor and interprets the
+// gateway's response as a types.ChargeResult.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+)
+
+var (
+	// ErrUnknownGateway is returned when no adapter is registered for a gateway name.
+	ErrUnknownGateway = errors.New("gateway: unknown gateway")
+	// ErrMissingCredential is returned when a required gateway credential is absent.
+	ErrMissingCredential = errors.New("gateway: missing credential")
+)
+
+// ChargeRequest holds the parameters for charging a vaulted card at a gateway.
+type ChargeRequest struct {
+	CardToken   string
+	Amount      money.Money
+	Reference   string
+	Description string
+	// Credentials are the hotel's own gateway credentials (BYOK), keyed by the
+	// field names the adapter documents.
+	Credentials map[string]string
+}
+
+// RefundRequest holds the parameters for refunding an earlier gateway charge.
+type RefundRequest struct {
+	// CardToken is the charged card's token. Refunds carry no card details,
+	// but the relay call is made on behalf of a vaulted card.
+	CardToken string
+	// TransactionID is the gateway's ID for the charge being refunded.
+	TransactionID string
+	Amount        money.Money
+	Reference     string
+	Credentials   map[string]string
+}
+
+// VerifyRequest holds the parameters for checking gateway credentials with a
+// vaulted card.
+type VerifyRequest struct {
+	CardToken   string
+	Credentials map[string]string
+}
+
+// Adapter charges cards at one gateway via the vault relay.
+type Adapter interface {
+	Name() string
+	// Charge sets the result's status, transaction ID, decline code, message,
+	// gateway and unredacted Raw body; the caller fills in the rest.
+	Charge(ctx context.Context, proc processor.Relayer, req ChargeRequest) (*types.ChargeResult, error)
+	// Refund refunds all or part of a charge, filling in the result as Charge
+	// does; the transaction ID is the gateway's refund ID.
+	Refund(ctx context.Context, proc processor.Relayer, req RefundRequest) (*types.ChargeResult, error)
+}
+
+// Verifier is implemented by adapters that can check the hotel's credentials
+// and a card with the gateway without moving money, for connection tests.
+type Verifier interface {
+	// Verify fills in the result as Charge does. Success, Accepted and
+	// Rejected (a decline of the card) all mean the gateway accepted the
+	// credentials.
+	Verify(ctx context.Context, proc processor.Relayer, req VerifyRequest) (*types.ChargeResult, error)
+}
+
+// Registry holds the available adapters, looked up case-insensitively by name.
+type Registry struct {
+	mu       sync.RWMutex
+	adapters map[string]Adapter
+}
+
+// NewRegistry creates a Registry containing adapters.
+func NewRegistry(adapters ...Adapter) *Registry {
+	r := &Registry{adapters: map[string]Adapter{}}
+	for _, a := range adapters {
+		r.Register(a)
+	}
+	return r
+}
+
+// Register adds a, replacing any adapter with the same name.
+func (r *Registry) Register(a Adapter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.adapters[strings.ToLower(a.Name())] = a
+}
+
+// Get returns the adapter for name.
+func (r *Registry) Get(name string) (Adapter, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	a, ok := r.adapters[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownGateway, name)
+	}
+	return a, nil
+}
+
+// Placeholders returns the relay placeholders for proc, failing with
+// ErrUnsupported when the vault's placeholder syntax is unknown.
+func Placeholders(proc processor.Processor) (processor.Placeholders, error) {
+	ph, ok := processor.PlaceholdersFor(proc.Name())
+	if !ok {
+		return processor.Placeholders{}, processor.Unsupported(proc.Name(), "relay placeholders")
+	}
+	return ph, nil
+}
+
+// Credential returns the named credential or ErrMissingCredential.
+func Credential(creds map[string]string, name string) (string, error) {
+	v := creds[name]
+	if v == "" {
+		return "", fmt.Errorf("%w: %s", ErrMissingCredential, name)
+	}
+	return v, nil
+}
+
+// HTTPStatus maps a gateway's HTTP status code to a charge status when the
+// response body carries no better information: 402 is a decline, 429 and 5xx
+// are temporary, other 4xx are fatal and anything else is a success.
+func HTTPStatus(code int) types.UPGStatus {
+	switch {
+	case code == http.StatusPaymentRequired:
+		return types.UPGStatusRejected
+	case code == http.StatusTooManyRequests, code >= 500:
+		return types.UPGStatusTemporaryFailure
+	case code >= 400:
+		return types.UPGStatusFatalFailure
+	}
+	return types.UPGStatusSuccess
+}
+
+// RelayBody returns the gateway response body from a relay response as JSON.
+// Vaults return it either as a JSON value or as a JSON string holding the raw body.
+func RelayBody(b json.RawMessage) json.RawMessage {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		return json.RawMessage(s)
+	}
+	return b
+}
@@ -0,0 +1,81 @@
+// Package gatewaytest provides a relay processor for testing gateway adapters
+// against local stub servers.
+package gatewaytest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+)
+
+// Card is the card data the relay substitutes for placeholders.
+type Card struct {
+	Number          string
+	ExpirationMonth string
+	ExpirationYear  string
+	CardholderName  string
+}
+
+// Relay is a processor.Relayer that behaves like a vault relay: SendCard
+// replaces the named vault's placeholders with Card and performs the request.
+// Methods other than Name, Capabilities and SendCard are not implemented.
+type Relay struct {
+	processor.Processor
+	VaultName string
+	Card      Card
+
+	// LastRequest is the request as received, before placeholder substitution.
+	LastRequest processor.SendRequest
+}
+
+// NewRelay creates a Relay using vaultName's placeholder syntax.
+func NewRelay(vaultName string, card Card) *Relay {
+	return &Relay{VaultName: vaultName, Card: card}
+}
+
+func (r *Relay) Name() string { return r.VaultName }
+
+func (r *Relay) Capabilities() processor.Capabilities {
+	return processor.Capabilities{processor.CapabilityRelay}
+}
+
+func (r *Relay) SendCard(ctx context.Context, _ string, req processor.SendRequest) (*processor.SendResponse, error) {
+	r.LastRequest = req
+	ph, ok := processor.PlaceholdersFor(r.VaultName)
+	if !ok {
+		return nil, fmt.Errorf("gatewaytest: unknown vault %q", r.VaultName)
+	}
+	replace := strings.NewReplacer(
+		ph.CardNumber, r.Card.Number,
+		ph.ExpirationMonth, r.Card.ExpirationMonth,
+		ph.ExpirationYear, r.Card.ExpirationYear,
+		ph.CardholderName, r.Card.CardholderName,
+	).Replace
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, replace(req.URL), strings.NewReader(replace(req.Body)))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, replace(v))
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, processor.TransportError(r.VaultName, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(body) {
+		body, _ = json.Marshal(string(body))
+	}
+	return &processor.SendResponse{StatusCode: resp.StatusCode, Body: body}, nil
+}
@@ -0,0 +1,307 @@
+// Package payzone implements a gateway.Adapter for the Payzone payment
+// gateway's SOAP CardDetailsTransaction and CrossReferenceTransaction calls
+// through the vault relay, using the hotel's own merchant credentials.
+package payzone
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+)
+
+// DefaultURL is the Payzone gateway endpoint.
+const DefaultURL = "https://gw1.payzoneonlinepayments.com:4430/"
+
+// Credential fields for the hotel's Payzone merchant account.
+const (
+	CredentialMerchantID = "merchant_id"
+	CredentialPassword   = "password"
+)
+
+// SOAP actions for charges and for refunds of an earlier transaction.
+const (
+	soapAction               = "https://www.thepaymentgateway.net/CardDetailsTransaction"
+	crossReferenceSOAPAction = "https://www.thepaymentgateway.net/CrossReferenceTransaction"
+)
+
+// Payzone transaction status codes. Any other code (30 for a failed
+// transaction), or none at all, is treated as a fatal failure.
+const (
+	statusSuccess     = 0
+	statusThreeDS     = 3
+	statusReferred    = 4
+	statusDeclined    = 5
+	statusDuplicate   = 20
+	statusUnavailable = -1
+)
+
+var _ gateway.Adapter = (*Adapter)(nil)
+
+// Adapter charges cards at Payzone via the vault relay.
+type Adapter struct {
+	url string
+}
+
+// New creates a Payzone adapter. An empty url uses DefaultURL.
+func New(url string) *Adapter {
+	if url == "" {
+		url = DefaultURL
+	}
+	return &Adapter{url: url}
+}
+
+func (a *Adapter) Name() string {
+	return "payzone"
+}
+
+// Charge performs a SALE CardDetailsTransaction for req.Amount with the card
+// details substituted by the vault.
+func (a *Adapter) Charge(ctx context.Context, proc processor.Relayer, req gateway.ChargeRequest) (*types.ChargeResult, error) {
+	merchantID, err := gateway.Credential(req.Credentials, CredentialMerchantID)
+	if err != nil {
+		return nil, err
+	}
+	password, err := gateway.Credential(req.Credentials, CredentialPassword)
+	if err != nil {
+		return nil, err
+	}
+	ph, err := gateway.Placeholders(proc)
+	if err != nil {
+		return nil, err
+	}
+	body, err := xml.Marshal(envelope{
+		Body: envelopeBody{Transaction: &cardDetailsTransaction{
+			PaymentMessage: paymentMessage{
+				MerchantAuthentication: merchantAuthentication{MerchantID: merchantID, Password: password},
+				TransactionDetails: transactionDetails{
+					Amount:           req.Amount.Minor,
+					CurrencyCode:     req.Amount.Currency.Numeric,
+					MessageDetails:   messageDetails{TransactionType: "SALE"},
+					OrderID:          req.Reference,
+					OrderDescription: req.Description,
+				},
+				CardDetails: cardDetails{
+					CardName:   ph.CardholderName,
+					CardNumber: ph.CardNumber,
+					ExpiryDate: expiryDate{Month: ph.ExpirationMonth, Year: ph.ExpirationYear},
+				},
+			},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("payzone: marshal request: %w", err)
+	}
+
+	return a.send(ctx, proc, req.CardToken, soapAction, body)
+}
+
+// Refund performs a REFUND CrossReferenceTransaction of req.Amount against
+// the transaction whose CrossReference is req.TransactionID.
+func (a *Adapter) Refund(ctx context.Context, proc processor.Relayer, req gateway.RefundRequest) (*types.ChargeResult, error) {
+	merchantID, err := gateway.Credential(req.Credentials, CredentialMerchantID)
+	if err != nil {
+		return nil, err
+	}
+	password, err := gateway.Credential(req.Credentials, CredentialPassword)
+	if err != nil {
+		return nil, err
+	}
+	body, err := xml.Marshal(envelope{
+		Body: envelopeBody{CrossReference: &crossReferenceTransaction{
+			PaymentMessage: crossReferenceMessage{
+				MerchantAuthentication: merchantAuthentication{MerchantID: merchantID, Password: password},
+				TransactionDetails: transactionDetails{
+					Amount:       req.Amount.Minor,
+					CurrencyCode: req.Amount.Currency.Numeric,
+					MessageDetails: messageDetails{
+						TransactionType: "REFUND",
+						NewTransaction:  "FALSE",
+						CrossReference:  req.TransactionID,
+					},
+					OrderID: req.Reference,
+				},
+			},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("payzone: marshal request: %w", err)
+	}
+	return a.send(ctx, proc, req.CardToken, crossReferenceSOAPAction, body)
+}
+
+func (a *Adapter) send(ctx context.Context, proc processor.Relayer, cardToken, action string, body []byte) (*types.ChargeResult, error) {
+	resp, err := proc.SendCard(ctx, cardToken, processor.SendRequest{
+		Method: http.MethodPost,
+		URL:    a.url,
+		Headers: map[string]string{
+			"Content-Type": "text/xml; charset=utf-8",
+			"SOAPAction":   action,
+		},
+		Body: xml.Header + string(body),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseResponse(resp)
+}
+
+// parseResponse maps Payzone's SOAP response to a charge result.
+func parseResponse(resp *processor.SendResponse) (*types.ChargeResult, error) {
+	body := gateway.RelayBody(resp.Body)
+	result := &types.ChargeResult{Gateway: "payzone"}
+	// The gateway replies in XML; keep it as a JSON string.
+	result.Raw, _ = json.Marshal(string(body))
+
+	if resp.StatusCode >= 500 {
+		result.Status = types.UPGStatusTemporaryFailure
+		result.Message = "payzone: HTTP " + strconv.Itoa(resp.StatusCode)
+		return result, nil
+	}
+
+	var env envelope
+	if err := xml.Unmarshal(body, &env); err != nil {
+		return nil, fmt.Errorf("payzone: decode response: %w", err)
+	}
+	var res transactionResult
+	var out transactionOutput
+	switch {
+	case env.Body.Response != nil:
+		res, out = env.Body.Response.Result, env.Body.Response.Output
+	case env.Body.CrossReferenceResponse != nil:
+		res, out = env.Body.CrossReferenceResponse.Result, env.Body.CrossReferenceResponse.Output
+	default:
+		result.Status = types.UPGStatusFatalFailure
+		result.Message = "payzone: missing transaction response"
+		return result, nil
+	}
+
+	if res.StatusCode != nil && *res.StatusCode == statusDuplicate && res.PreviousTransactionResult != nil {
+		// A repeated OrderID within the duplicate window returns the first
+		// attempt's outcome.
+		res = *res.PreviousTransactionResult
+	}
+	if res.StatusCode == nil {
+		result.Status = types.UPGStatusFatalFailure
+		result.Message = "payzone: missing status code"
+		return result, nil
+	}
+
+	result.TransactionID = out.CrossReference
+	result.Message = res.Message
+	switch *res.StatusCode {
+	case statusSuccess:
+		result.Status = types.UPGStatusSuccess
+	case statusThreeDS:
+		result.Status = types.UPGStatusAccepted
+	case statusReferred:
+		result.Status = types.UPGStatusRejected
+		result.DeclineCode = "referred"
+	case statusDeclined:
+		result.Status = types.UPGStatusRejected
+		result.DeclineCode = "declined"
+	case statusUnavailable:
+		result.Status = types.UPGStatusTemporaryFailure
+	default:
+		result.Status = types.UPGStatusFatalFailure
+	}
+	return result, nil
+}
+
+// envelope is the SOAP envelope for both the request and the response.
+type envelope struct {
+	XMLName xml.Name     `xml:"http://schemas.xmlsoap.org/soap/envelope/ Envelope"`
+	Body    envelopeBody `xml:"http://schemas.xmlsoap.org/soap/envelope/ Body"`
+}
+
+type envelopeBody struct {
+	Transaction            *cardDetailsTransaction            `xml:",omitempty"`
+	CrossReference         *crossReferenceTransaction         `xml:",omitempty"`
+	Response               *cardDetailsTransactionResponse    `xml:",omitempty"`
+	CrossReferenceResponse *crossReferenceTransactionResponse `xml:",omitempty"`
+}
+
+type cardDetailsTransaction struct {
+	XMLName        xml.Name       `xml:"https://www.thepaymentgateway.net/ CardDetailsTransaction"`
+	PaymentMessage paymentMessage `xml:"PaymentMessage"`
+}
+
+type paymentMessage struct {
+	MerchantAuthentication merchantAuthentication `xml:"MerchantAuthentication"`
+	TransactionDetails     transactionDetails     `xml:"TransactionDetails"`
+	CardDetails            cardDetails            `xml:"CardDetails"`
+}
+
+type crossReferenceTransaction struct {
+	XMLName        xml.Name              `xml:"https://www.thepaymentgateway.net/ CrossReferenceTransaction"`
+	PaymentMessage crossReferenceMessage `xml:"PaymentMessage"`
+}
+
+type crossReferenceMessage struct {
+	MerchantAuthentication merchantAuthentication `xml:"MerchantAuthentication"`
+	TransactionDetails     transactionDetails     `xml:"TransactionDetails"`
+}
+
+type merchantAuthentication struct {
+	MerchantID string `xml:"MerchantID,attr"`
+	Password   string `xml:"Password,attr"`
+}
+
+// transactionDetails carries the amount in minor units and the ISO 4217
+// numeric currency code.
+type transactionDetails struct {
+	Amount           int64          `xml:"Amount,attr"`
+	CurrencyCode     int            `xml:"CurrencyCode,attr"`
+	MessageDetails   messageDetails `xml:"MessageDetails"`
+	OrderID          string         `xml:"OrderID,omitempty"`
+	OrderDescription string         `xml:"OrderDescription,omitempty"`
+}
+
+// messageDetails references the earlier transaction by CrossReference for
+// cross-reference transactions such as refunds.
+type messageDetails struct {
+	TransactionType string `xml:"TransactionType,attr"`
+	NewTransaction  string `xml:"NewTransaction,attr,omitempty"`
+	CrossReference  string `xml:"CrossReference,attr,omitempty"`
+}
+
+type cardDetails struct {
+	CardName   string     `xml:"CardName"`
+	CardNumber string     `xml:"CardNumber"`
+	ExpiryDate expiryDate `xml:"ExpiryDate"`
+}
+
+type expiryDate struct {
+	Month string `xml:"Month,attr"`
+	Year  string `xml:"Year,attr"`
+}
+
+type cardDetailsTransactionResponse struct {
+	XMLName xml.Name          `xml:"https://www.thepaymentgateway.net/ CardDetailsTransactionResponse"`
+	Result  transactionResult `xml:"CardDetailsTransactionResult"`
+	Output  transactionOutput `xml:"TransactionOutputData"`
+}
+
+type crossReferenceTransactionResponse struct {
+	XMLName xml.Name          `xml:"https://www.thepaymentgateway.net/ CrossReferenceTransactionResponse"`
+	Result  transactionResult `xml:"CrossReferenceTransactionResult"`
+	Output  transactionOutput `xml:"TransactionOutputData"`
+}
+
+type transactionOutput struct {
+	CrossReference string `xml:"CrossReference,attr"`
+}
+
+// transactionResult is a transaction's outcome. StatusCode is nil when the
+// response has none, which must not read as 0 (success).
+type transactionResult struct {
+	StatusCode                *int               `xml:"StatusCode"`
+	Message                   string             `xml:"Message"`
+	PreviousTransactionResult *transactionResult `xml:"PreviousTransactionResult"`
+}
@@ -0,0 +1,216 @@
+package payzone_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway/gatewaytest"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway/payzone"
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+)
+
+var testCard = gatewaytest.Card{
+	Number:          "4976000000003436",
+	ExpirationMonth: "12",
+	ExpirationYear:  "2030",
+	CardholderName:  "JOHN DOE",
+}
+
+var testCredentials = map[string]string{
+	payzone.CredentialMerchantID: "HOTEL-1234567",
+	payzone.CredentialPassword:   "secret",
+}
+
+const responseTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <CardDetailsTransactionResponse xmlns="https://www.thepaymentgateway.net/">
+      <CardDetailsTransactionResult AuthorisationAttempted="True">
+        <StatusCode>%d</StatusCode>
+        <Message>%s</Message>%s
+      </CardDetailsTransactionResult>
+      <TransactionOutputData CrossReference="%s"/>
+    </CardDetailsTransactionResponse>
+  </soap:Body>
+</soap:Envelope>`
+
+// stubPayzone checks the relayed SOAP request and replies with response.
+func stubPayzone(t *testing.T, response string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("SOAPAction") != "https://www.thepaymentgateway.net/CardDetailsTransaction" {
+			t.Errorf("unexpected SOAPAction %q", r.Header.Get("SOAPAction"))
+		}
+		b, _ := io.ReadAll(r.Body)
+		for _, want := range []string{
+			`MerchantID="HOTEL-1234567"`,
+			`Amount="1050"`,
+			`CurrencyCode="826"`,
+			`TransactionType="SALE"`,
+			"<CardNumber>" + testCard.Number + "</CardNumber>",
+			`Month="12" Year="2030"`,
+		} {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("request missing %s:\n%s", want, b)
+			}
+		}
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		io.WriteString(w, response)
+	}))
+}
+
+func charge(t *testing.T, srv *httptest.Server, vault string) (*types.ChargeResult, *gatewaytest.Relay) {
+	t.Helper()
+	relay := gatewaytest.NewRelay(vault, testCard)
+	result, err := payzone.New(srv.URL).Charge(context.Background(), relay, gateway.ChargeRequest{
+		CardToken:   "tok_abc",
+		Amount:      money.MustParse("10.50", "GBP"),
+		Reference:   "res-1",
+		Credentials: testCredentials,
+	})
+	if err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+	return result, relay
+}
+
+func TestCharge_StatusCodes(t *testing.T) {
+	tests := []struct {
+		code        int
+		previous    string
+		want        types.UPGStatus
+		declineCode string
+	}{
+		{0, "", types.UPGStatusSuccess, ""},
+		{3, "", types.UPGStatusAccepted, ""},
+		{4, "", types.UPGStatusRejected, "referred"},
+		{5, "", types.UPGStatusRejected, "declined"},
+		{20, "<PreviousTransactionResult><StatusCode>5</StatusCode><Message>Card declined</Message></PreviousTransactionResult>", types.UPGStatusRejected, "declined"},
+		{30, "", types.UPGStatusFatalFailure, ""},
+	}
+
+	for _, tc := range tests {
+		srv := stubPayzone(t, fmt.Sprintf(responseTemplate, tc.code, "message", tc.previous, "xref-1"))
+		result, _ := charge(t, srv, "vaultera")
+		srv.Close()
+		if result.Status != tc.want || result.DeclineCode != tc.declineCode {
+			t.Errorf("status code %d: expected %s/%q, got %s/%q", tc.code, tc.want, tc.declineCode, result.Status, result.DeclineCode)
+		}
+		if result.TransactionID != "xref-1" {
+			t.Errorf("status code %d: expected cross reference, got %q", tc.code, result.TransactionID)
+		}
+	}
+}
+
+func TestCharge_MissingStatusCode(t *testing.T) {
+	responses := map[string]string{
+		"no status code": strings.Replace(fmt.Sprintf(responseTemplate, 0, "message", "", "xref-1"), "<StatusCode>0</StatusCode>", "", 1),
+		"duplicate without previous status code": fmt.Sprintf(responseTemplate, 20, "message",
+			"<PreviousTransactionResult><Message>Card declined</Message></PreviousTransactionResult>", "xref-1"),
+	}
+
+	for name, response := range responses {
+		srv := stubPayzone(t, response)
+		result, _ := charge(t, srv, "vaultera")
+		srv.Close()
+		if result.Status != types.UPGStatusFatalFailure {
+			t.Errorf("%s: expected %s, got %s", name, types.UPGStatusFatalFailure, result.Status)
+		}
+	}
+}
+
+func TestCharge_VaultPlaceholders(t *testing.T) {
+	tests := []struct {
+		vault, placeholder string
+	}{
+		{"vaultera", "<CardNumber>%CARD_NUMBER%</CardNumber>"},
+		{"pcibooking", "<CardNumber>{{{CardNumber}}}</CardNumber>"},
+	}
+	for _, tc := range tests {
+		srv := stubPayzone(t, fmt.Sprintf(responseTemplate, 0, "AuthCode: 123", "", "xref-1"))
+		_, relay := charge(t, srv, tc.vault)
+		srv.Close()
+		if !strings.Contains(relay.LastRequest.Body, tc.placeholder) {
+			t.Errorf("%s: expected %s in relayed body, got %s", tc.vault, tc.placeholder, relay.LastRequest.Body)
+		}
+	}
+}
+
+func TestCharge_GatewayError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	result, _ := charge(t, srv, "vaultera")
+	if result.Status != types.UPGStatusTemporaryFailure {
+		t.Errorf("expected TemporaryFailure, got %s", result.Status)
+	}
+}
+
+func TestCharge_MissingCredentials(t *testing.T) {
+	_, err := payzone.New("").Charge(context.Background(), gatewaytest.NewRelay("vaultera", testCard), gateway.ChargeRequest{
+		Amount:      money.MustParse("10.50", "GBP"),
+		Credentials: map[string]string{payzone.CredentialMerchantID: "HOTEL-1234567"},
+	})
+	if !errors.Is(err, gateway.ErrMissingCredential) {
+		t.Errorf("expected ErrMissingCredential, got %v", err)
+	}
+}
+
+func TestRefund(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("SOAPAction") != "https://www.thepaymentgateway.net/CrossReferenceTransaction" {
+			t.Errorf("unexpected SOAPAction %q", r.Header.Get("SOAPAction"))
+		}
+		b, _ := io.ReadAll(r.Body)
+		for _, want := range []string{
+			`Amount="400"`,
+			`CurrencyCode="826"`,
+			`TransactionType="REFUND" NewTransaction="FALSE" CrossReference="xref-1"`,
+			"<OrderID>refund-1</OrderID>",
+		} {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("request missing %s:\n%s", want, b)
+			}
+		}
+		if strings.Contains(string(b), "CardNumber") {
+			t.Errorf("expected no card details in refund:\n%s", b)
+		}
+		io.WriteString(w, `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <CrossReferenceTransactionResponse xmlns="https://www.thepaymentgateway.net/">
+      <CrossReferenceTransactionResult AuthorisationAttempted="True">
+        <StatusCode>0</StatusCode>
+        <Message>Refund successful</Message>
+      </CrossReferenceTransactionResult>
+      <TransactionOutputData CrossReference="xref-2"/>
+    </CrossReferenceTransactionResponse>
+  </soap:Body>
+</soap:Envelope>`)
+	}))
+	defer srv.Close()
+
+	result, err := payzone.New(srv.URL).Refund(context.Background(), gatewaytest.NewRelay("vaultera", testCard), gateway.RefundRequest{
+		CardToken:     "tok_abc",
+		TransactionID: "xref-1",
+		Amount:        money.MustParse("4.00", "GBP"),
+		Reference:     "refund-1",
+		Credentials:   testCredentials,
+	})
+	if err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if result.Status != types.UPGStatusSuccess || result.TransactionID != "xref-2" || result.Message != "Refund successful" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
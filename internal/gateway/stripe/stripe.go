@@ -0,0 +1,357 @@
+// Package stripe implements a gateway.Adapter that creates and confirms Stripe
+// PaymentIntents, and refunds them, through the vault relay using the hotel's
+// own secret key.
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+)
+
+// DefaultBaseURL is the Stripe API base URL.
+const DefaultBaseURL = "https://api.stripe.com"
+
+// CredentialSecretKey is the credential field holding the hotel's Stripe secret key.
+const CredentialSecretKey = "secret_key"
+
+var (
+	_ gateway.Adapter  = (*Adapter)(nil)
+	_ gateway.Verifier = (*Adapter)(nil)
+)
+
+// Adapter charges cards at Stripe via the vault relay.
+type Adapter struct {
+	baseURL string
+}
+
+// New creates a Stripe adapter. An empty baseURL uses DefaultBaseURL; tests
+// point it at a local Stripe-shaped stub.
+func New(baseURL string) *Adapter {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Adapter{baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (a *Adapter) Name() string {
+	return "stripe"
+}
+
+// paymentIntent is the subset of a Stripe PaymentIntent (or error response)
+// the adapter reads.
+type paymentIntent struct {
+	ID               string       `json:"id"`
+	Status           string       `json:"status"`
+	LastPaymentError *stripeError `json:"last_payment_error"`
+	Error            *stripeError `json:"error"`
+}
+
+type paymentIntentRef struct {
+	ID string `json:"id"`
+}
+
+// stripeError is Stripe's error object, returned either top-level under
+// "error" or as a PaymentIntent's last_payment_error.
+type stripeError struct {
+	Type          string            `json:"type"`
+	Code          string            `json:"code"`
+	DeclineCode   string            `json:"decline_code"`
+	Message       string            `json:"message"`
+	PaymentIntent *paymentIntentRef `json:"payment_intent"`
+}
+
+// Charge creates a confirmed PaymentIntent for req.Amount with the card
+// details substituted by the vault.
+func (a *Adapter) Charge(ctx context.Context, proc processor.Relayer, req gateway.ChargeRequest) (*types.ChargeResult, error) {
+	secretKey, err := gateway.Credential(req.Credentials, CredentialSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	ph, err := gateway.Placeholders(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + secretKey,
+		"Content-Type":  "application/x-www-form-urlencoded",
+	}
+	if req.Reference != "" {
+		headers["Idempotency-Key"] = req.Reference
+	}
+
+	resp, err := proc.SendCard(ctx, req.CardToken, processor.SendRequest{
+		Method:  http.MethodPost,
+		URL:     a.baseURL + "/v1/payment_intents",
+		Headers: headers,
+		Body:    paymentIntentBody(req, ph),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseResponse(resp)
+}
+
+// refund is the subset of a Stripe Refund the adapter reads.
+type refund struct {
+	ID            string       `json:"id"`
+	Status        string       `json:"status"`
+	FailureReason string       `json:"failure_reason"`
+	Error         *stripeError `json:"error"`
+}
+
+// Refund creates a Refund of req.Amount against the PaymentIntent
+// req.TransactionID.
+func (a *Adapter) Refund(ctx context.Context, proc processor.Relayer, req gateway.RefundRequest) (*types.ChargeResult, error) {
+	secretKey, err := gateway.Credential(req.Credentials, CredentialSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + secretKey,
+		"Content-Type":  "application/x-www-form-urlencoded",
+	}
+	if req.Reference != "" {
+		headers["Idempotency-Key"] = req.Reference
+	}
+	params := url.Values{}
+	params.Set("payment_intent", req.TransactionID)
+	params.Set("amount", strconv.FormatInt(req.Amount.Minor, 10))
+	if req.Reference != "" {
+		params.Set("metadata[reference]", req.Reference)
+	}
+
+	resp, err := proc.SendCard(ctx, req.CardToken, processor.SendRequest{
+		Method:  http.MethodPost,
+		URL:     a.baseURL + "/v1/refunds",
+		Headers: headers,
+		Body:    params.Encode(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseRefundResponse(resp)
+}
+
+// setupIntent is the subset of a Stripe SetupIntent the adapter reads.
+type setupIntent struct {
+	ID             string       `json:"id"`
+	Status         string       `json:"status"`
+	LastSetupError *stripeError `json:"last_setup_error"`
+	Error          *stripeError `json:"error"`
+}
+
+// Verify confirms a SetupIntent for the card, which checks the secret key and
+// the card with Stripe without charging it.
+func (a *Adapter) Verify(ctx context.Context, proc processor.Relayer, req gateway.VerifyRequest) (*types.ChargeResult, error) {
+	secretKey, err := gateway.Credential(req.Credentials, CredentialSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	ph, err := gateway.Placeholders(proc)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("confirm", "true")
+	params.Set("usage", "off_session")
+	params.Set("payment_method_types[]", "card")
+	params.Set("payment_method_data[type]", "card")
+	var b strings.Builder
+	b.WriteString(params.Encode())
+	writeCard(&b, ph)
+
+	resp, err := proc.SendCard(ctx, req.CardToken, processor.SendRequest{
+		Method: http.MethodPost,
+		URL:    a.baseURL + "/v1/setup_intents",
+		Headers: map[string]string{
+			"Authorization": "Bearer " + secretKey,
+			"Content-Type":  "application/x-www-form-urlencoded",
+		},
+		Body: b.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseSetupResponse(resp)
+}
+
+// paymentIntentBody form-encodes the PaymentIntent parameters. Placeholders are
+// appended unescaped so the vault can find and replace them.
+func paymentIntentBody(req gateway.ChargeRequest, ph processor.Placeholders) string {
+	params := url.Values{}
+	// Stripe takes amounts in the currency's minor units.
+	params.Set("amount", strconv.FormatInt(req.Amount.Minor, 10))
+	params.Set("currency", strings.ToLower(req.Amount.Currency.Code))
+	params.Set("confirm", "true")
+	params.Set("payment_method_types[]", "card")
+	params.Set("payment_method_data[type]", "card")
+	if req.Description != "" {
+		params.Set("description", req.Description)
+	}
+	if req.Reference != "" {
+		params.Set("metadata[reference]", req.Reference)
+	}
+
+	var b strings.Builder
+	b.WriteString(params.Encode())
+	writeCard(&b, ph)
+	return b.String()
+}
+
+// writeCard appends the card placeholders as payment_method_data fields.
+func writeCard(b *strings.Builder, ph processor.Placeholders) {
+	for _, p := range []struct{ key, placeholder string }{
+		{"payment_method_data[card][number]", ph.CardNumber},
+		{"payment_method_data[card][exp_month]", ph.ExpirationMonth},
+		{"payment_method_data[card][exp_year]", ph.ExpirationYear},
+		{"payment_method_data[billing_details][name]", ph.CardholderName},
+	} {
+		b.WriteString("&" + url.QueryEscape(p.key) + "=" + p.placeholder)
+	}
+}
+
+// parseResponse maps Stripe's response to a charge result.
+func parseResponse(resp *processor.SendResponse) (*types.ChargeResult, error) {
+	body := gateway.RelayBody(resp.Body)
+	result := &types.ChargeResult{Gateway: "stripe", Raw: body}
+
+	var pi paymentIntent
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &pi); err != nil {
+			return nil, fmt.Errorf("stripe: decode response: %w", err)
+		}
+	}
+
+	if pi.Error != nil {
+		result.DeclineCode = firstNonEmpty(pi.Error.DeclineCode, pi.Error.Code)
+		result.Message = pi.Error.Message
+		if pi.Error.PaymentIntent != nil {
+			result.TransactionID = pi.Error.PaymentIntent.ID
+		}
+	}
+
+	if resp.StatusCode >= 400 {
+		result.Status = gateway.HTTPStatus(resp.StatusCode)
+	} else {
+		result.TransactionID = pi.ID
+		result.Status, result.Message = intentStatus(pi)
+		if pi.LastPaymentError != nil {
+			result.DeclineCode = firstNonEmpty(pi.LastPaymentError.DeclineCode, pi.LastPaymentError.Code)
+			result.Message = pi.LastPaymentError.Message
+		}
+	}
+	return result, nil
+}
+
+// parseRefundResponse maps Stripe's response to a refund to a result.
+func parseRefundResponse(resp *processor.SendResponse) (*types.ChargeResult, error) {
+	body := gateway.RelayBody(resp.Body)
+	result := &types.ChargeResult{Gateway: "stripe", Raw: body}
+
+	var r refund
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &r); err != nil {
+			return nil, fmt.Errorf("stripe: decode response: %w", err)
+		}
+	}
+	if resp.StatusCode >= 400 {
+		result.Status = gateway.HTTPStatus(resp.StatusCode)
+		if r.Error != nil {
+			result.DeclineCode = r.Error.Code
+			result.Message = r.Error.Message
+		}
+		return result, nil
+	}
+
+	result.TransactionID = r.ID
+	switch r.Status {
+	case "succeeded":
+		result.Status, result.Message = types.UPGStatusSuccess, "refund succeeded"
+	case "pending", "requires_action":
+		result.Status, result.Message = types.UPGStatusAccepted, "refund pending"
+	case "failed", "canceled":
+		result.Status, result.Message = types.UPGStatusRejected, "refund failed"
+		result.DeclineCode = r.FailureReason
+	default:
+		result.Status = types.UPGStatusFatalFailure
+		result.Message = "unexpected refund status " + strconv.Quote(r.Status)
+	}
+	return result, nil
+}
+
+// parseSetupResponse maps Stripe's response to a SetupIntent to a result.
+func parseSetupResponse(resp *processor.SendResponse) (*types.ChargeResult, error) {
+	body := gateway.RelayBody(resp.Body)
+	result := &types.ChargeResult{Gateway: "stripe", Raw: body}
+
+	var si setupIntent
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &si); err != nil {
+			return nil, fmt.Errorf("stripe: decode response: %w", err)
+		}
+	}
+	if resp.StatusCode >= 400 {
+		result.Status = gateway.HTTPStatus(resp.StatusCode)
+		if si.Error != nil {
+			result.DeclineCode = firstNonEmpty(si.Error.DeclineCode, si.Error.Code)
+			result.Message = si.Error.Message
+		}
+		return result, nil
+	}
+
+	result.TransactionID = si.ID
+	switch si.Status {
+	case "succeeded":
+		result.Status, result.Message = types.UPGStatusSuccess, "card verified"
+	case "requires_action", "processing":
+		result.Status, result.Message = types.UPGStatusAccepted, "card verification pending"
+	case "requires_payment_method", "canceled":
+		result.Status, result.Message = types.UPGStatusRejected, "card verification failed"
+	default:
+		result.Status = types.UPGStatusFatalFailure
+		result.Message = "unexpected setup intent status " + strconv.Quote(si.Status)
+	}
+	if si.LastSetupError != nil {
+		result.DeclineCode = firstNonEmpty(si.LastSetupError.DeclineCode, si.LastSetupError.Code)
+		result.Message = si.LastSetupError.Message
+	}
+	return result, nil
+}
+
+// intentStatus maps a PaymentIntent status to a UPG status.
+func intentStatus(pi paymentIntent) (types.UPGStatus, string) {
+	switch pi.Status {
+	case "succeeded":
+		return types.UPGStatusSuccess, "payment succeeded"
+	case "requires_capture":
+		return types.UPGStatusAccepted, "payment authorized"
+	case "requires_action":
+		return types.UPGStatusAccepted, "3DS authentication required"
+	case "processing":
+		return types.UPGStatusAccepted, "payment processing"
+	case "requires_payment_method", "canceled":
+		return types.UPGStatusRejected, "payment failed"
+	}
+	return types.UPGStatusFatalFailure, "unexpected payment intent status " + strconv.Quote(pi.Status)
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,230 @@
+package stripe_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway/gatewaytest"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway/stripe"
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+)
+
+var testCard = gatewaytest.Card{
+	Number:          "4242424242424242",
+	ExpirationMonth: "12",
+	ExpirationYear:  "2030",
+	CardholderName:  "JOHN DOE",
+}
+
+// stubStripe serves POST /v1/payment_intents, checking the request and
+// replying with status and body.
+func stubStripe(t *testing.T, status int, body any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/payment_intents" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer sk_test_hotel" {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("parse form: %v", err)
+		}
+		if r.PostForm.Get("payment_method_data[card][number]") != testCard.Number {
+			t.Errorf("card number not substituted: %q", r.PostForm.Get("payment_method_data[card][number]"))
+		}
+		if r.PostForm.Get("amount") != "1050" || r.PostForm.Get("currency") != "eur" {
+			t.Errorf("unexpected amount/currency %q %q", r.PostForm.Get("amount"), r.PostForm.Get("currency"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}))
+}
+
+func charge(t *testing.T, srv *httptest.Server, vault string) (*types.ChargeResult, *gatewaytest.Relay) {
+	t.Helper()
+	relay := gatewaytest.NewRelay(vault, testCard)
+	result, err := stripe.New(srv.URL).Charge(context.Background(), relay, gateway.ChargeRequest{
+		CardToken:   "tok_abc",
+		Amount:      money.MustParse("10.50", "EUR"),
+		Reference:   "res-1",
+		Credentials: map[string]string{stripe.CredentialSecretKey: "sk_test_hotel"},
+	})
+	if err != nil {
+		t.Fatalf("Charge: %v", err)
+	}
+	return result, relay
+}
+
+func TestCharge_Succeeded(t *testing.T) {
+	srv := stubStripe(t, http.StatusOK, map[string]any{"id": "pi_123", "status": "succeeded"})
+	defer srv.Close()
+
+	result, relay := charge(t, srv, "vaultera")
+	if result.Status != types.UPGStatusSuccess || result.TransactionID != "pi_123" {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if !strings.Contains(relay.LastRequest.Body, "=%CARD_NUMBER%") {
+		t.Errorf("expected Vaultera placeholder in relayed body, got %q", relay.LastRequest.Body)
+	}
+	if relay.LastRequest.Headers["Idempotency-Key"] != "res-1" {
+		t.Errorf("expected Idempotency-Key from reference")
+	}
+}
+
+func TestCharge_PCIBookingPlaceholders(t *testing.T) {
+	srv := stubStripe(t, http.StatusOK, map[string]any{"id": "pi_123", "status": "succeeded"})
+	defer srv.Close()
+
+	_, relay := charge(t, srv, "pcibooking")
+	if !strings.Contains(relay.LastRequest.Body, "={{{CardNumber}}}") {
+		t.Errorf("expected PCI Booking placeholder in relayed body, got %q", relay.LastRequest.Body)
+	}
+}
+
+func TestCharge_Declined(t *testing.T) {
+	srv := stubStripe(t, http.StatusPaymentRequired, map[string]any{
+		"error": map[string]any{
+			"type":           "card_error",
+			"code":           "card_declined",
+			"decline_code":   "insufficient_funds",
+			"message":        "Your card has insufficient funds.",
+			"payment_intent": map[string]any{"id": "pi_declined"},
+		},
+	})
+	defer srv.Close()
+
+	result, _ := charge(t, srv, "vaultera")
+	if result.Status != types.UPGStatusRejected {
+		t.Errorf("expected Rejected, got %s", result.Status)
+	}
+	if result.DeclineCode != "insufficient_funds" || result.TransactionID != "pi_declined" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestCharge_StatusMapping(t *testing.T) {
+	tests := []struct {
+		status int
+		body   map[string]any
+		want   types.UPGStatus
+	}{
+		{http.StatusOK, map[string]any{"id": "pi", "status": "requires_action"}, types.UPGStatusAccepted},
+		{http.StatusOK, map[string]any{"id": "pi", "status": "requires_capture"}, types.UPGStatusAccepted},
+		{http.StatusOK, map[string]any{"id": "pi", "status": "requires_payment_method"}, types.UPGStatusRejected},
+		{http.StatusUnauthorized, map[string]any{"error": map[string]any{"type": "invalid_request_error"}}, types.UPGStatusFatalFailure},
+		{http.StatusTooManyRequests, map[string]any{"error": map[string]any{"type": "rate_limit_error"}}, types.UPGStatusTemporaryFailure},
+		{http.StatusInternalServerError, map[string]any{"error": map[string]any{"type": "api_error"}}, types.UPGStatusTemporaryFailure},
+	}
+
+	for _, tc := range tests {
+		srv := stubStripe(t, tc.status, tc.body)
+		result, _ := charge(t, srv, "vaultera")
+		srv.Close()
+		if result.Status != tc.want {
+			t.Errorf("status %d %v: expected %s, got %s", tc.status, tc.body, tc.want, result.Status)
+		}
+	}
+}
+
+func TestCharge_MissingSecretKey(t *testing.T) {
+	_, err := stripe.New("").Charge(context.Background(), gatewaytest.NewRelay("vaultera", testCard), gateway.ChargeRequest{})
+	if !errors.Is(err, gateway.ErrMissingCredential) {
+		t.Errorf("expected ErrMissingCredential, got %v", err)
+	}
+}
+
+func TestRefund(t *testing.T) {
+	tests := []struct {
+		status int
+		body   map[string]any
+		want   types.UPGStatus
+	}{
+		{http.StatusOK, map[string]any{"id": "re_1", "status": "succeeded"}, types.UPGStatusSuccess},
+		{http.StatusOK, map[string]any{"id": "re_1", "status": "pending"}, types.UPGStatusAccepted},
+		{http.StatusOK, map[string]any{"id": "re_1", "status": "failed", "failure_reason": "expired_or_canceled_card"}, types.UPGStatusRejected},
+		{http.StatusBadRequest, map[string]any{"error": map[string]any{"code": "charge_already_refunded", "message": "already refunded"}}, types.UPGStatusFatalFailure},
+	}
+
+	for _, tc := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.URL.Path != "/v1/refunds" {
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			}
+			r.ParseForm()
+			if r.PostForm.Get("payment_intent") != "pi_123" || r.PostForm.Get("amount") != "400" {
+				t.Errorf("unexpected refund form %v", r.PostForm)
+			}
+			w.WriteHeader(tc.status)
+			json.NewEncoder(w).Encode(tc.body)
+		}))
+		relay := gatewaytest.NewRelay("vaultera", testCard)
+		result, err := stripe.New(srv.URL).Refund(context.Background(), relay, gateway.RefundRequest{
+			CardToken:     "tok_abc",
+			TransactionID: "pi_123",
+			Amount:        money.MustParse("4.00", "EUR"),
+			Reference:     "refund-1",
+			Credentials:   map[string]string{stripe.CredentialSecretKey: "sk_test_hotel"},
+		})
+		srv.Close()
+		if err != nil {
+			t.Fatalf("Refund: %v", err)
+		}
+		if result.Status != tc.want {
+			t.Errorf("%v: expected %s, got %s", tc.body, tc.want, result.Status)
+		}
+		if relay.LastRequest.Headers["Idempotency-Key"] != "refund-1" {
+			t.Errorf("expected Idempotency-Key from reference")
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		status int
+		body   map[string]any
+		want   types.UPGStatus
+	}{
+		{http.StatusOK, map[string]any{"id": "seti_1", "status": "succeeded"}, types.UPGStatusSuccess},
+		{http.StatusOK, map[string]any{"id": "seti_1", "status": "requires_action"}, types.UPGStatusAccepted},
+		{http.StatusPaymentRequired, map[string]any{"error": map[string]any{"code": "card_declined", "decline_code": "do_not_honor"}}, types.UPGStatusRejected},
+		{http.StatusUnauthorized, map[string]any{"error": map[string]any{"type": "invalid_request_error", "message": "Invalid API Key provided"}}, types.UPGStatusFatalFailure},
+	}
+
+	for _, tc := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.URL.Path != "/v1/setup_intents" {
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			}
+			r.ParseForm()
+			if r.PostForm.Get("confirm") != "true" || r.PostForm.Get("payment_method_data[card][number]") != testCard.Number {
+				t.Errorf("unexpected setup intent form %v", r.PostForm)
+			}
+			if r.PostForm.Has("amount") {
+				t.Error("expected no amount in a verification")
+			}
+			w.WriteHeader(tc.status)
+			json.NewEncoder(w).Encode(tc.body)
+		}))
+		relay := gatewaytest.NewRelay("vaultera", testCard)
+		result, err := stripe.New(srv.URL).Verify(context.Background(), relay, gateway.VerifyRequest{
+			CardToken:   "tok_abc",
+			Credentials: map[string]string{stripe.CredentialSecretKey: "sk_test_hotel"},
+		})
+		srv.Close()
+		if err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if result.Status != tc.want {
+			t.Errorf("%v: expected %s, got %s", tc.body, tc.want, result.Status)
+		}
+	}
+}
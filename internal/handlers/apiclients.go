@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/apiclients"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// APIClientHandler manages the API clients that call /v1 with their own key,
+// under /v1/admin/clients.
+type APIClientHandler struct {
+	store apiclients.Store
+}
+
+// NewAPIClientHandler creates an APIClientHandler. A nil store makes every
+// endpoint respond 503, for deployments without a database.
+func NewAPIClientHandler(s apiclients.Store) *APIClientHandler {
+	return &APIClientHandler{store: s}
+}
+
+// apiClientRequest is the body of POST /v1/admin/clients.
+type apiClientRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// Create handles POST /v1/admin/clients. The response is the only time the
+// client's key is returned.
+func (h *APIClientHandler) Create(c *fiber.Ctx) error {
+	if h.store == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "API client store unavailable")
+	}
+	var req apiClientRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Scopes) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "name and scopes are required")
+	}
+	for _, scope := range req.Scopes {
+		if !apiclients.ValidScope(scope) {
+			return fiber.NewError(fiber.StatusBadRequest, "unknown scope "+scope+" (supported: "+strings.Join(apiclients.Scopes, ", ")+")")
+		}
+	}
+
+	key, hash, err := apiclients.NewKey()
+	if err != nil {
+		return err
+	}
+	client := &apiclients.Client{ID: uuid.NewString(), Name: req.Name, Scopes: req.Scopes, KeyPrefix: apiclients.Prefix(key)}
+	err = h.store.Create(c.Context(), client, hash)
+	if errors.Is(err, apiclients.ErrNameTaken) {
+		return fiber.NewError(fiber.StatusConflict, "an API client named "+req.Name+" already exists; revoke it first")
+	}
+	if err != nil {
+		return apiClientError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"client": client, "key": key})
+}
+
+// List handles GET /v1/admin/clients.
+func (h *APIClientHandler) List(c *fiber.Ctx) error {
+	if h.store == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "API client store unavailable")
+	}
+	clients, err := h.store.List(c.Context())
+	if err != nil {
+		return apiClientError(err)
+	}
+	return c.JSON(fiber.Map{"clients": clients})
+}
+
+// Revoke handles DELETE /v1/admin/clients/:clientId. The client's key is
+// rejected from then on.
+func (h *APIClientHandler) Revoke(c *fiber.Ctx) error {
+	if h.store == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "API client store unavailable")
+	}
+	id := c.Params("clientId")
+	if _, err := uuid.Parse(id); err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "API client not found")
+	}
+	if err := h.store.Revoke(c.Context(), id); err != nil {
+		return apiClientError(err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// apiClientError maps API client store errors to responses.
+func apiClientError(err error) error {
+	if errors.Is(err, apiclients.ErrNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, "API client not found")
+	}
+	log.Printf("apiclients: %v", err)
+	return fiber.NewError(fiber.StatusServiceUnavailable, "API client store unavailable")
+}
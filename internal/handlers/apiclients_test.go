@@ -0,0 +1,61 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/apiclients"
+	"github.com/CentraGlobal/backend-payment-go/internal/handlers"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAPIClients_CreateListRevoke(t *testing.T) {
+	store := apiclients.NewMemoryStore()
+	h := handlers.NewAPIClientHandler(store)
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Post("/v1/admin/clients", h.Create)
+	app.Get("/v1/admin/clients", h.List)
+	app.Delete("/v1/admin/clients/:clientId", h.Revoke)
+
+	status, created := doJSON(t, app, http.MethodPost, "/v1/admin/clients",
+		`{"name":"obe-frontend","scopes":["session:create","cards:create"]}`, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %v", status, created)
+	}
+	key, _ := created["key"].(string)
+	client, _ := created["client"].(map[string]any)
+	if !strings.HasPrefix(key, "pk_") || !strings.HasPrefix(key, client["key_prefix"].(string)) {
+		t.Fatalf("expected a key matching its prefix, got %q and %v", key, client)
+	}
+	if got, err := store.FindByKeyHash(t.Context(), apiclients.HashKey(key)); err != nil || got.Name != "obe-frontend" {
+		t.Errorf("expected the key to find the client, got %+v, %v", got, err)
+	}
+
+	_, list := doJSON(t, app, http.MethodGet, "/v1/admin/clients", "", nil)
+	clients, _ := list["clients"].([]any)
+	if listed, _ := json.Marshal(list); len(clients) != 1 || strings.Contains(string(listed), key) {
+		t.Errorf("expected one client listed without its key, got %v", list)
+	}
+
+	path := "/v1/admin/clients/" + client["id"].(string)
+	if status, _ := doJSON(t, app, http.MethodDelete, path, "", nil); status != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodDelete, path, "", nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 for a revoked client, got %d", status)
+	}
+
+	body := `{"name":"obe-frontend","scopes":["session:create"]}`
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/admin/clients", body, nil); status != http.StatusCreated {
+		t.Errorf("expected a revoked client's name to be reusable, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/admin/clients", body, nil); status != http.StatusConflict {
+		t.Errorf("expected 409 for the name of an unrevoked client, got %d", status)
+	}
+
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/admin/clients", `{"name":"x","scopes":["cards:everything"]}`, nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown scope, got %d", status)
+	}
+}
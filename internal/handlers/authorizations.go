@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/ledger"
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// authorizeRequest is the body of POST /v1/payments/authorize.
+type authorizeRequest struct {
+	CardToken     string      `json:"card_token"`
+	CredentialsID string      `json:"credentials_id"`
+	GatewayName   string      `json:"gateway_name"`
+	Amount        json.Number `json:"amount"`
+	Currency      string      `json:"currency"`
+
+	ReservationNumber string `json:"reservation_number,omitempty"`
+	IncludeRaw        bool   `json:"include_raw,omitempty"`
+}
+
+// captureRequest is the optional body of POST /v1/payments/:id/capture and
+// /void. Amount, in the authorization's currency, defaults to the full
+// authorized amount and is ignored by void.
+type captureRequest struct {
+	Amount     json.Number `json:"amount,omitempty"`
+	IncludeRaw bool        `json:"include_raw,omitempty"`
+}
+
+// Authorize handles POST /v1/payments/authorize: a UPG pre-authorization that
+// places a hold on the card to be captured or voided later. The ledger tracks
+// the authorization so captures cannot exceed it, so Authorize is unavailable
+// without one.
+func (h *PaymentHandler) Authorize(c *fiber.Ctx) error {
+	if err := h.requireLedger(); err != nil {
+		return err
+	}
+	var req authorizeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	if req.CardToken == "" || req.CredentialsID == "" || req.GatewayName == "" || req.Currency == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "card_token, credentials_id, gateway_name, and currency are required")
+	}
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	proc, err := h.processorFor(c)
+	if err != nil {
+		return err
+	}
+	authorizer, err := processor.As[processor.UPGAuthorizer](proc, processor.CapabilityUPGAuthorize)
+	if err != nil {
+		return err
+	}
+
+	txn := &ledger.Transaction{
+		Operation:         ledger.OperationAuthorize,
+		Mode:              ledger.ModeUPG,
+		Processor:         proc.Name(),
+		Gateway:           req.GatewayName,
+		CredentialsID:     req.CredentialsID,
+		AmountMinor:       amount.Minor,
+		Currency:          amount.Currency.Code,
+		CardToken:         req.CardToken,
+		ReservationNumber: req.ReservationNumber,
+	}
+	if err := h.beginTransaction(c, txn); err != nil {
+		return err
+	}
+
+	resp, err := authorizer.PreAuthorizeUPG(c.Context(), processor.UPGChargeRequest{
+		CardToken:     req.CardToken,
+		Amount:        amount,
+		GatewayName:   req.GatewayName,
+		CredentialsID: req.CredentialsID,
+	})
+	if err != nil {
+		h.failTransaction(c, txn, err)
+		return processorError(proc, err)
+	}
+	result := upgResult(resp, req.GatewayName, proc)
+	h.completeTransaction(c, txn, result)
+	return c.JSON(chargeResult(result, amount, req.IncludeRaw))
+}
+
+// Capture handles POST /v1/payments/:id/capture, capturing all or part of the
+// authorization with ledger ID :id. Captures of an authorization may not
+// exceed its amount in total.
+func (h *PaymentHandler) Capture(c *fiber.Ctx) error {
+	return h.followUp(c, ledger.OperationCapture)
+}
+
+// Void handles POST /v1/payments/:id/void, releasing the uncaptured part of
+// the authorization with ledger ID :id.
+func (h *PaymentHandler) Void(c *fiber.Ctx) error {
+	return h.followUp(c, ledger.OperationVoid)
+}
+
+// followUp sends a capture or void of the authorization named by :id through
+// the processor that made it.
+func (h *PaymentHandler) followUp(c *fiber.Ctx, op ledger.Operation) error {
+	var req captureRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		}
+	}
+
+	auth, err := h.transaction(c)
+	if err != nil {
+		return err
+	}
+
+	var amount money.Money
+	if op == ledger.OperationCapture {
+		if req.Amount == "" {
+			amount, err = money.New(auth.AmountMinor, auth.Currency)
+		} else {
+			amount, err = parseAmount(req.Amount, auth.Currency)
+		}
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+
+	proc, err := h.processorFor(c)
+	if err != nil {
+		return err
+	}
+	if proc.Name() != auth.Processor {
+		return fiber.NewError(fiber.StatusConflict, "authorization was made with processor "+auth.Processor)
+	}
+	authorizer, err := processor.As[processor.UPGAuthorizer](proc, processor.CapabilityUPGAuthorize)
+	if err != nil {
+		return err
+	}
+
+	txn := &ledger.Transaction{
+		Operation:         op,
+		ParentID:          auth.ID,
+		Mode:              ledger.ModeUPG,
+		Processor:         proc.Name(),
+		Gateway:           auth.Gateway,
+		CredentialsID:     auth.CredentialsID,
+		AmountMinor:       amount.Minor,
+		Currency:          auth.Currency,
+		CardToken:         auth.CardToken,
+		ReservationNumber: auth.ReservationNumber,
+	}
+	if err := h.beginTransaction(c, txn); err != nil {
+		return err
+	}
+
+	upgReq := processor.UPGTransactionRequest{
+		TransactionID: auth.TransactionID,
+		Amount:        amount,
+		GatewayName:   auth.Gateway,
+		CredentialsID: auth.CredentialsID,
+	}
+	var resp *processor.UPGChargeResponse
+	if op == ledger.OperationCapture {
+		resp, err = authorizer.CaptureUPG(c.Context(), upgReq)
+	} else {
+		resp, err = authorizer.VoidUPG(c.Context(), upgReq)
+	}
+	if err != nil {
+		// A capture that may have gone through is recorded as unknown and
+		// keeps counting against the authorization.
+		h.failTransaction(c, txn, err)
+		return processorError(proc, err)
+	}
+	result := upgResult(resp, auth.Gateway, proc)
+	h.completeTransaction(c, txn, result)
+	return c.JSON(chargeResult(result, amount, req.IncludeRaw))
+}
+
+// upgResult normalizes a UPG operation's response.
+func upgResult(resp *processor.UPGChargeResponse, gatewayName string, proc processor.Processor) *types.ChargeResult {
+	return &types.ChargeResult{
+		Status:        types.UPGStatus(resp.Status),
+		TransactionID: resp.TransactionID,
+		Message:       resp.Message,
+		Gateway:       gatewayName,
+		Processor:     proc.Name(),
+		Raw:           resp.Raw,
+	}
+}
@@ -0,0 +1,176 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/handlers"
+	"github.com/CentraGlobal/backend-payment-go/internal/ledger"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/gofiber/fiber/v2"
+)
+
+func setupAuthorizationApp(mock *mockUPGProcessor) *fiber.App {
+	ph := handlers.NewPaymentHandler(processor.Static(mock), handlers.WithLedger(ledger.NewMemoryStore()))
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	payments := app.Group("/v1/payments")
+	payments.Post("/authorize", ph.Authorize)
+	payments.Post("/:id/capture", ph.Capture)
+	payments.Post("/:id/void", ph.Void)
+	payments.Get("/transactions", ph.ListTransactions)
+	return app
+}
+
+const authorizeBody = `{"card_token":"tok_1","amount":80.00,"currency":"EUR","gateway_name":"Stripe","credentials_id":"creds-1"}`
+
+func authorize(t *testing.T, app *fiber.App) string {
+	t.Helper()
+	status, result := doJSON(t, app, http.MethodPost, "/v1/payments/authorize", authorizeBody, nil)
+	if status != http.StatusOK {
+		t.Fatalf("authorize: expected 200, got %d: %v", status, result)
+	}
+	id, _ := result["id"].(string)
+	if id == "" || result["status"] != "Success" || result["amount"] != 80.0 {
+		t.Fatalf("authorize: unexpected result %v", result)
+	}
+	return id
+}
+
+func TestCapture_PartialThenRemainder(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success", TransactionID: "auth_1"}}
+	app := setupAuthorizationApp(mock)
+	id := authorize(t, app)
+
+	status, result := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/capture", `{"amount":50.00}`, nil)
+	if status != http.StatusOK || result["amount"] != 50.0 || result["currency"] != "EUR" {
+		t.Fatalf("partial capture: %d %v", status, result)
+	}
+	if mock.lastTxn.TransactionID != "auth_1" || mock.lastTxn.CredentialsID != "creds-1" || mock.lastTxn.GatewayName != "Stripe" {
+		t.Errorf("expected capture of the authorization's gateway transaction, got %+v", mock.lastTxn)
+	}
+	if mock.lastTxn.Amount.Minor != 5000 {
+		t.Errorf("expected 5000 minor units captured, got %d", mock.lastTxn.Amount.Minor)
+	}
+
+	status, result = doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/capture", `{"amount":30.01}`, nil)
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for capture beyond the authorization, got %d: %v", status, result)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/capture", `{"amount":30.00}`, nil); status != http.StatusOK {
+		t.Fatalf("expected remaining 30.00 to be capturable, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/void", "", nil); status != http.StatusConflict {
+		t.Errorf("expected 409 voiding a fully captured authorization, got %d", status)
+	}
+
+	_, list := doJSON(t, app, http.MethodGet, "/v1/payments/transactions?parent_id="+id+"&operation=capture", "", nil)
+	if txns, _ := list["transactions"].([]any); len(txns) != 2 {
+		t.Errorf("expected 2 recorded captures, the refused one unrecorded, got %v", list["transactions"])
+	}
+}
+
+func TestCapture_DefaultsToFullAmount(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success"}}
+	app := setupAuthorizationApp(mock)
+	id := authorize(t, app)
+
+	status, result := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/capture", "", nil)
+	if status != http.StatusOK || result["amount_minor"] != 8000.0 {
+		t.Fatalf("expected full capture of 80.00, got %d %v", status, result)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/capture", `{"amount":0.01}`, nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 after full capture, got %d", status)
+	}
+}
+
+func TestCapture_TimedOut_StillCountsAgainstAuthorization(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success", TransactionID: "auth_1"}}
+	app := setupAuthorizationApp(mock)
+	id := authorize(t, app)
+
+	mock.err = processor.TransportError("mock", context.DeadlineExceeded)
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/capture", "", nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for a timed-out capture, got %d", status)
+	}
+	mock.err = nil
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/capture", `{"amount":0.01}`, nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 capturing after a timed-out full capture, got %d", status)
+	}
+
+	_, list := doJSON(t, app, http.MethodGet, "/v1/payments/transactions?parent_id="+id+"&status=Unknown", "", nil)
+	if txns, _ := list["transactions"].([]any); len(txns) != 1 {
+		t.Errorf("expected the timed-out capture recorded as Unknown, got %v", list["transactions"])
+	}
+}
+
+func TestVoid_BlocksLaterCaptures(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success"}}
+	app := setupAuthorizationApp(mock)
+	id := authorize(t, app)
+
+	status, result := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/void", "", nil)
+	if status != http.StatusOK || result["status"] != "Success" {
+		t.Fatalf("void: %d %v", status, result)
+	}
+	if _, ok := result["amount"]; ok {
+		t.Errorf("expected no amount on void, got %v", result)
+	}
+
+	calls := mock.calls
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/capture", "", nil); status != http.StatusConflict {
+		t.Errorf("expected 409 capturing a voided authorization, got %d", status)
+	}
+	if mock.calls != calls {
+		t.Error("expected refused capture not to reach the processor")
+	}
+}
+
+func TestCapture_RejectedAuthorization_Returns409(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Rejected"}}
+	app := setupAuthorizationApp(mock)
+
+	_, result := doJSON(t, app, http.MethodPost, "/v1/payments/authorize", authorizeBody, nil)
+	id, _ := result["id"].(string)
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/capture", "", nil); status != http.StatusConflict {
+		t.Errorf("expected 409 capturing a rejected authorization, got %d", status)
+	}
+}
+
+func TestCapture_NotAnAuthorization(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success"}}
+	store := ledger.NewMemoryStore()
+	ph := handlers.NewPaymentHandler(processor.Static(mock), handlers.WithLedger(store))
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Post("/v1/payments/charge", ph.Charge)
+	app.Post("/v1/payments/:id/capture", ph.Capture)
+
+	body := `{"card_token":"tok_1","amount":1,"currency":"USD","gateway_name":"Stripe","credentials_id":"c"}`
+	_, result := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, nil)
+	id, _ := result["id"].(string)
+
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/capture", "", nil); status != http.StatusConflict {
+		t.Errorf("expected 409 capturing a charge, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/not-a-uuid/capture", "", nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown transaction, got %d", status)
+	}
+}
+
+func TestAuthorize_Validation(t *testing.T) {
+	app := setupAuthorizationApp(&mockUPGProcessor{})
+
+	status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/authorize", `{"card_token":"tok_1","amount":1,"currency":"USD"}`, nil)
+	if status != http.StatusBadRequest {
+		t.Errorf("expected 400 without credentials, got %d", status)
+	}
+
+	caps := &mockUPGProcessor{caps: processor.Capabilities{processor.CapabilityUPGCharge}}
+	app = setupAuthorizationApp(caps)
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/authorize", authorizeBody, nil); status != http.StatusNotImplemented {
+		t.Errorf("expected 501 without upg_authorize, got %d", status)
+	}
+	if caps.calls != 0 {
+		t.Error("expected unsupported authorize not to reach the processor")
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetCapabilities handles GET /v1/capabilities.
+// Returns the processor resolved for the request's property and the operations
+// it supports, so callers can decide on a payment flow before attempting it.
+func (h *PaymentHandler) GetCapabilities(c *fiber.Ctx) error {
+	proc, err := h.processorFor(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"processor":    proc.Name(),
+		"capabilities": proc.Capabilities(),
+	})
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/credentials"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway"
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// testCardNumber is the card tokenized for connection tests when the caller
+// does not supply one. It is a well-known test number: test-mode gateways
+// approve it and live-mode gateways decline it, and either shows that the
+// gateway accepted the credentials.
+const testCardNumber = "4111111111111111"
+
+// connectionTestRequest is the optional body of
+// POST /v1/properties/:propertyId/credentials/:credId/test.
+type connectionTestRequest struct {
+	// CardToken is a vaulted test card to verify with; by default a test
+	// card is tokenized for the test and deleted afterwards.
+	CardToken string `json:"card_token,omitempty"`
+	// CredentialsID overrides the processor's ID for UPG credentials; by
+	// default the credential is tested under its own registration, which is
+	// made if needed.
+	CredentialsID string `json:"credentials_id,omitempty"`
+	// Currency of the zero-amount UPG authorization; defaults to USD.
+	Currency string `json:"currency,omitempty"`
+}
+
+// verifyFunc runs a connection test with a vaulted card.
+type verifyFunc func(ctx context.Context, cardToken string) (*types.ChargeResult, error)
+
+// connectionTestResponse is the recorded test result with the gateway's
+// outcome, when there was one.
+type connectionTestResponse struct {
+	credentials.TestResult
+	Status      types.UPGStatus `json:"status,omitempty"`
+	DeclineCode string          `json:"decline_code,omitempty"`
+	Gateway     string          `json:"gateway"`
+	Processor   string          `json:"processor"`
+}
+
+// Test handles POST /v1/properties/:propertyId/credentials/:credId/test. It
+// checks a stored credential against its gateway without moving money: UPG
+// credentials with a zero-amount pre-authorization, relay credentials with
+// the gateway adapter's verification call. The pass/fail result and reason
+// are recorded on the credential and returned with 200; the request fails
+// only when the test cannot be run at all.
+func (h *CredentialHandler) Test(c *fiber.Ctx) error {
+	property, id, err := h.credentialID(c)
+	if err != nil {
+		return err
+	}
+	var req connectionTestRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		}
+	}
+
+	cred, err := h.service.Get(c.Context(), property, id)
+	if err != nil {
+		return credentialError(c, err)
+	}
+	proc, err := resolveProcessor(c, h.resolver)
+	if err != nil {
+		return err
+	}
+
+	var verify verifyFunc
+	switch cred.Mode {
+	case credentials.ModeUPG:
+		verify, err = h.upgVerifier(proc, cred, req)
+	case credentials.ModeRelay:
+		verify, err = h.relayVerifier(proc, cred)
+	default:
+		err = fiber.NewError(fiber.StatusConflict, "credential has unknown mode "+string(cred.Mode))
+	}
+	if err != nil {
+		return err
+	}
+
+	cardToken := req.CardToken
+	if cardToken == "" {
+		if err := processor.Require(proc, processor.CapabilityTokenize); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "card_token is required: processor "+proc.Name()+" cannot tokenize a test card")
+		}
+		card, err := proc.CreateCard(c.Context(), processor.Card{
+			CardNumber:      testCardNumber,
+			CardholderName:  "CONNECTION TEST",
+			ExpirationMonth: "12",
+			ExpirationYear:  strconv.Itoa(time.Now().Year() + 3),
+		})
+		if err != nil {
+			return processorError(proc, err)
+		}
+		cardToken = card.CardToken
+		if proc.Capabilities().Has(processor.CapabilityCardDelete) {
+			defer func() {
+				if err := proc.DeleteCard(c.Context(), cardToken); err != nil {
+					log.Printf("credentials: delete test card: %v", err)
+				}
+			}()
+		}
+	}
+
+	resp := connectionTestResponse{Gateway: cred.Gateway, Processor: proc.Name()}
+	result, err := verify(c.Context(), cardToken)
+	resp.TestResult = testResult(result, err)
+	if result != nil {
+		resp.Status, resp.DeclineCode = result.Status, result.DeclineCode
+	}
+	if err := h.service.RecordTest(c.Context(), property, id, resp.TestResult); err != nil {
+		log.Printf("credentials: record test of %s: %v", id, err)
+	}
+	return c.JSON(resp)
+}
+
+// upgVerifier tests UPG credentials with a zero-amount pre-authorization.
+func (h *CredentialHandler) upgVerifier(proc processor.Processor, cred *credentials.Credential, req connectionTestRequest) (verifyFunc, error) {
+	if req.Currency == "" {
+		req.Currency = "USD"
+	}
+	amount, err := money.New(0, req.Currency)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	authorizer, err := processor.As[processor.UPGAuthorizer](proc, processor.CapabilityUPGAuthorize)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, cardToken string) (*types.ChargeResult, error) {
+		credentialsID := req.CredentialsID
+		if credentialsID == "" {
+			var err error
+			if credentialsID, err = h.service.CredentialsID(ctx, proc, cred); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := authorizer.PreAuthorizeUPG(ctx, processor.UPGChargeRequest{
+			CardToken:     cardToken,
+			Amount:        amount,
+			GatewayName:   cred.Gateway,
+			CredentialsID: credentialsID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return upgResult(resp, cred.Gateway, proc), nil
+	}, nil
+}
+
+// relayVerifier tests relay credentials with the verification call of the
+// gateway adapter named by the credential.
+func (h *CredentialHandler) relayVerifier(proc processor.Processor, cred *credentials.Credential) (verifyFunc, error) {
+	if h.gateways == nil {
+		return nil, fiber.NewError(fiber.StatusNotImplemented, "no gateway adapters are configured")
+	}
+	adapter, err := h.gateways.Get(cred.Gateway)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusConflict, "credential is for unknown gateway adapter "+cred.Gateway)
+	}
+	verifier, ok := adapter.(gateway.Verifier)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusNotImplemented, "connection tests are not supported for gateway "+adapter.Name())
+	}
+	relay, err := processor.As[processor.Relayer](proc, processor.CapabilityRelay)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, cardToken string) (*types.ChargeResult, error) {
+		return verifier.Verify(ctx, relay, gateway.VerifyRequest{CardToken: cardToken, Credentials: cred.Secrets})
+	}, nil
+}
+
+// testResult turns the outcome of a verification into a test result. The test
+// passes when the gateway accepted the credentials, even if it declined the
+// test card; failures and temporary errors, such as a rejected API key, fail
+// it. Processor failures are reported by category without upstream detail,
+// which is logged instead.
+func testResult(result *types.ChargeResult, err error) credentials.TestResult {
+	r := credentials.TestResult{TestedAt: time.Now().UTC()}
+	switch {
+	case errors.Is(err, gateway.ErrMissingCredential):
+		r.Reason = strings.TrimPrefix(err.Error(), "gateway: ")
+	case err != nil:
+		log.Printf("credentials: connection test: %v", err)
+		r.Reason = "The payment processor returned an error."
+		for _, m := range processorErrors {
+			if errors.Is(err, m.kind) {
+				r.Reason = m.message
+				break
+			}
+		}
+	case result.Status == types.UPGStatusSuccess || result.Status == types.UPGStatusAccepted:
+		r.Passed = true
+		r.Reason = result.Message
+	case result.Status == types.UPGStatusRejected:
+		r.Passed = true
+		r.Reason = "credentials accepted; the test card was declined"
+		if d := firstNonEmpty(result.DeclineCode, result.Message); d != "" {
+			r.Reason += " (" + d + ")"
+		}
+	default:
+		r.Reason = firstNonEmpty(result.Message, result.DeclineCode, "gateway returned "+string(result.Status))
+		if result.DeclineCode != "" && result.Message != "" {
+			r.Reason += " (" + result.DeclineCode + ")"
+		}
+	}
+	return r
+}
@@ -0,0 +1,99 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+)
+
+func TestConnectionTest_Relay(t *testing.T) {
+	mock := &mockUPGProcessor{}
+	adapter := &stubAdapter{verified: types.UPGStatusSuccess}
+	app := setupCredentialApp(t, mock, adapter)
+
+	_, created := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"mode":"relay","gateway":"stub","secrets":{"secret_key":"sk_1"}}`, nil)
+	path := "/v1/properties/7/credentials/" + created["id"].(string)
+
+	status, result := doJSON(t, app, http.MethodPost, path+"/test", "", nil)
+	if status != http.StatusOK || result["passed"] != true || result["status"] != "Success" || result["tested_at"] == nil {
+		t.Fatalf("expected a passing test, got %d %v", status, result)
+	}
+	if len(mock.created) != 1 || mock.created[0].CardNumber != "4111111111111111" {
+		t.Errorf("expected a test card to be tokenized, got %+v", mock.created)
+	}
+	if len(mock.deleted) != 1 || mock.deleted[0] != "tok_created" {
+		t.Errorf("expected the test card to be deleted, got %v", mock.deleted)
+	}
+
+	_, got := doJSON(t, app, http.MethodGet, path, "", nil)
+	if last, _ := got["last_test"].(map[string]any); last["passed"] != true || last["tested_at"] == nil {
+		t.Errorf("expected the result recorded on the credential, got %v", got)
+	}
+
+	adapter.verified = types.UPGStatusFatalFailure
+	status, result = doJSON(t, app, http.MethodPost, path+"/test", `{"card_token":"tok_mine"}`, nil)
+	if status != http.StatusOK || result["passed"] != false || result["reason"] != "verified (code)" {
+		t.Errorf("expected a failing test with the gateway's reason, got %d %v", status, result)
+	}
+	if len(mock.created) != 1 {
+		t.Error("expected the supplied card token to be used")
+	}
+	_, got = doJSON(t, app, http.MethodGet, path, "", nil)
+	if last, _ := got["last_test"].(map[string]any); last["passed"] != false {
+		t.Errorf("expected the failure recorded on the credential, got %v", got)
+	}
+}
+
+func TestConnectionTest_DeclineStillPasses(t *testing.T) {
+	app := setupCredentialApp(t, &mockUPGProcessor{}, &stubAdapter{verified: types.UPGStatusRejected})
+	_, created := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"mode":"relay","gateway":"stub","secrets":{"secret_key":"sk_1"}}`, nil)
+
+	_, result := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials/"+created["id"].(string)+"/test", "", nil)
+	if result["passed"] != true || result["decline_code"] != "code" {
+		t.Errorf("expected a declined test card to pass, got %v", result)
+	}
+}
+
+func TestConnectionTest_UPG(t *testing.T) {
+	mock := &mockUPGProcessor{structure: stripeStructure, charge: &processor.UPGChargeResponse{Status: "Success", Message: "ok"}}
+	app := setupCredentialApp(t, mock)
+	_, created := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"gateway":"Stripe","secrets":{"secret_key":"sk_1"}}`, nil)
+	path := "/v1/properties/7/credentials/" + created["id"].(string) + "/test"
+
+	status, result := doJSON(t, app, http.MethodPost, path, `{"currency":"EUR"}`, nil)
+	if status != http.StatusOK || result["passed"] != true {
+		t.Fatalf("expected a passing test, got %d %v", status, result)
+	}
+	if c := mock.lastCharge; c.Amount.Minor != 0 || c.Amount.Currency.Code != "EUR" || c.CredentialsID != "creds-1" || c.GatewayName != "Stripe" {
+		t.Errorf("expected a zero-amount authorization with the registered credentials, got %+v", c)
+	}
+
+	doJSON(t, app, http.MethodPost, path, `{"credentials_id":"creds-other"}`, nil)
+	if c := mock.lastCharge; c.CredentialsID != "creds-other" || c.Amount.Currency.Code != "USD" {
+		t.Errorf("expected the given credentials_id in USD, got %+v", c)
+	}
+
+	mock.err = processor.NewError(processor.ErrUpstreamUnavailable, "mock", errors.New("dial tcp: refused"))
+	status, result = doJSON(t, app, http.MethodPost, path, `{"credentials_id":"creds-1","card_token":"tok_1"}`, nil)
+	if status != http.StatusOK || result["passed"] != false {
+		t.Fatalf("expected a failing test, got %d %v", status, result)
+	}
+	if reason, _ := result["reason"].(string); reason == "" || reason == "dial tcp: refused" {
+		t.Errorf("expected a generic reason without upstream detail, got %q", reason)
+	}
+}
+
+func TestConnectionTest_Unsupported(t *testing.T) {
+	app := setupCredentialApp(t, &mockUPGProcessor{})
+	_, created := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"mode":"relay","gateway":"payzone","secrets":{"merchant_id":"m"}}`, nil)
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials/"+created["id"].(string)+"/test", "", nil); status != http.StatusConflict {
+		t.Errorf("expected 409 for a credential without an adapter, got %d", status)
+	}
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/credentials"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// CredentialHandler serves a property's stored gateway credentials under
+// /v1/properties/:propertyId/credentials. Secrets are write-only: responses
+// list the names of the stored fields but never their values.
+//
+// UPG credentials are validated against the gateway's credential structure,
+// fetched from the property's processor through schemas, and registered with
+// that processor so charges can name them by gateway alone.
+type CredentialHandler struct {
+	resolver processor.Resolver
+	service  *credentials.Service
+	schemas  *credentials.SchemaCache
+	gateways *gateway.Registry
+}
+
+// NewCredentialHandler creates a CredentialHandler. A nil service makes every
+// endpoint respond 503, for deployments without a database or master key.
+// gateways provides the adapters that test relay credentials.
+func NewCredentialHandler(r processor.Resolver, s *credentials.Service, schemas *credentials.SchemaCache, gateways *gateway.Registry) *CredentialHandler {
+	return &CredentialHandler{resolver: r, service: s, schemas: schemas, gateways: gateways}
+}
+
+// credentialRequest is the body of POST and PATCH
+// /v1/properties/:propertyId/credentials. Secret values may be given as JSON
+// strings, numbers or booleans and are stored as strings; null is the same
+// as "".
+type credentialRequest struct {
+	Mode    credentials.Mode           `json:"mode"`
+	Gateway string                     `json:"gateway"`
+	Label   *string                    `json:"label"`
+	Secrets map[string]json.RawMessage `json:"secrets"`
+}
+
+// Create handles POST /v1/properties/:propertyId/credentials.
+func (h *CredentialHandler) Create(c *fiber.Ctx) error {
+	property, err := h.property(c)
+	if err != nil {
+		return err
+	}
+	var req credentialRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	req.Gateway = strings.TrimSpace(req.Gateway)
+	if req.Gateway == "" || len(req.Secrets) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "gateway and secrets are required")
+	}
+	switch req.Mode {
+	case "":
+		req.Mode = credentials.ModeUPG
+	case credentials.ModeUPG, credentials.ModeRelay:
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "mode must be upg or relay")
+	}
+	secrets, err := secretValues(req.Secrets, false)
+	if err != nil {
+		return credentialError(c, err)
+	}
+
+	cred := &credentials.Credential{PropertyID: property, Mode: req.Mode, Gateway: req.Gateway, Secrets: secrets}
+	if req.Label != nil {
+		cred.Label = *req.Label
+	}
+	if err := h.validate(c, cred); err != nil {
+		return credentialError(c, err)
+	}
+	if err := h.service.Create(c.Context(), cred); err != nil {
+		return credentialError(c, err)
+	}
+	h.register(c, cred, nil)
+	return c.Status(fiber.StatusCreated).JSON(cred)
+}
+
+// List handles GET /v1/properties/:propertyId/credentials.
+func (h *CredentialHandler) List(c *fiber.Ctx) error {
+	property, err := h.property(c)
+	if err != nil {
+		return err
+	}
+	creds, err := h.service.List(c.Context(), property)
+	if err != nil {
+		return credentialError(c, err)
+	}
+	return c.JSON(fiber.Map{"credentials": creds})
+}
+
+// Get handles GET /v1/properties/:propertyId/credentials/:credId.
+func (h *CredentialHandler) Get(c *fiber.Ctx) error {
+	property, id, err := h.credentialID(c)
+	if err != nil {
+		return err
+	}
+	cred, err := h.service.Get(c.Context(), property, id)
+	if err != nil {
+		return credentialError(c, err)
+	}
+	return c.JSON(cred)
+}
+
+// Update handles PATCH /v1/properties/:propertyId/credentials/:credId. The
+// label is replaced when present; secrets are merged into the stored ones and
+// a field set to "" is removed, and the result is validated as a whole. The
+// mode and gateway cannot be changed.
+func (h *CredentialHandler) Update(c *fiber.Ctx) error {
+	property, id, err := h.credentialID(c)
+	if err != nil {
+		return err
+	}
+	var req credentialRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	if req.Gateway != "" || req.Mode != "" {
+		return fiber.NewError(fiber.StatusBadRequest, "mode and gateway cannot be changed; create a new credential instead")
+	}
+	secrets, err := secretValues(req.Secrets, true)
+	if err != nil {
+		return credentialError(c, err)
+	}
+
+	var prev *credentials.Registration
+	cred, err := h.service.Update(c.Context(), property, id, credentials.Update{
+		Label:   req.Label,
+		Secrets: secrets,
+		Check: func(cred *credentials.Credential) error {
+			prev = cred.Registration
+			return h.validate(c, cred)
+		},
+	})
+	if err != nil {
+		return credentialError(c, err)
+	}
+	if len(secrets) > 0 {
+		h.register(c, cred, prev)
+	}
+	return c.JSON(cred)
+}
+
+// Delete handles DELETE /v1/properties/:propertyId/credentials/:credId.
+func (h *CredentialHandler) Delete(c *fiber.Ctx) error {
+	property, id, err := h.credentialID(c)
+	if err != nil {
+		return err
+	}
+	cred, err := h.service.Get(c.Context(), property, id)
+	if err != nil {
+		return credentialError(c, err)
+	}
+	if err := h.service.Delete(c.Context(), property, id); err != nil {
+		return credentialError(c, err)
+	}
+	h.unregister(c, cred.Registration)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// property returns the property the credentials belong to. Credentials are
+// only served under a property scope.
+func (h *CredentialHandler) property(c *fiber.Ctx) (int64, error) {
+	if h.service == nil {
+		return 0, fiber.NewError(fiber.StatusServiceUnavailable, "credential store unavailable")
+	}
+	property, err := propertyFilter(c)
+	if err != nil {
+		return 0, err
+	}
+	if property == 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "property id is required")
+	}
+	return property, nil
+}
+
+// credentialID returns the property and the :credId path param.
+func (h *CredentialHandler) credentialID(c *fiber.Ctx) (int64, string, error) {
+	property, err := h.property(c)
+	if err != nil {
+		return 0, "", err
+	}
+	id := c.Params("credId")
+	if _, err := uuid.Parse(id); err != nil {
+		return 0, "", fiber.NewError(fiber.StatusNotFound, "credential not found")
+	}
+	return property, id, nil
+}
+
+// validate checks UPG credentials against the credential structure of their
+// gateway, as reported by the property's processor. Relay credentials are
+// not validated.
+func (h *CredentialHandler) validate(c *fiber.Ctx, cred *credentials.Credential) error {
+	if cred.Mode != credentials.ModeUPG {
+		return nil
+	}
+	proc, err := resolveProcessor(c, h.resolver)
+	if err != nil {
+		return err
+	}
+	upg, err := processor.As[processor.UPG](proc, processor.CapabilityUPGGateways)
+	if err != nil {
+		return err
+	}
+	schema, err := h.schemas.Get(c.Context(), upg, cred.Gateway)
+	if errors.Is(err, processor.ErrNotFound) {
+		return &credentials.ValidationError{Fields: []credentials.FieldError{{
+			Field:   "gateway",
+			Code:    credentials.CodeUnknownGateway,
+			Message: "is not a UPG gateway of processor " + proc.Name(),
+		}}}
+	}
+	if err != nil {
+		return processorError(proc, err)
+	}
+	return schema.Validate(cred.Secrets)
+}
+
+// register registers new or changed UPG credentials with the property's
+// processor, replacing the registration prev. Failures are only logged: the
+// credentials are registered on first use by a charge instead.
+func (h *CredentialHandler) register(c *fiber.Ctx, cred *credentials.Credential, prev *credentials.Registration) {
+	if cred.Mode != credentials.ModeUPG {
+		return
+	}
+	proc, err := resolveProcessor(c, h.resolver)
+	if err == nil {
+		err = h.service.Register(c.Context(), proc, cred)
+	}
+	if err != nil {
+		log.Printf("credentials: register %s with processor: %v", cred.ID, err)
+	}
+	h.unregister(c, prev)
+}
+
+// unregister deletes a registration from the property's processor. Failures,
+// and registrations with another processor, are logged and left in place.
+func (h *CredentialHandler) unregister(c *fiber.Ctx, reg *credentials.Registration) {
+	if reg == nil {
+		return
+	}
+	proc, err := resolveProcessor(c, h.resolver)
+	switch {
+	case err != nil:
+	case proc.Name() != reg.Processor:
+		err = fmt.Errorf("registered with %s, not the property's processor %s", reg.Processor, proc.Name())
+	default:
+		var upg processor.UPG
+		if upg, err = processor.As[processor.UPG](proc, processor.CapabilityUPGCredentials); err == nil {
+			err = upg.DeleteUPGCredentials(c.Context(), reg.CredentialsID)
+		}
+	}
+	if err != nil {
+		log.Printf("credentials: delete processor credentials %s: %v", reg.CredentialsID, err)
+	}
+}
+
+// secretValues converts the submitted secret values to strings. Blank field
+// names are rejected, as are empty values unless removal is allowed.
+func secretValues(raw map[string]json.RawMessage, allowRemove bool) (map[string]string, error) {
+	secrets := make(map[string]string, len(raw))
+	var errs []credentials.FieldError
+	for k, v := range raw {
+		if strings.TrimSpace(k) == "" {
+			return nil, fiber.NewError(fiber.StatusBadRequest, "secret field names must not be empty")
+		}
+		var s string
+		switch {
+		case string(v) == "null":
+		case len(v) > 0 && v[0] == '"':
+			if err := json.Unmarshal(v, &s); err != nil {
+				return nil, fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+			}
+		case len(v) > 0 && (v[0] == '-' || v[0] >= '0' && v[0] <= '9' || v[0] == 't' || v[0] == 'f'):
+			s = string(v)
+		default:
+			errs = append(errs, credentials.FieldError{Field: k, Code: credentials.CodeInvalidType, Message: "must be a string, number or boolean"})
+			continue
+		}
+		if s == "" && !allowRemove {
+			errs = append(errs, credentials.FieldError{Field: k, Code: credentials.CodeRequired, Message: "must not be empty"})
+			continue
+		}
+		secrets[k] = s
+	}
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+		return nil, &credentials.ValidationError{Fields: errs}
+	}
+	return secrets, nil
+}
+
+// credentialError maps a credentials error to a response. Validation errors
+// are reported field by field with 422 so the caller can show each one next
+// to its input. Storage and decryption failures are logged and reported as
+// 503 without detail; request and processor errors pass through to the
+// error handler.
+func credentialError(c *fiber.Ctx, err error) error {
+	var verr *credentials.ValidationError
+	var fe *fiber.Error
+	var pe *processor.Error
+	switch {
+	case errors.As(err, &verr):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":  "invalid credentials",
+			"fields": verr.Fields,
+		})
+	case errors.As(err, &fe), errors.As(err, &pe), errors.Is(err, processor.ErrUnknownProcessor):
+		return err
+	case errors.Is(err, credentials.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "credential not found")
+	}
+	log.Printf("credentials: %v", err)
+	return fiber.NewError(fiber.StatusServiceUnavailable, "credential store unavailable")
+}
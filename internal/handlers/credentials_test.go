@@ -0,0 +1,291 @@
+package handlers_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/credentials"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway"
+	"github.com/CentraGlobal/backend-payment-go/internal/handlers"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/gofiber/fiber/v2"
+)
+
+func newCredentialService(t *testing.T) *credentials.Service {
+	t.Helper()
+	sealer, err := credentials.NewSealer([]byte(strings.Repeat("k", credentials.KeySize)))
+	if err != nil {
+		t.Fatalf("NewSealer: %v", err)
+	}
+	return credentials.NewService(credentials.NewMemoryStore(), sealer)
+}
+
+func setupCredentialApp(t *testing.T, mock *mockUPGProcessor, adapters ...gateway.Adapter) *fiber.App {
+	t.Helper()
+	svc := newCredentialService(t)
+	registry := gateway.NewRegistry(adapters...)
+	app := credentialApp(handlers.NewCredentialHandler(processor.Static(mock), svc,
+		credentials.NewSchemaCache(time.Hour), registry))
+	app.Post("/v1/payments/charge", handlers.NewPaymentHandler(processor.Static(mock),
+		handlers.WithCredentials(svc), handlers.WithGateways(registry)).Charge)
+	return app
+}
+
+var stripeStructure = map[string]any{"secret_key": map[string]any{"type": "string", "required": true}}
+
+func credentialApp(h *handlers.CredentialHandler) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	creds := app.Group("/v1/properties/:propertyId/credentials")
+	creds.Post("/", h.Create)
+	creds.Get("/", h.List)
+	creds.Get("/:credId", h.Get)
+	creds.Patch("/:credId", h.Update)
+	creds.Delete("/:credId", h.Delete)
+	creds.Post("/:credId/test", h.Test)
+	return app
+}
+
+func TestCredentials_NeverReturnSecrets(t *testing.T) {
+	app := setupCredentialApp(t, &mockUPGProcessor{structure: stripeStructure})
+
+	body := `{"gateway":"Stripe","label":"live","secrets":{"secret_key":"sk_live_abc"}}`
+	status, created := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials", body, nil)
+	if status != http.StatusCreated {
+		t.Fatalf("create: %d %v", status, created)
+	}
+	id, _ := created["id"].(string)
+	if fields, _ := created["fields"].([]any); len(fields) != 1 || fields[0] != "secret_key" {
+		t.Errorf("expected secret field names, got %v", created)
+	}
+	if _, ok := created["secrets"]; ok {
+		t.Errorf("expected no secrets in response, got %v", created)
+	}
+
+	for _, path := range []string{"/v1/properties/7/credentials", "/v1/properties/7/credentials/" + id} {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || strings.Contains(string(b), "sk_live_abc") {
+			t.Errorf("GET %s: %d %s", path, resp.StatusCode, b)
+		}
+	}
+
+	if status, _ := doJSON(t, app, http.MethodGet, "/v1/properties/8/credentials/"+id, "", nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 for another property's credential, got %d", status)
+	}
+}
+
+func TestCredentials_UpdateAndDelete(t *testing.T) {
+	app := setupCredentialApp(t, &mockUPGProcessor{})
+	_, created := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"mode":"relay","gateway":"payzone","secrets":{"merchant_id":"m1","password":"p1"}}`, nil)
+	id, _ := created["id"].(string)
+	path := "/v1/properties/7/credentials/" + id
+
+	status, updated := doJSON(t, app, http.MethodPatch, path, `{"label":"test","secrets":{"password":""}}`, nil)
+	if status != http.StatusOK || updated["label"] != "test" {
+		t.Fatalf("update: %d %v", status, updated)
+	}
+	if fields, _ := updated["fields"].([]any); len(fields) != 1 || fields[0] != "merchant_id" {
+		t.Errorf("expected password removed, got %v", updated["fields"])
+	}
+	if status, _ := doJSON(t, app, http.MethodPatch, path, `{"gateway":"Stripe"}`, nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 changing the gateway, got %d", status)
+	}
+
+	if status, _ := doJSON(t, app, http.MethodDelete, path, "", nil); status != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodGet, path, "", nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", status)
+	}
+}
+
+func TestCredentials_Validation(t *testing.T) {
+	app := setupCredentialApp(t, &mockUPGProcessor{structure: stripeStructure})
+	for _, body := range []string{
+		`{"secrets":{"k":"v"}}`,
+		`{"gateway":"Stripe"}`,
+		`{"mode":"direct","gateway":"Stripe","secrets":{"k":"v"}}`,
+	} {
+		if status, _ := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials", body, nil); status != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, status)
+		}
+	}
+	if status, _ := doJSON(t, app, http.MethodGet, "/v1/properties/abc/credentials", "", nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid property, got %d", status)
+	}
+
+	unavailable := credentialApp(handlers.NewCredentialHandler(processor.Static(&mockUPGProcessor{}), nil, nil, nil))
+	if status, _ := doJSON(t, unavailable, http.MethodGet, "/v1/properties/7/credentials", "", nil); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without a credential store, got %d", status)
+	}
+}
+
+func TestCredentials_ValidatesUPGStructure(t *testing.T) {
+	mock := &mockUPGProcessor{structure: map[string]any{
+		"api_key": map[string]any{"type": "string", "required": true},
+		"port":    map[string]any{"type": "integer", "required": true},
+		"sandbox": map[string]any{"type": "boolean"},
+	}}
+	app := setupCredentialApp(t, mock)
+
+	status, result := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"gateway":"Acme","secrets":{"port":"abc","extra":"x","sandbox":{}}}`, nil)
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %v", status, result)
+	}
+	if fields, _ := result["fields"].([]any); len(fields) != 1 {
+		t.Errorf("expected only the malformed sandbox value to be reported before validation, got %v", result["fields"])
+	}
+
+	status, result = doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"gateway":"Acme","secrets":{"port":"abc","extra":"x"}}`, nil)
+	fields, _ := result["fields"].([]any)
+	if status != http.StatusUnprocessableEntity || len(fields) != 3 {
+		t.Fatalf("expected 3 field errors, got %d %v", status, result)
+	}
+	for i, want := range []string{"api_key:required", "extra:unknown_field", "port:invalid_type"} {
+		f, _ := fields[i].(map[string]any)
+		if got := f["field"].(string) + ":" + f["code"].(string); got != want {
+			t.Errorf("field error %d: expected %s, got %s", i, want, got)
+		}
+	}
+
+	status, created := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"gateway":"Acme","secrets":{"api_key":"k1","port":8443,"sandbox":true}}`, nil)
+	if status != http.StatusCreated || created["mode"] != "upg" {
+		t.Fatalf("expected valid credentials to be saved, got %d %v", status, created)
+	}
+	id, _ := created["id"].(string)
+
+	if status, _ := doJSON(t, app, http.MethodPatch, "/v1/properties/7/credentials/"+id, `{"secrets":{"api_key":""}}`, nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 removing a required field, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodPatch, "/v1/properties/7/credentials/"+id, `{"secrets":{"port":"9443"}}`, nil); status != http.StatusOK {
+		t.Errorf("expected valid update, got %d", status)
+	}
+	if mock.calls != 1 {
+		t.Errorf("expected the credential structure to be fetched once, got %d calls", mock.calls)
+	}
+}
+
+func TestCredentials_UnknownUPGGateway(t *testing.T) {
+	mock := &mockUPGProcessor{err: processor.NewError(processor.ErrNotFound, "mock", errors.New("no such gateway"))}
+	app := setupCredentialApp(t, mock)
+
+	status, result := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials", `{"gateway":"Nope","secrets":{"k":"v"}}`, nil)
+	fields, _ := result["fields"].([]any)
+	if status != http.StatusUnprocessableEntity || len(fields) != 1 {
+		t.Fatalf("expected a gateway field error, got %d %v", status, result)
+	}
+	if f, _ := fields[0].(map[string]any); f["field"] != "gateway" || f["code"] != "unknown_gateway" {
+		t.Errorf("unexpected field error %v", f)
+	}
+
+	noUPG := setupCredentialApp(t, &mockUPGProcessor{caps: processor.Capabilities{processor.CapabilityRelay}})
+	if status, _ := doJSON(t, noUPG, http.MethodPost, "/v1/properties/7/credentials", `{"gateway":"Stripe","secrets":{"k":"v"}}`, nil); status != http.StatusNotImplemented {
+		t.Errorf("expected 501 saving UPG credentials without a UPG processor, got %d", status)
+	}
+}
+
+func TestCredentials_RegisterWithProcessor(t *testing.T) {
+	mock := &mockUPGProcessor{structure: stripeStructure}
+	app := setupCredentialApp(t, mock)
+
+	_, created := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"gateway":"Stripe","secrets":{"secret_key":"sk_1"}}`, nil)
+	reg, _ := created["registration"].(map[string]any)
+	if reg["processor"] != "mock" || reg["credentials_id"] != "creds-1" {
+		t.Fatalf("expected the credential to be registered, got %v", created)
+	}
+	path := "/v1/properties/7/credentials/" + created["id"].(string)
+
+	_, updated := doJSON(t, app, http.MethodPatch, path, `{"label":"renamed"}`, nil)
+	if reg, _ := updated["registration"].(map[string]any); reg["credentials_id"] != "creds-1" {
+		t.Errorf("expected a label change to keep the registration, got %v", updated)
+	}
+	_, updated = doJSON(t, app, http.MethodPatch, path, `{"secrets":{"secret_key":"sk_2"}}`, nil)
+	if reg, _ := updated["registration"].(map[string]any); reg["credentials_id"] != "creds-2" {
+		t.Errorf("expected new secrets to be registered again, got %v", updated)
+	}
+	if len(mock.unregistered) != 1 || mock.unregistered[0] != "creds-1" {
+		t.Errorf("expected the old registration to be deleted, got %v", mock.unregistered)
+	}
+
+	doJSON(t, app, http.MethodDelete, path, "", nil)
+	if len(mock.unregistered) != 2 || mock.unregistered[1] != "creds-2" {
+		t.Errorf("expected deletion to remove the registration, got %v", mock.unregistered)
+	}
+
+	_, relay := doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"mode":"relay","gateway":"stripe","secrets":{"secret_key":"sk_1"}}`, nil)
+	if _, ok := relay["registration"]; ok || len(mock.registered) != 2 {
+		t.Errorf("expected relay credentials not to be registered, got %v", relay)
+	}
+}
+
+func TestCharge_UPG_StoredCredentials(t *testing.T) {
+	mock := &mockUPGProcessor{structure: stripeStructure, charge: &processor.UPGChargeResponse{Status: "Success", TransactionID: "txn_1"}}
+	app := setupCredentialApp(t, mock)
+	doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"gateway":"Stripe","secrets":{"secret_key":"sk_1"}}`, nil)
+
+	body := `{"card_token":"tok_1","amount":"10.00","currency":"EUR","gateway_name":"stripe"}`
+	status, result := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, map[string]string{"X-Property-ID": "7"})
+	if status != http.StatusOK || result["status"] != "Success" {
+		t.Fatalf("expected a successful charge, got %d %v", status, result)
+	}
+	if mock.lastCharge.CredentialsID != "creds-1" {
+		t.Errorf("expected the stored credentials' ID, got %+v", mock.lastCharge)
+	}
+
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, map[string]string{"X-Property-ID": "8"}); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a property without credentials, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 without a property, got %d", status)
+	}
+}
+
+func TestCharge_Gateway_StoredRelayCredentials(t *testing.T) {
+	mock := &mockUPGProcessor{structure: stripeStructure, charge: &processor.UPGChargeResponse{Status: "Success", TransactionID: "txn_1"}}
+	adapter := &stubAdapter{}
+	app := setupCredentialApp(t, mock, adapter)
+	doJSON(t, app, http.MethodPost, "/v1/properties/7/credentials",
+		`{"mode":"relay","gateway":"stub","secrets":{"secret_key":"sk_hotel"}}`, nil)
+
+	body := `{"card_token":"tok_1","amount":"10.00","currency":"EUR","gateway_name":"Stub"}`
+	status, result := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, map[string]string{"X-Property-ID": "7"})
+	if status != http.StatusOK || result["status"] != "Success" || result["processor"] != "mock" || result["amount"] != 10.0 {
+		t.Fatalf("expected a successful gateway charge, got %d %v", status, result)
+	}
+	if adapter.charged.Credentials["secret_key"] != "sk_hotel" || adapter.charged.CardToken != "tok_1" || adapter.charged.Amount.Minor != 1000 {
+		t.Errorf("unexpected adapter request %+v", adapter.charged)
+	}
+	if mock.lastCharge.CardToken != "" {
+		t.Errorf("expected no UPG charge, got %+v", mock.lastCharge)
+	}
+
+	// Without relay credentials the gateway is charged through the UPG.
+	doJSON(t, app, http.MethodPost, "/v1/properties/8/credentials",
+		`{"gateway":"stub","secrets":{"secret_key":"sk_upg"}}`, nil)
+	status, result = doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, map[string]string{"X-Property-ID": "8"})
+	if status != http.StatusOK || mock.lastCharge.CredentialsID != "creds-1" {
+		t.Errorf("expected a UPG charge, got %d %v", status, result)
+	}
+
+	doJSON(t, app, http.MethodPost, "/v1/properties/9/credentials",
+		`{"mode":"relay","gateway":"stub","secrets":{"api_key":"k"}}`, nil)
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, map[string]string{"X-Property-ID": "9"}); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for incomplete stored credentials, got %d", status)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/gofiber/fiber/v2"
+)
+
+// errorMapping is the API response for a processor error category.
+type errorMapping struct {
+	kind    error
+	status  int
+	code    string
+	message string
+}
+
+// processorErrors maps processor error categories to stable API error codes.
+// Order matters only for errors that wrap more than one category.
+var processorErrors = []errorMapping{
+	{processor.ErrUnsupported, fiber.StatusNotImplemented, "UNSUPPORTED_OPERATION",
+		"This operation is not supported by the payment processor configured for this property."},
+	{processor.ErrNotFound, fiber.StatusNotFound, "NOT_FOUND",
+		"The requested card or resource was not found."},
+	{processor.ErrInvalidCard, fiber.StatusUnprocessableEntity, "INVALID_CARD",
+		"The card details were rejected as invalid."},
+	{processor.ErrBadRequest, fiber.StatusUnprocessableEntity, "PROCESSOR_REJECTED_REQUEST",
+		"The payment processor rejected the request as invalid."},
+	{processor.ErrDeclined, fiber.StatusPaymentRequired, "CARD_DECLINED",
+		"The payment was declined."},
+	{processor.ErrRateLimited, fiber.StatusTooManyRequests, "RATE_LIMITED",
+		"The payment processor is rate limiting requests. Please retry later."},
+	{processor.ErrAuthFailure, fiber.StatusBadGateway, "PROCESSOR_AUTH_FAILED",
+		"The payment service could not authenticate with the payment processor."},
+	{processor.ErrUpstreamUnavailable, fiber.StatusServiceUnavailable, "UPSTREAM_UNAVAILABLE",
+		"The payment processor is temporarily unavailable. Please retry later."},
+	{processor.ErrUnknownProcessor, fiber.StatusInternalServerError, "PROCESSOR_NOT_CONFIGURED",
+		"The payment processor assigned to this property is not configured on the payment service."},
+}
+
+// ErrorHandler is the Fiber error handler for the service. Processor errors are
+// mapped to a status and a machine-readable code; upstream error details are
+// logged but never included in the response.
+//
+// Response shape: {"error": "<CODE>", "message": "<human readable>"}. Plain
+// *fiber.Error values keep the {"error": "<message>"} shape used by handlers
+// for request validation.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return c.Status(fe.Code).JSON(fiber.Map{
+			"error": fe.Message,
+		})
+	}
+
+	log.Printf("%s %s: %v", c.Method(), c.Path(), err)
+
+	for _, m := range processorErrors {
+		if errors.Is(err, m.kind) {
+			return c.Status(m.status).JSON(fiber.Map{
+				"error":   m.code,
+				"message": m.message,
+			})
+		}
+	}
+
+	var pe *processor.Error
+	if errors.As(err, &pe) {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":   "PROCESSOR_ERROR",
+			"message": "The payment processor returned an error.",
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":   "INTERNAL_ERROR",
+		"message": "An unexpected error occurred.",
+	})
+}
@@ -0,0 +1,74 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/handlers"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestErrorHandler_Mapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", processor.StatusError("vaultera", 404, []byte(`{}`)), http.StatusNotFound, "NOT_FOUND"},
+		{"invalid card", processor.CardRejected(processor.StatusError("vaultera", 422, []byte(`{}`))), http.StatusUnprocessableEntity, "INVALID_CARD"},
+		{"bad request", processor.StatusError("vaultera", 400, []byte(`{}`)), http.StatusUnprocessableEntity, "PROCESSOR_REJECTED_REQUEST"},
+		{"declined", processor.StatusError("vaultera", 402, []byte(`{}`)), http.StatusPaymentRequired, "CARD_DECLINED"},
+		{"unsupported", processor.Unsupported("vaultera", "UPG"), http.StatusNotImplemented, "UNSUPPORTED_OPERATION"},
+		{"unavailable", processor.TransportError("vaultera", errors.New("dial tcp")), http.StatusServiceUnavailable, "UPSTREAM_UNAVAILABLE"},
+		{"rate limited", processor.StatusError("vaultera", 429, []byte(`{}`)), http.StatusTooManyRequests, "RATE_LIMITED"},
+		{"auth failure", processor.StatusError("vaultera", 401, []byte(`{}`)), http.StatusBadGateway, "PROCESSOR_AUTH_FAILED"},
+		{"uncategorized", processor.StatusError("vaultera", 409, []byte(`{}`)), http.StatusBadGateway, "PROCESSOR_ERROR"},
+		{"unknown processor", processor.ErrUnknownProcessor, http.StatusInternalServerError, "PROCESSOR_NOT_CONFIGURED"},
+		{"internal", errors.New("boom"), http.StatusInternalServerError, "INTERNAL_ERROR"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+			app.Get("/", func(c *fiber.Ctx) error { return tc.err })
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.status {
+				t.Errorf("expected %d, got %d", tc.status, resp.StatusCode)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			var result map[string]string
+			json.Unmarshal(body, &result)
+			if result["error"] != tc.code {
+				t.Errorf("expected code %s, got %q", tc.code, result["error"])
+			}
+		})
+	}
+}
+
+func TestErrorHandler_DoesNotLeakUpstreamBody(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Get("/", func(c *fiber.Ctx) error {
+		return processor.StatusError("pcibooking", 500, []byte(`{"secret":"provider-internal-detail"}`))
+	})
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if strings.Contains(string(body), "provider-internal-detail") {
+		t.Errorf("response leaked upstream body: %s", body)
+	}
+}
@@ -9,9 +9,18 @@ import (
 	goredis "github.com/redis/go-redis/v9"
 )
 
+// CircuitReporter exposes the circuit breaker state of each processor.
+type CircuitReporter interface {
+	CircuitStates() map[string]string
+}
+
 // HealthHandler returns an extended health-check handler that verifies DB and
 // Redis connectivity in addition to reporting the service as alive.
-func HealthHandler(db *pgxpool.Pool, ariDB *pgxpool.Pool, redisClient *goredis.Client) fiber.Handler {
+//
+// Processor circuit breaker states are reported under "processors" but do not
+// degrade the status: an upstream outage affects every instance equally, so
+// failing the health check would only take healthy instances out of rotation.
+func HealthHandler(db *pgxpool.Pool, ariDB *pgxpool.Pool, redisClient *goredis.Client, circuits CircuitReporter) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		ctx, cancel := context.WithTimeout(c.Context(), 3*time.Second)
 		defer cancel()
@@ -47,6 +56,10 @@ func HealthHandler(db *pgxpool.Pool, ariDB *pgxpool.Pool, redisClient *goredis.C
 			}
 		}
 
+		if circuits != nil {
+			status["processors"] = circuits.CircuitStates()
+		}
+
 		httpStatus := fiber.StatusOK
 		if status["status"] == "degraded" {
 			httpStatus = fiber.StatusServiceUnavailable
@@ -15,7 +15,7 @@ import (
 func TestHealthHandler_NoInfra(t *testing.T) {
 	app := fiber.New()
 	// Pass nil pools and nil redis to simulate no infra configured.
-	app.Get("/health", handlers.HealthHandler(nil, nil, nil))
+	app.Get("/health", handlers.HealthHandler(nil, nil, nil, nil))
 
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	resp, err := app.Test(req)
@@ -44,7 +44,7 @@ func TestHealthHandler_Degraded_Redis(t *testing.T) {
 	badRedis := goredis.NewClient(&goredis.Options{
 		Addr: "localhost:1", // port 1 is never open
 	})
-	app.Get("/health", handlers.HealthHandler(nil, nil, badRedis))
+	app.Get("/health", handlers.HealthHandler(nil, nil, badRedis, nil))
 
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	resp, err := app.Test(req, 5000) // 5 s timeout for the test
@@ -69,3 +69,36 @@ func TestHealthHandler_Degraded_Redis(t *testing.T) {
 		t.Errorf("expected redis=unhealthy, got %q", result["redis"])
 	}
 }
+
+type staticCircuits map[string]string
+
+func (s staticCircuits) CircuitStates() map[string]string { return s }
+
+func TestHealthHandler_ReportsCircuits(t *testing.T) {
+	app := fiber.New()
+	app.Get("/health", handlers.HealthHandler(nil, nil, nil, staticCircuits{"vaultera": "open"}))
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// An open circuit is reported but does not fail the health check.
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var result struct {
+		Status     string            `json:"status"`
+		Processors map[string]string `json:"processors"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("parse response: %v", err)
+	}
+	if result.Processors["vaultera"] != "open" {
+		t.Errorf("expected vaultera=open, got %v", result.Processors)
+	}
+}
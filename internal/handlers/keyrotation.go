@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/credentials"
+	"github.com/gofiber/fiber/v2"
+)
+
+// KeyRotationHandler serves the master key rotation of stored gateway
+// credentials under /v1/admin/credentials/key-rotation.
+type KeyRotationHandler struct {
+	rotator *credentials.Rotator
+}
+
+// NewKeyRotationHandler creates a KeyRotationHandler. A nil rotator makes both
+// endpoints respond 503, as credential storage is disabled.
+func NewKeyRotationHandler(r *credentials.Rotator) *KeyRotationHandler {
+	return &KeyRotationHandler{rotator: r}
+}
+
+// Start handles POST /v1/admin/credentials/key-rotation. It starts re-wrapping
+// every stored data key with the current master key in the background and
+// responds 202 with the run's status, or 409 while a run is in progress.
+func (h *KeyRotationHandler) Start(c *fiber.Ctx) error {
+	if h.rotator == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "credential store unavailable")
+	}
+	status, err := h.rotator.Start(c.Context())
+	if errors.Is(err, credentials.ErrRotationRunning) {
+		return fiber.NewError(fiber.StatusConflict, "a key rotation is already running")
+	}
+	if err != nil {
+		log.Printf("credentials: start key rotation: %v", err)
+		return fiber.NewError(fiber.StatusServiceUnavailable, "credential store unavailable")
+	}
+	return c.Status(fiber.StatusAccepted).JSON(status)
+}
+
+// Status handles GET /v1/admin/credentials/key-rotation, reporting the
+// progress of the current or last run and the stored records by key version.
+func (h *KeyRotationHandler) Status(c *fiber.Ctx) error {
+	if h.rotator == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "credential store unavailable")
+	}
+	status, err := h.rotator.Status(c.Context())
+	if err != nil {
+		log.Printf("credentials: key rotation status: %v", err)
+		return fiber.NewError(fiber.StatusServiceUnavailable, "credential store unavailable")
+	}
+	return c.JSON(status)
+}
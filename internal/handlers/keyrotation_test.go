@@ -0,0 +1,58 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/credentials"
+	"github.com/CentraGlobal/backend-payment-go/internal/handlers"
+	"github.com/gofiber/fiber/v2"
+)
+
+func keyRotationApp(r *credentials.Rotator) *fiber.App {
+	h := handlers.NewKeyRotationHandler(r)
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Post("/v1/admin/credentials/key-rotation", h.Start)
+	app.Get("/v1/admin/credentials/key-rotation", h.Status)
+	return app
+}
+
+func TestKeyRotation(t *testing.T) {
+	store := credentials.NewMemoryStore()
+	oldKey, newKey := bytes.Repeat([]byte{1}, credentials.KeySize), bytes.Repeat([]byte{2}, credentials.KeySize)
+	v1, _ := credentials.NewKeyRing(1, map[int][]byte{1: oldKey})
+	for i := 0; i < 3; i++ {
+		credentials.NewService(store, v1).Create(context.Background(),
+			&credentials.Credential{PropertyID: 7, Gateway: "Stripe", Secrets: map[string]string{"secret_key": "sk"}})
+	}
+	ring, _ := credentials.NewKeyRing(2, map[int][]byte{1: oldKey, 2: newKey})
+	app := keyRotationApp(credentials.NewRotator(credentials.NewService(store, ring), 10))
+
+	status, result := doJSON(t, app, http.MethodGet, "/v1/admin/credentials/key-rotation", "", nil)
+	if versions, _ := result["key_versions"].(map[string]any); status != http.StatusOK || result["state"] != "idle" || versions["1"] != 3.0 {
+		t.Fatalf("expected an idle rotation with 3 records under version 1, got %d %v", status, result)
+	}
+
+	status, result = doJSON(t, app, http.MethodPost, "/v1/admin/credentials/key-rotation", "", nil)
+	if status != http.StatusAccepted || result["total"] != 3.0 || result["target_version"] != 2.0 {
+		t.Fatalf("expected the rotation to start, got %d %v", status, result)
+	}
+	deadline := time.Now().Add(time.Second)
+	for result["state"] == "running" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		_, result = doJSON(t, app, http.MethodGet, "/v1/admin/credentials/key-rotation", "", nil)
+	}
+	if versions, _ := result["key_versions"].(map[string]any); result["state"] != "completed" || result["rewrapped"] != 3.0 || versions["2"] != 3.0 {
+		t.Errorf("expected every record re-wrapped to version 2, got %v", result)
+	}
+}
+
+func TestKeyRotation_Unavailable(t *testing.T) {
+	app := keyRotationApp(nil)
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/admin/credentials/key-rotation", "", nil); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without credential storage, got %d", status)
+	}
+}
@@ -1,27 +1,125 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
 	"strings"
 
+	"github.com/CentraGlobal/backend-payment-go/internal/credentials"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway"
+	"github.com/CentraGlobal/backend-payment-go/internal/ledger"
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
 	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/redact"
+	"github.com/CentraGlobal/backend-payment-go/internal/reservations"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
 	"github.com/gofiber/fiber/v2"
 )
 
+// PropertyIDHeader carries the property a request is made on behalf of. It is
+// used to route the request to that property's processor.
+const PropertyIDHeader = "X-Property-ID"
+
 type PaymentHandler struct {
-	processor processor.Processor
+	resolver     processor.Resolver
+	ledger       ledger.Store
+	gateways     *gateway.Registry
+	credentials  *credentials.Service
+	reservations reservations.Store
+}
+
+// PaymentOption configures optional PaymentHandler dependencies.
+type PaymentOption func(*PaymentHandler)
+
+// WithLedger records every charge attempt in l and serves the transaction
+// endpoints from it.
+func WithLedger(l ledger.Store) PaymentOption {
+	return func(h *PaymentHandler) { h.ledger = l }
+}
+
+// WithGateways makes the adapters in g available for refunding relay charges.
+func WithGateways(g *gateway.Registry) PaymentOption {
+	return func(h *PaymentHandler) { h.gateways = g }
+}
+
+// WithCredentials lets UPG charges omit credentials_id and use the property's
+// stored credentials for the gateway instead.
+func WithCredentials(s *credentials.Service) PaymentOption {
+	return func(h *PaymentHandler) { h.credentials = s }
+}
+
+// WithReservations serves the reservation endpoints from s, so that charges
+// can be made by reservation number.
+func WithReservations(s reservations.Store) PaymentOption {
+	return func(h *PaymentHandler) { h.reservations = s }
+}
+
+func NewPaymentHandler(r processor.Resolver, opts ...PaymentOption) *PaymentHandler {
+	h := &PaymentHandler{resolver: r}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// propertyID returns the property ID from the :propertyId path param, falling
+// back to the X-Property-ID header. An empty value selects the default processor.
+func propertyID(c *fiber.Ctx) string {
+	if id := c.Params("propertyId"); id != "" {
+		return id
+	}
+	return strings.TrimSpace(c.Get(PropertyIDHeader))
+}
+
+// processorFor resolves the processor that should serve the request's property.
+func (h *PaymentHandler) processorFor(c *fiber.Ctx) (processor.Processor, error) {
+	return resolveProcessor(c, h.resolver)
 }
 
-func NewPaymentHandler(p processor.Processor) *PaymentHandler {
-	return &PaymentHandler{processor: p}
+// resolveProcessor resolves the processor for the request's property with r.
+func resolveProcessor(c *fiber.Ctx, r processor.Resolver) (processor.Processor, error) {
+	if _, err := propertyFilter(c); err != nil {
+		return nil, err
+	}
+	id := propertyID(c)
+	p, err := r.Resolve(c.Context(), id)
+	if err != nil && !errors.Is(err, processor.ErrUnknownProcessor) {
+		log.Printf("resolve processor for property %q: %v", id, err)
+		return nil, fiber.NewError(fiber.StatusServiceUnavailable, "failed to resolve payment processor")
+	}
+	return p, err
+}
+
+// processorError attributes err to proc so that the error handler reports it
+// as a processor failure (502) rather than an internal error, unless it
+// already carries a processor error category.
+func processorError(proc processor.Processor, err error) error {
+	var pe *processor.Error
+	if errors.As(err, &pe) {
+		return err
+	}
+	return &processor.Error{Processor: proc.Name(), Err: err}
 }
 
 func (h *PaymentHandler) GetSession(c *fiber.Ctx) error {
+	proc, err := h.processorFor(c)
+	if err != nil {
+		return err
+	}
 	scope := c.Query("scope", "card")
-	token, err := h.processor.CreateSessionToken(c.Context(), scope)
+	if err := processor.Require(proc, processor.CapabilitySessionToken); err != nil {
+		return err
+	}
+
+	token, err := proc.CreateSessionToken(c.Context(), scope)
 	if err != nil {
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return processorError(proc, err)
+	}
+	if token.Processor == "" {
+		token.Processor = proc.Name()
 	}
 	return c.JSON(token)
 }
@@ -38,32 +136,51 @@ func (h *PaymentHandler) Tokenize(c *fiber.Ctx) error {
 		})
 	}
 
-	card, err := h.processor.CreateCard(c.Context(), req.Card)
+	proc, err := h.processorFor(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
+	}
+
+	if err := processor.Require(proc, processor.CapabilityTokenize); err != nil {
+		return err
+	}
+
+	card, err := proc.CreateCard(c.Context(), req.Card)
+	if err != nil {
+		return processorError(proc, err)
 	}
 	return c.Status(fiber.StatusCreated).JSON(card)
 }
 
 func (h *PaymentHandler) GetCard(c *fiber.Ctx) error {
+	proc, err := h.processorFor(c)
+	if err != nil {
+		return err
+	}
 	token := c.Params("token")
-	card, err := h.processor.GetCard(c.Context(), token)
+	if err := processor.Require(proc, processor.CapabilityCardRead); err != nil {
+		return err
+	}
+
+	card, err := proc.GetCard(c.Context(), token)
 	if err != nil {
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return processorError(proc, err)
 	}
 	return c.JSON(card)
 }
 
 func (h *PaymentHandler) DeleteCard(c *fiber.Ctx) error {
+	proc, err := h.processorFor(c)
+	if err != nil {
+		return err
+	}
 	token := c.Params("token")
-	if err := h.processor.DeleteCard(c.Context(), token); err != nil {
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	if err := processor.Require(proc, processor.CapabilityCardDelete); err != nil {
+		return err
+	}
+
+	if err := proc.DeleteCard(c.Context(), token); err != nil {
+		return processorError(proc, err)
 	}
 	return c.SendStatus(fiber.StatusNoContent)
 }
@@ -77,11 +194,26 @@ type chargeRequest struct {
 	Headers map[string]string `json:"headers,omitempty"`
 	Body    string            `json:"body,omitempty"`
 
-	// UPG mode fields
-	CredentialsID string  `json:"credentials_id,omitempty"`
-	GatewayName   string  `json:"gateway_name,omitempty"`
-	Amount        float64 `json:"amount,omitempty"`
-	Currency      string  `json:"currency,omitempty"`
+	// UPG mode fields. Without credentials_id, the property's stored
+	// credentials for the gateway are used. When the property holds relay
+	// credentials for a gateway with an adapter, gateway_name alone charges
+	// through the adapter instead (gateway mode).
+	CredentialsID string `json:"credentials_id,omitempty"`
+	GatewayName   string `json:"gateway_name,omitempty"`
+
+	// Amount is a decimal in major units (e.g. 10.50), kept as its JSON text so
+	// it is never rounded through float64. Required in UPG and gateway mode; in
+	// relay mode it is optional and only reported back in the result.
+	Amount   json.Number `json:"amount,omitempty"`
+	Currency string      `json:"currency,omitempty"`
+
+	// ReservationNumber optionally links the charge to a reservation in the
+	// transaction ledger.
+	ReservationNumber string `json:"reservation_number,omitempty"`
+
+	// IncludeRaw returns the redacted gateway or processor response in the
+	// raw_response field of the result.
+	IncludeRaw bool `json:"include_raw,omitempty"`
 }
 
 func (h *PaymentHandler) Charge(c *fiber.Ctx) error {
@@ -98,59 +230,207 @@ func (h *PaymentHandler) Charge(c *fiber.Ctx) error {
 		})
 	}
 
+	proc, err := h.processorFor(c)
+	if err != nil {
+		return err
+	}
+
 	// Auto-detect mode from request fields
 	if req.CredentialsID != "" {
-		return h.chargeViaUPG(c, req)
+		return h.chargeViaUPG(c, proc, req)
 	} else if req.URL != "" {
-		return h.chargeViaRelay(c, req)
+		return h.chargeViaRelay(c, proc, req)
+	} else if req.GatewayName != "" && h.credentials != nil {
+		adapter, secrets, err := h.storedRelayAdapter(c, req.GatewayName)
+		if err != nil {
+			return err
+		}
+		if adapter != nil {
+			return h.chargeViaAdapter(c, proc, adapter, secrets, req)
+		}
+		return h.chargeViaUPG(c, proc, req)
 	}
 	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 		"error": "either credentials_id (UPG mode) or url (relay mode) is required",
 	})
 }
 
-func (h *PaymentHandler) chargeViaUPG(c *fiber.Ctx, req chargeRequest) error {
+func (h *PaymentHandler) chargeViaUPG(c *fiber.Ctx, proc processor.Processor, req chargeRequest) error {
 	if req.GatewayName == "" || req.Currency == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "credentials_id, gateway_name, and currency are required for UPG mode",
+			"error": "gateway_name and currency are required for UPG mode",
 		})
 	}
 
-	if req.Amount <= 0 {
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "amount must be greater than zero",
+			"error": err.Error(),
 		})
 	}
 
-	resp, err := h.processor.ChargeUPG(c.Context(), processor.UPGChargeRequest{
+	upg, err := processor.As[processor.UPG](proc, processor.CapabilityUPGCharge)
+	if err != nil {
+		return err
+	}
+	_, result, err := h.chargeUPG(c, upg, req, amount)
+	if err != nil {
+		return err
+	}
+	return c.JSON(chargeResult(result, amount, req.IncludeRaw))
+}
+
+// chargeUPG charges amount to req's card through proc's UPG, resolving the
+// stored credentials when req has no credentials_id, and records the charge
+// in the ledger. The recorded transaction is returned once the processor has
+// been called, even if the call failed.
+func (h *PaymentHandler) chargeUPG(c *fiber.Ctx, proc processor.UPG, req chargeRequest, amount money.Money) (*ledger.Transaction, *types.ChargeResult, error) {
+	if req.CredentialsID == "" {
+		var err error
+		if req.CredentialsID, err = h.storedCredentialsID(c, proc, req.GatewayName); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	txn := &ledger.Transaction{
+		Operation:         ledger.OperationCharge,
+		Mode:              ledger.ModeUPG,
+		Processor:         proc.Name(),
+		Gateway:           req.GatewayName,
+		CredentialsID:     req.CredentialsID,
+		AmountMinor:       amount.Minor,
+		Currency:          amount.Currency.Code,
+		CardToken:         req.CardToken,
+		ReservationNumber: req.ReservationNumber,
+	}
+	if err := h.beginTransaction(c, txn); err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := proc.ChargeUPG(c.Context(), processor.UPGChargeRequest{
 		CardToken:     req.CardToken,
-		Amount:        req.Amount,
-		Currency:      req.Currency,
+		Amount:        amount,
 		GatewayName:   req.GatewayName,
 		CredentialsID: req.CredentialsID,
 	})
 	if err != nil {
-		errStr := err.Error()
-		if strings.Contains(errStr, "UPG is not supported") {
-			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-				"error":   "PROCESSOR_CONFIGURATION_MISMATCH",
-				"message": "This hotel's payment gateway requires UPG support, but the payment service is not configured for UPG. Please contact support to resolve this configuration issue.",
-			})
-		}
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		h.failTransaction(c, txn, err)
+		return txn, nil, processorError(proc, err)
+	}
+
+	result := upgResult(resp, req.GatewayName, proc)
+	h.completeTransaction(c, txn, result)
+	return txn, result, nil
+}
+
+// storedCredentialsID returns the processor's credentials ID for the
+// property's stored UPG credentials for gateway, registering them with proc
+// on first use.
+func (h *PaymentHandler) storedCredentialsID(c *fiber.Ctx, proc processor.Processor, gateway string) (string, error) {
+	property, err := propertyFilter(c)
+	if err != nil {
+		return "", err
 	}
+	if property == 0 {
+		return "", fiber.NewError(fiber.StatusBadRequest, "credentials_id or a property id is required for UPG mode")
+	}
+	id, err := h.credentials.ResolveUPG(c.Context(), proc, property, gateway)
+	if errors.Is(err, credentials.ErrNotFound) {
+		return "", fiber.NewError(fiber.StatusUnprocessableEntity, "property has no stored UPG credentials for gateway "+gateway)
+	}
+	if err != nil {
+		return "", credentialError(c, err)
+	}
+	return id, nil
+}
 
-	return c.JSON(fiber.Map{
-		"status":         resp.Status,
-		"transaction_id": resp.TransactionID,
-		"message":        resp.Message,
-		"raw_response":   resp.Raw,
+// storedRelayAdapter returns the adapter for gateway and the property's
+// stored relay credentials for it. It returns a nil adapter, to charge
+// through the UPG instead, when the gateway has no adapter or the property no
+// relay credentials for it.
+func (h *PaymentHandler) storedRelayAdapter(c *fiber.Ctx, gatewayName string) (gateway.Adapter, map[string]string, error) {
+	if h.gateways == nil {
+		return nil, nil, nil
+	}
+	adapter, err := h.gateways.Get(gatewayName)
+	if err != nil {
+		return nil, nil, nil
+	}
+	property, err := propertyFilter(c)
+	if err != nil || property == 0 {
+		return nil, nil, err
+	}
+	secrets, err := h.credentials.ResolveRelay(c.Context(), property, gatewayName)
+	if errors.Is(err, credentials.ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, credentialError(c, err)
+	}
+	return adapter, secrets, nil
+}
+
+// chargeViaAdapter charges req's card through the gateway adapter, which
+// builds the gateway's request with secrets and sends it via proc's relay.
+func (h *PaymentHandler) chargeViaAdapter(c *fiber.Ctx, proc processor.Processor, adapter gateway.Adapter, secrets map[string]string, req chargeRequest) error {
+	if req.Currency == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "currency is required for gateway mode")
+	}
+	amount, err := parseAmount(req.Amount, req.Currency)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	relay, err := processor.As[processor.Relayer](proc, processor.CapabilityRelay)
+	if err != nil {
+		return err
+	}
+
+	txn := &ledger.Transaction{
+		Operation:         ledger.OperationCharge,
+		Mode:              ledger.ModeRelay,
+		Processor:         proc.Name(),
+		Gateway:           adapter.Name(),
+		AmountMinor:       amount.Minor,
+		Currency:          amount.Currency.Code,
+		CardToken:         req.CardToken,
+		ReservationNumber: req.ReservationNumber,
+	}
+	if err := h.beginTransaction(c, txn); err != nil {
+		return err
+	}
+
+	result, err := adapter.Charge(c.Context(), relay, gateway.ChargeRequest{
+		CardToken:   req.CardToken,
+		Amount:      amount,
+		Reference:   txn.ID,
+		Credentials: secrets,
 	})
+	if errors.Is(err, gateway.ErrMissingCredential) {
+		h.failTransaction(c, txn, err)
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "stored relay credentials for gateway "+adapter.Name()+": "+err.Error())
+	}
+	if err != nil {
+		h.failTransaction(c, txn, err)
+		return processorError(proc, err)
+	}
+	if result.Processor == "" {
+		result.Processor = proc.Name()
+	}
+	h.completeTransaction(c, txn, result)
+	return c.JSON(chargeResult(result, amount, req.IncludeRaw))
 }
 
-func (h *PaymentHandler) chargeViaRelay(c *fiber.Ctx, req chargeRequest) error {
+func (h *PaymentHandler) chargeViaRelay(c *fiber.Ctx, proc processor.Processor, req chargeRequest) error {
+	var amount money.Money
+	if req.Amount != "" || req.Currency != "" {
+		var err error
+		if amount, err = parseAmount(req.Amount, req.Currency); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
 	sendReq := processor.SendRequest{
 		Method:  req.Method,
 		URL:     req.URL,
@@ -158,11 +438,120 @@ func (h *PaymentHandler) chargeViaRelay(c *fiber.Ctx, req chargeRequest) error {
 		Body:    req.Body,
 	}
 
-	resp, err := h.processor.SendCard(c.Context(), req.CardToken, sendReq)
+	relay, err := processor.As[processor.Relayer](proc, processor.CapabilityRelay)
 	if err != nil {
-		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
+	}
+
+	txn := &ledger.Transaction{
+		Operation:         ledger.OperationCharge,
+		Mode:              ledger.ModeRelay,
+		Processor:         proc.Name(),
+		Gateway:           relayGateway(req.URL),
+		AmountMinor:       amount.Minor,
+		Currency:          amount.Currency.Code,
+		CardToken:         req.CardToken,
+		ReservationNumber: req.ReservationNumber,
+	}
+	if err := h.beginTransaction(c, txn); err != nil {
+		return err
+	}
+
+	resp, err := relay.SendCard(c.Context(), req.CardToken, sendReq)
+	if err != nil {
+		h.failTransaction(c, txn, err)
+		return processorError(proc, err)
+	}
+	result := relayResult(resp, req.URL)
+	if result.Processor == "" {
+		result.Processor = proc.Name()
+	}
+	h.completeTransaction(c, txn, result)
+	return c.JSON(chargeResult(result, amount, req.IncludeRaw))
+}
+
+// relayGatewayResponse holds the fields commonly used by gateways for the
+// transaction ID and decline reason.
+type relayGatewayResponse struct {
+	ID            string `json:"id"`
+	TransactionID string `json:"transaction_id"`
+	DeclineCode   string `json:"decline_code"`
+	Message       string `json:"message"`
+	Error         struct {
+		Code        string `json:"code"`
+		DeclineCode string `json:"decline_code"`
+		Message     string `json:"message"`
+	} `json:"error"`
+}
+
+// relayResult normalizes a relayed gateway response. The relay does not know
+// the gateway's payload format, so the status is derived from the HTTP status
+// code and the transaction ID and decline code are read from common fields
+// when present.
+func relayResult(resp *processor.SendResponse, gatewayURL string) *types.ChargeResult {
+	body := gateway.RelayBody(resp.Body)
+	result := &types.ChargeResult{
+		Status:    gateway.HTTPStatus(resp.StatusCode),
+		Gateway:   relayGateway(gatewayURL),
+		Processor: resp.Processor,
+		Raw:       body,
+	}
+
+	// Best effort: fields with unexpected types are skipped by Unmarshal.
+	var gr relayGatewayResponse
+	_ = json.Unmarshal(body, &gr)
+	result.TransactionID = firstNonEmpty(gr.TransactionID, gr.ID)
+	result.Message = firstNonEmpty(gr.Error.Message, gr.Message)
+	if result.Status != types.UPGStatusSuccess {
+		result.DeclineCode = firstNonEmpty(gr.DeclineCode, gr.Error.DeclineCode, gr.Error.Code)
+	}
+	return result
+}
+
+// parseAmount validates a request's amount and currency, returning errors
+// suitable for the client.
+func parseAmount(decimal json.Number, currency string) (money.Money, error) {
+	amount, err := money.Parse(decimal.String(), currency)
+	switch {
+	case errors.Is(err, money.ErrUnknownCurrency):
+		return money.Money{}, errors.New("currency must be an ISO 4217 currency code")
+	case errors.Is(err, money.ErrPrecision):
+		return money.Money{}, fmt.Errorf("amount has more decimal places than %s allows", strings.ToUpper(currency))
+	case err != nil || !amount.IsPositive():
+		return money.Money{}, errors.New("amount must be greater than zero")
+	}
+	return amount, nil
+}
+
+// relayGateway names the gateway of a relay charge by its URL's host.
+func relayGateway(gatewayURL string) string {
+	if u, err := url.Parse(gatewayURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return gatewayURL
+}
+
+// chargeResult completes r with the charged amount, if known, and redacts the
+// raw response or drops it unless the caller asked for it.
+func chargeResult(r *types.ChargeResult, amount money.Money, includeRaw bool) *types.ChargeResult {
+	if amount.Currency.Code != "" {
+		r.Amount = json.Number(amount.Decimal())
+		r.AmountMinor = amount.Minor
+		r.Currency = amount.Currency.Code
+	}
+	if includeRaw {
+		r.Raw = redact.JSON(r.Raw)
+	} else {
+		r.Raw = nil
+	}
+	return r
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
 	}
-	return c.JSON(resp)
+	return ""
 }
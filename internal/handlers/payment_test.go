@@ -2,6 +2,7 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/CentraGlobal/backend-payment-go/internal/handlers"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
 	"github.com/CentraGlobal/backend-payment-go/internal/vaultera"
 	"github.com/gofiber/fiber/v2"
 )
@@ -16,9 +18,9 @@ import (
 func setupPaymentApp(vaulteraHandler http.Handler) (*fiber.App, *httptest.Server) {
 	vSrv := httptest.NewServer(vaulteraHandler)
 	client := vaultera.NewClient("test-key", vSrv.URL)
-	ph := handlers.NewPaymentHandler(client)
+	ph := handlers.NewPaymentHandler(processor.Static(client))
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
 	v1 := app.Group("/v1")
 	v1.Get("/session", ph.GetSession)
 	payments := v1.Group("/payments")
@@ -166,3 +168,70 @@ func TestDeleteCard(t *testing.T) {
 		t.Error("expected mock Vaultera server to be called")
 	}
 }
+
+// staticRoutes routes properties to processor names from a fixed map.
+type staticRoutes map[string]string
+
+func (s staticRoutes) ProcessorFor(_ context.Context, propertyID string) (string, error) {
+	return s[propertyID], nil
+}
+
+func TestCharge_RoutesByPropertyHeader(t *testing.T) {
+	def := &mockUPGProcessor{sendResp: &processor.SendResponse{StatusCode: 200, Body: []byte(`{"via":"default"}`)}}
+	routed := &mockUPGProcessor{sendResp: &processor.SendResponse{StatusCode: 200, Body: []byte(`{"via":"routed"}`)}}
+
+	reg := processor.NewRegistry("vaultera", def, staticRoutes{"42": "pcibooking"})
+	reg.Register("pcibooking", routed)
+
+	ph := handlers.NewPaymentHandler(reg)
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Post("/v1/payments/charge", ph.Charge)
+	app.Post("/v1/properties/:propertyId/payments/charge", ph.Charge)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   string
+		status int
+	}{
+		{"no property", "/v1/payments/charge", "", "default", http.StatusOK},
+		{"routed header", "/v1/payments/charge", "42", "routed", http.StatusOK},
+		{"unrouted header", "/v1/payments/charge", "7", "default", http.StatusOK},
+		{"routed path param", "/v1/properties/42/payments/charge", "", "routed", http.StatusOK},
+		{"invalid header", "/v1/payments/charge", "abc", "", http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := `{"card_token":"tok","url":"https://gateway.test","method":"POST","include_raw":true}`
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.header != "" {
+				req.Header.Set(handlers.PropertyIDHeader, tc.header)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, resp.StatusCode)
+			}
+			if tc.want == "" {
+				return
+			}
+
+			respBody, _ := io.ReadAll(resp.Body)
+			var result struct {
+				Body map[string]string `json:"raw_response"`
+			}
+			json.Unmarshal(respBody, &result)
+			if result.Body["via"] != tc.want {
+				t.Errorf("expected %s processor, got %v", tc.want, result.Body["via"])
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/credentials"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway"
+	"github.com/CentraGlobal/backend-payment-go/internal/ledger"
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// refundRequest is the optional body of POST /v1/payments/:id/refunds.
+// Amount, in the original transaction's currency, defaults to its full amount.
+type refundRequest struct {
+	Amount json.Number `json:"amount,omitempty"`
+
+	// Gateway names the gateway adapter that refunds a relay charge sent to a
+	// raw URL; charges made through an adapter are refunded by it. The
+	// adapter uses the property's stored relay credentials for its gateway.
+	// UPG refunds use the original transaction's gateway and credentials.
+	Gateway string `json:"gateway,omitempty"`
+
+	IncludeRaw bool `json:"include_raw,omitempty"`
+}
+
+// Refund handles POST /v1/payments/:id/refunds, refunding all or part of the
+// charge or authorization with ledger ID :id. Refunds of a transaction may not
+// exceed its captured amount in total.
+func (h *PaymentHandler) Refund(c *fiber.Ctx) error {
+	var req refundRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		}
+	}
+
+	orig, err := h.transaction(c)
+	if err != nil {
+		return err
+	}
+	if orig.TransactionID == "" {
+		return fiber.NewError(fiber.StatusConflict, "the original transaction has no gateway transaction ID")
+	}
+
+	var amount money.Money
+	if req.Amount == "" {
+		amount, err = money.New(orig.AmountMinor, orig.Currency)
+	} else {
+		amount, err = parseAmount(req.Amount, orig.Currency)
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	proc, err := h.processorFor(c)
+	if err != nil {
+		return err
+	}
+
+	var (
+		refunder processor.Refunder
+		relay    processor.Relayer
+		adapter  gateway.Adapter
+		secrets  map[string]string
+	)
+	switch orig.Mode {
+	case ledger.ModeUPG:
+		if proc.Name() != orig.Processor {
+			return fiber.NewError(fiber.StatusConflict, "transaction was made with processor "+orig.Processor)
+		}
+		if refunder, err = processor.As[processor.Refunder](proc, processor.CapabilityRefund); err != nil {
+			return err
+		}
+	case ledger.ModeRelay:
+		if adapter, secrets, err = h.refundAdapter(c, orig, req); err != nil {
+			return err
+		}
+		if relay, err = processor.As[processor.Relayer](proc, processor.CapabilityRelay); err != nil {
+			return err
+		}
+	}
+
+	txn := &ledger.Transaction{
+		Operation:         ledger.OperationRefund,
+		ParentID:          orig.ID,
+		Mode:              orig.Mode,
+		Processor:         proc.Name(),
+		Gateway:           orig.Gateway,
+		CredentialsID:     orig.CredentialsID,
+		AmountMinor:       amount.Minor,
+		Currency:          amount.Currency.Code,
+		CardToken:         orig.CardToken,
+		ReservationNumber: orig.ReservationNumber,
+	}
+	if err := h.beginTransaction(c, txn); err != nil {
+		return err
+	}
+
+	var result *types.ChargeResult
+	if adapter != nil {
+		result, err = adapter.Refund(c.Context(), relay, gateway.RefundRequest{
+			CardToken:     orig.CardToken,
+			TransactionID: orig.TransactionID,
+			Amount:        amount,
+			Reference:     txn.ID,
+			Credentials:   secrets,
+		})
+		if errors.Is(err, gateway.ErrMissingCredential) {
+			h.failTransaction(c, txn, err)
+			return fiber.NewError(fiber.StatusUnprocessableEntity, "stored relay credentials for gateway "+adapter.Name()+": "+err.Error())
+		}
+		if result != nil {
+			result.Processor = proc.Name()
+		}
+	} else {
+		var resp *processor.UPGChargeResponse
+		resp, err = refunder.RefundUPG(c.Context(), processor.UPGTransactionRequest{
+			TransactionID: orig.TransactionID,
+			Amount:        amount,
+			GatewayName:   orig.Gateway,
+			CredentialsID: orig.CredentialsID,
+		})
+		if err == nil {
+			result = upgResult(resp, orig.Gateway, proc)
+		}
+	}
+	if err != nil {
+		h.failTransaction(c, txn, err)
+		return processorError(proc, err)
+	}
+	h.completeTransaction(c, txn, result)
+	return c.JSON(chargeResult(result, amount, req.IncludeRaw))
+}
+
+// refundAdapter returns the gateway adapter that refunds the relay charge
+// orig, and the stored relay credentials for its gateway of the property the
+// charge was made for. Charges made through an adapter record its name as
+// their gateway; charges sent to a raw URL name the adapter in req.
+func (h *PaymentHandler) refundAdapter(c *fiber.Ctx, orig *ledger.Transaction, req refundRequest) (gateway.Adapter, map[string]string, error) {
+	if h.gateways == nil {
+		return nil, nil, fiber.NewError(fiber.StatusNotImplemented, "no gateway adapters are configured")
+	}
+	name := orig.Gateway
+	if _, err := h.gateways.Get(name); err != nil {
+		name = req.Gateway
+	} else if req.Gateway != "" && !strings.EqualFold(req.Gateway, name) {
+		return nil, nil, fiber.NewError(fiber.StatusConflict, "transaction was made through gateway "+name)
+	}
+	if name == "" {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "gateway is required to refund a relay charge")
+	}
+	adapter, err := h.gateways.Get(name)
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusBadRequest, "unknown gateway "+name)
+	}
+
+	if h.credentials == nil || orig.PropertyID == 0 {
+		return nil, nil, fiber.NewError(fiber.StatusUnprocessableEntity, "relay refunds need the property's stored relay credentials for gateway "+adapter.Name())
+	}
+	secrets, err := h.credentials.ResolveRelay(c.Context(), orig.PropertyID, adapter.Name())
+	if errors.Is(err, credentials.ErrNotFound) {
+		return nil, nil, fiber.NewError(fiber.StatusUnprocessableEntity, "property has no stored relay credentials for gateway "+adapter.Name())
+	}
+	if err != nil {
+		return nil, nil, credentialError(c, err)
+	}
+	return adapter, secrets, nil
+}
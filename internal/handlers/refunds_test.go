@@ -0,0 +1,193 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/credentials"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway"
+	"github.com/CentraGlobal/backend-payment-go/internal/handlers"
+	"github.com/CentraGlobal/backend-payment-go/internal/ledger"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+	"github.com/gofiber/fiber/v2"
+)
+
+// stubAdapter is a gateway.Adapter and gateway.Verifier that records charge
+// and refund requests and verifies with the configured status.
+type stubAdapter struct {
+	charged  gateway.ChargeRequest
+	last     gateway.RefundRequest
+	verified types.UPGStatus
+}
+
+func (a *stubAdapter) Name() string { return "stub" }
+func (a *stubAdapter) Charge(_ context.Context, _ processor.Relayer, req gateway.ChargeRequest) (*types.ChargeResult, error) {
+	if _, err := gateway.Credential(req.Credentials, "secret_key"); err != nil {
+		return nil, err
+	}
+	a.charged = req
+	return &types.ChargeResult{Status: types.UPGStatusSuccess, TransactionID: "ch_1", Gateway: "stub"}, nil
+}
+func (a *stubAdapter) Refund(_ context.Context, _ processor.Relayer, req gateway.RefundRequest) (*types.ChargeResult, error) {
+	if _, err := gateway.Credential(req.Credentials, "secret_key"); err != nil {
+		return nil, err
+	}
+	a.last = req
+	return &types.ChargeResult{Status: types.UPGStatusSuccess, TransactionID: "re_1", Gateway: "stub"}, nil
+}
+
+func (a *stubAdapter) Verify(_ context.Context, _ processor.Relayer, req gateway.VerifyRequest) (*types.ChargeResult, error) {
+	if _, err := gateway.Credential(req.Credentials, "secret_key"); err != nil {
+		return nil, err
+	}
+	return &types.ChargeResult{Status: a.verified, Message: "verified", DeclineCode: "code", Gateway: "stub"}, nil
+}
+
+func setupRefundApp(mock *mockUPGProcessor, adapter gateway.Adapter, opts ...handlers.PaymentOption) *fiber.App {
+	opts = append(opts, handlers.WithLedger(ledger.NewMemoryStore()), handlers.WithGateways(gateway.NewRegistry(adapter)))
+	ph := handlers.NewPaymentHandler(processor.Static(mock), opts...)
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	payments := app.Group("/v1/payments")
+	payments.Post("/charge", ph.Charge)
+	payments.Post("/authorize", ph.Authorize)
+	payments.Post("/:id/capture", ph.Capture)
+	payments.Post("/:id/refunds", ph.Refund)
+	return app
+}
+
+func TestRefund_UPG_PartialRefunds(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success", TransactionID: "txn_1"}}
+	app := setupRefundApp(mock, &stubAdapter{})
+
+	body := `{"card_token":"tok_1","amount":100.00,"currency":"USD","gateway_name":"Stripe","credentials_id":"creds-1"}`
+	_, charged := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, nil)
+	id, _ := charged["id"].(string)
+
+	status, result := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", `{"amount":60.00}`, nil)
+	if status != http.StatusOK || result["amount"] != 60.0 || result["status"] != "Success" {
+		t.Fatalf("partial refund: %d %v", status, result)
+	}
+	if mock.lastTxn.TransactionID != "txn_1" || mock.lastTxn.CredentialsID != "creds-1" || mock.lastTxn.Amount.Minor != 6000 {
+		t.Errorf("unexpected refund request %+v", mock.lastTxn)
+	}
+
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", `{"amount":40.01}`, nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 refunding more than was charged, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", `{"amount":40.00}`, nil); status != http.StatusOK {
+		t.Errorf("expected the remaining 40.00 to be refundable, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", "", nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a full refund after partial refunds, got %d", status)
+	}
+}
+
+func TestRefund_TimedOut_StillCountsAgainstCharge(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success", TransactionID: "txn_1"}}
+	app := setupRefundApp(mock, &stubAdapter{})
+
+	body := `{"card_token":"tok_1","amount":100.00,"currency":"USD","gateway_name":"Stripe","credentials_id":"creds-1"}`
+	_, charged := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, nil)
+	id, _ := charged["id"].(string)
+
+	// The refund may have gone through at the gateway before the timeout.
+	mock.err = processor.TransportError("mock", context.DeadlineExceeded)
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", "", nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for a timed-out refund, got %d", status)
+	}
+	mock.err = nil
+	calls := mock.calls
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", "", nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a second full refund after a timed-out one, got %d", status)
+	}
+	if mock.calls != calls {
+		t.Error("second refund should not reach the processor")
+	}
+}
+
+func TestRefund_Authorization_LimitedToCaptured(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success", TransactionID: "auth_1"}}
+	app := setupRefundApp(mock, &stubAdapter{})
+	id := authorize(t, app)
+
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", `{"amount":1.00}`, nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 refunding an uncaptured authorization, got %d", status)
+	}
+	doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/capture", `{"amount":30.00}`, nil)
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", `{"amount":30.01}`, nil); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 refunding more than was captured, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", `{"amount":30.00}`, nil); status != http.StatusOK {
+		t.Errorf("expected captured amount to be refundable, got %d", status)
+	}
+}
+
+func TestRefund_RejectedCharge_Returns409(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Rejected", TransactionID: "txn_1"}}
+	app := setupRefundApp(mock, &stubAdapter{})
+
+	body := `{"card_token":"tok_1","amount":10,"currency":"USD","gateway_name":"Stripe","credentials_id":"c"}`
+	_, charged := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, nil)
+	id, _ := charged["id"].(string)
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", "", nil); status != http.StatusConflict {
+		t.Errorf("expected 409 refunding a rejected charge, got %d", status)
+	}
+}
+
+func TestRefund_Relay_UsesGatewayAdapter(t *testing.T) {
+	mock := &mockUPGProcessor{sendResp: &processor.SendResponse{StatusCode: 200, Body: []byte(`{"id":"pi_1"}`)}}
+	adapter := &stubAdapter{}
+	svc := newCredentialService(t)
+	app := setupRefundApp(mock, adapter, handlers.WithCredentials(svc))
+	property := map[string]string{"X-Property-ID": "7"}
+
+	body := `{"card_token":"tok_1","amount":25.00,"currency":"EUR","method":"POST","url":"https://api.stripe.com/v1/payment_intents"}`
+	_, charged := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, property)
+	id, _ := charged["id"].(string)
+
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", `{"amount":5.00}`, property); status != http.StatusBadRequest {
+		t.Errorf("expected 400 without a gateway, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", `{"gateway":"stub"}`, property); status != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 without stored credentials, got %d", status)
+	}
+
+	svc.Create(context.Background(), &credentials.Credential{PropertyID: 7, Mode: credentials.ModeRelay, Gateway: "stub",
+		Secrets: map[string]string{"secret_key": "sk_hotel"}})
+	status, result := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", `{"amount":5.00,"gateway":"stub"}`, property)
+	if status != http.StatusOK || result["transaction_id"] != "re_1" || result["processor"] != "mock" {
+		t.Fatalf("relay refund: %d %v", status, result)
+	}
+	if adapter.last.TransactionID != "pi_1" || adapter.last.CardToken != "tok_1" || adapter.last.Amount.Minor != 500 {
+		t.Errorf("unexpected adapter request %+v", adapter.last)
+	}
+	if adapter.last.Credentials["secret_key"] != "sk_hotel" {
+		t.Errorf("expected the stored credentials, got %v", adapter.last.Credentials)
+	}
+	if adapter.last.Reference != result["id"] {
+		t.Errorf("expected the refund's ledger ID as reference, got %q", adapter.last.Reference)
+	}
+}
+
+func TestRefund_GatewayCharge_UsesChargingAdapter(t *testing.T) {
+	adapter := &stubAdapter{}
+	svc := newCredentialService(t)
+	svc.Create(context.Background(), &credentials.Credential{PropertyID: 7, Mode: credentials.ModeRelay, Gateway: "stub",
+		Secrets: map[string]string{"secret_key": "sk_hotel"}})
+	app := setupRefundApp(&mockUPGProcessor{}, adapter, handlers.WithCredentials(svc))
+	property := map[string]string{"X-Property-ID": "7"}
+
+	body := `{"card_token":"tok_1","amount":25.00,"currency":"EUR","gateway_name":"stub"}`
+	_, charged := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, property)
+	id, _ := charged["id"].(string)
+
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", `{"gateway":"other"}`, property); status != http.StatusConflict {
+		t.Errorf("expected 409 naming another gateway, got %d", status)
+	}
+	status, result := doJSON(t, app, http.MethodPost, "/v1/payments/"+id+"/refunds", "", property)
+	if status != http.StatusOK || adapter.last.TransactionID != "ch_1" || adapter.last.Amount.Minor != 2500 {
+		t.Errorf("refund of a gateway charge: %d %v %+v", status, result, adapter.last)
+	}
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/ledger"
+	"github.com/CentraGlobal/backend-payment-go/internal/middleware"
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/reservations"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// reservationRequest is the body of POST
+// /v1/properties/:propertyId/reservations. Check-in and check-out are dates
+// (YYYY-MM-DD) and total_amount a decimal in major units of currency.
+type reservationRequest struct {
+	ReservationNumber string                  `json:"reservation_number"`
+	GuestName         string                  `json:"guest_name"`
+	GuestEmail        string                  `json:"guest_email"`
+	CheckIn           string                  `json:"check_in"`
+	CheckOut          string                  `json:"check_out"`
+	Status            types.ReservationStatus `json:"status"`
+	CardToken         string                  `json:"card_token"`
+	TotalAmount       json.Number             `json:"total_amount"`
+	Currency          string                  `json:"currency"`
+}
+
+// reservationChargeRequest is the body of POST
+// /v1/properties/:propertyId/reservations/:number/charge. The card, currency
+// and amount come from the reservation: amount defaults to the outstanding
+// balance, and currency, if given, must be the reservation's.
+type reservationChargeRequest struct {
+	CredentialsID string      `json:"credentials_id,omitempty"`
+	GatewayName   string      `json:"gateway_name"`
+	Amount        json.Number `json:"amount,omitempty"`
+	Currency      string      `json:"currency,omitempty"`
+	IncludeRaw    bool        `json:"include_raw,omitempty"`
+}
+
+// reservationResponse adds the outstanding balance to a reservation.
+type reservationResponse struct {
+	*types.Reservation
+	OutstandingAmount int64 `json:"outstanding_amount"`
+}
+
+func newReservationResponse(r *types.Reservation) *reservationResponse {
+	return &reservationResponse{Reservation: r, OutstandingAmount: r.Outstanding()}
+}
+
+// reservationChargeResponse is the charge result with the reservation's
+// payment state after the charge.
+type reservationChargeResponse struct {
+	*types.ChargeResult
+	Reservation *reservationResponse `json:"reservation,omitempty"`
+}
+
+// CreateReservation handles POST /v1/properties/:propertyId/reservations.
+func (h *PaymentHandler) CreateReservation(c *fiber.Ctx) error {
+	property, err := h.reservationProperty(c)
+	if err != nil {
+		return err
+	}
+	var req reservationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	req.ReservationNumber = strings.TrimSpace(req.ReservationNumber)
+	if req.ReservationNumber == "" || req.CheckIn == "" || req.CheckOut == "" || req.Currency == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "reservation_number, check_in, check_out, and currency are required")
+	}
+	checkIn, err1 := time.Parse(time.DateOnly, req.CheckIn)
+	checkOut, err2 := time.Parse(time.DateOnly, req.CheckOut)
+	if err1 != nil || err2 != nil || checkOut.Before(checkIn) {
+		return fiber.NewError(fiber.StatusBadRequest, "check_in and check_out must be dates (YYYY-MM-DD) with check_out not before check_in")
+	}
+	switch req.Status {
+	case "":
+		req.Status = types.ReservationStatusPending
+	case types.ReservationStatusPending, types.ReservationStatusConfirmed, types.ReservationStatusCancelled,
+		types.ReservationStatusCheckedIn, types.ReservationStatusCheckedOut:
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "status must be pending, confirmed, cancelled, checked_in or checked_out")
+	}
+	total, err := parseAmount(req.TotalAmount, req.Currency)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, strings.Replace(err.Error(), "amount", "total_amount", 1))
+	}
+
+	r := &types.Reservation{
+		ReservationNumber: req.ReservationNumber,
+		PropertyID:        int(property),
+		GuestName:         req.GuestName,
+		GuestEmail:        req.GuestEmail,
+		CheckIn:           checkIn,
+		CheckOut:          checkOut,
+		Status:            req.Status,
+		CardToken:         req.CardToken,
+		TotalAmount:       total.Minor,
+		Currency:          total.Currency.Code,
+	}
+	if err := h.reservations.Create(c.Context(), r); err != nil {
+		return reservationError(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(newReservationResponse(r))
+}
+
+// GetReservation handles GET /v1/properties/:propertyId/reservations/:number.
+func (h *PaymentHandler) GetReservation(c *fiber.Ctx) error {
+	property, err := h.reservationProperty(c)
+	if err != nil {
+		return err
+	}
+	r, err := h.reservations.Get(c.Context(), property, c.Params("number"))
+	if err != nil {
+		return reservationError(err)
+	}
+	return c.JSON(newReservationResponse(r))
+}
+
+// ChargeReservation handles POST
+// /v1/properties/:propertyId/reservations/:number/charge: a UPG charge of
+// the reservation's stored card, recorded against the reservation in the
+// ledger. The amount is held on the reservation while the processor is
+// called, so concurrent charges cannot exceed its total; Success and
+// Accepted charges count as paid. When the outcome is unknown, e.g. after a
+// timeout, the amount stays held so that a retry cannot charge it twice,
+// until the charge is reconciled with ReconcileReservation.
+func (h *PaymentHandler) ChargeReservation(c *fiber.Ctx) error {
+	property, err := h.reservationProperty(c)
+	if err != nil {
+		return err
+	}
+	var req reservationChargeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	if req.GatewayName == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "gateway_name is required")
+	}
+	if req.CredentialsID == "" && h.credentials == nil {
+		return fiber.NewError(fiber.StatusBadRequest, "credentials_id is required")
+	}
+
+	r, err := h.reservations.Get(c.Context(), property, c.Params("number"))
+	if err != nil {
+		return reservationError(err)
+	}
+	amount, err := reservationAmount(r, req)
+	if err != nil {
+		return err
+	}
+
+	proc, err := h.processorFor(c)
+	if err != nil {
+		return err
+	}
+	upg, err := processor.As[processor.UPG](proc, processor.CapabilityUPGCharge)
+	if err != nil {
+		return err
+	}
+
+	if err := h.reservations.Hold(c.Context(), property, r.ReservationNumber, amount.Minor); err != nil {
+		return reservationError(err)
+	}
+	txn, result, err := h.chargeUPG(c, upg, chargeRequest{
+		CardToken:         r.CardToken,
+		CredentialsID:     req.CredentialsID,
+		GatewayName:       req.GatewayName,
+		ReservationNumber: r.ReservationNumber,
+	}, amount)
+	if err != nil && chargeOutcomeUnknown(c, err) {
+		log.Printf("reservations: outcome of charging %s on reservation %s is unknown, keeping it held: %v",
+			amount.Decimal(), r.ReservationNumber, err)
+		if txn != nil && txn.ID != "" {
+			if kerr := h.reservations.Keep(c.Context(), property, r.ReservationNumber, txn.ID, amount.Minor); kerr != nil {
+				log.Printf("reservations: keep hold of %s for reconciliation: %v", txn.ID, kerr)
+			}
+		}
+		return err
+	}
+	paid := err == nil && (result.Status == types.UPGStatusSuccess || result.Status == types.UPGStatusAccepted)
+	// The charge has already happened, so a failure to record it on the
+	// reservation is logged rather than returned.
+	settled, serr := h.reservations.Settle(c.Context(), property, r.ReservationNumber, amount.Minor, paid)
+	if serr != nil {
+		log.Printf("reservations: settle %s of reservation %s: %v", amount.Decimal(), r.ReservationNumber, serr)
+	}
+	if err != nil {
+		return err
+	}
+
+	resp := reservationChargeResponse{ChargeResult: chargeResult(result, amount, req.IncludeRaw)}
+	if settled != nil {
+		resp.Reservation = newReservationResponse(settled)
+	}
+	return c.JSON(resp)
+}
+
+// reconcileRequest is the body of POST
+// /v1/properties/:propertyId/reservations/:number/reconcile: the ledger ID of
+// a charge of the reservation whose outcome was unknown, and its outcome as
+// found at the gateway.
+type reconcileRequest struct {
+	TransactionID string          `json:"transaction_id"`
+	Status        types.UPGStatus `json:"status"`
+}
+
+// ReconcileReservation handles POST
+// /v1/properties/:propertyId/reservations/:number/reconcile. It records the
+// outcome of a reservation charge that was left Unknown in the ledger and
+// settles the amount the charge held on the reservation: Success and
+// Accepted charges are added to the amount paid, and other outcomes release
+// the hold.
+func (h *PaymentHandler) ReconcileReservation(c *fiber.Ctx) error {
+	property, err := h.reservationProperty(c)
+	if err != nil {
+		return err
+	}
+	if err := h.requireLedger(); err != nil {
+		return err
+	}
+	var req reconcileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+	}
+	switch req.Status {
+	case types.UPGStatusSuccess, types.UPGStatusAccepted, types.UPGStatusRejected,
+		types.UPGStatusTemporaryFailure, types.UPGStatusFatalFailure:
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "status must be Success, Accepted, Rejected, TemporaryFailure or FatalFailure")
+	}
+	if _, err := uuid.Parse(req.TransactionID); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "transaction_id must be a transaction id")
+	}
+
+	number := c.Params("number")
+	txn, err := h.ledger.Get(c.Context(), req.TransactionID)
+	if errors.Is(err, ledger.ErrNotFound) || err == nil &&
+		(txn.PropertyID != property || txn.ReservationNumber != number || txn.Operation != ledger.OperationCharge) {
+		return fiber.NewError(fiber.StatusNotFound, "transaction not found")
+	}
+	if err != nil {
+		return err
+	}
+	if txn.Status != ledger.StatusUnknown {
+		return fiber.NewError(fiber.StatusConflict, "transaction outcome is already recorded as "+string(txn.Status))
+	}
+
+	paid := req.Status == types.UPGStatusSuccess || req.Status == types.UPGStatusAccepted
+	r, err := h.reservations.Reconcile(c.Context(), property, number, txn.ID, paid)
+	if err != nil {
+		return reservationError(err)
+	}
+	if err := h.ledger.Complete(c.Context(), txn.ID, ledger.Outcome{Status: ledger.Status(req.Status), Message: "reconciled"}); err != nil {
+		log.Printf("ledger: record reconciled outcome of %s: %v", txn.ID, err)
+	}
+	return c.JSON(newReservationResponse(r))
+}
+
+// ReservationCard is a middleware for the reservation charge route that makes
+// the reservation's stored card the request's card for rate limiting, as the
+// charge names none. Lookup failures are left to ChargeReservation to report.
+func (h *PaymentHandler) ReservationCard(c *fiber.Ctx) error {
+	if h.reservations == nil {
+		return c.Next()
+	}
+	property, err := propertyFilter(c)
+	if err != nil || property == 0 {
+		return c.Next()
+	}
+	if r, err := h.reservations.Get(c.Context(), property, c.Params("number")); err == nil {
+		middleware.SetRateLimitCard(c, r.CardToken)
+	}
+	return c.Next()
+}
+
+// reservationAmount validates a charge of r and returns its amount, which
+// defaults to the outstanding balance.
+func reservationAmount(r *types.Reservation, req reservationChargeRequest) (money.Money, error) {
+	switch {
+	case r.Status == types.ReservationStatusCancelled:
+		return money.Money{}, fiber.NewError(fiber.StatusConflict, "reservation is cancelled")
+	case r.CardToken == "":
+		return money.Money{}, fiber.NewError(fiber.StatusUnprocessableEntity, "reservation has no stored card")
+	case req.Currency != "" && !strings.EqualFold(req.Currency, r.Currency):
+		return money.Money{}, fiber.NewError(fiber.StatusUnprocessableEntity, "currency must be the reservation's currency "+r.Currency)
+	}
+
+	outstanding := r.Outstanding()
+	if req.Amount == "" {
+		if outstanding <= 0 {
+			return money.Money{}, fiber.NewError(fiber.StatusConflict, "reservation has no outstanding balance")
+		}
+		amount, err := money.New(outstanding, r.Currency)
+		if err != nil {
+			return money.Money{}, fmt.Errorf("reservation %s: %w", r.ReservationNumber, err)
+		}
+		return amount, nil
+	}
+	amount, err := parseAmount(req.Amount, r.Currency)
+	if err != nil {
+		return money.Money{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if amount.Minor > outstanding {
+		return money.Money{}, fiber.NewError(fiber.StatusUnprocessableEntity, "amount exceeds the outstanding balance")
+	}
+	return amount, nil
+}
+
+// reservationProperty returns the property the reservation belongs to.
+// Reservations are only served under a property scope.
+func (h *PaymentHandler) reservationProperty(c *fiber.Ctx) (int64, error) {
+	if h.reservations == nil {
+		return 0, fiber.NewError(fiber.StatusServiceUnavailable, "reservation store unavailable")
+	}
+	property, err := propertyFilter(c)
+	if err != nil {
+		return 0, err
+	}
+	if property == 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "property id is required")
+	}
+	return property, nil
+}
+
+// reservationError maps reservation store errors to responses. Unexpected
+// errors are logged and reported as the store being unavailable.
+func reservationError(err error) error {
+	switch {
+	case errors.Is(err, reservations.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "reservation not found")
+	case errors.Is(err, reservations.ErrExists):
+		return fiber.NewError(fiber.StatusConflict, "reservation number already exists")
+	case errors.Is(err, reservations.ErrAmountExceeded):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "amount exceeds the outstanding balance")
+	case errors.Is(err, reservations.ErrHoldNotFound):
+		return fiber.NewError(fiber.StatusConflict, "the charge holds no amount on the reservation")
+	}
+	log.Printf("reservations: %v", err)
+	return fiber.NewError(fiber.StatusServiceUnavailable, "reservation store unavailable")
+}
@@ -0,0 +1,226 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/handlers"
+	"github.com/CentraGlobal/backend-payment-go/internal/ledger"
+	"github.com/CentraGlobal/backend-payment-go/internal/middleware"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/ratelimit"
+	"github.com/CentraGlobal/backend-payment-go/internal/reservations"
+	"github.com/gofiber/fiber/v2"
+)
+
+func setupReservationApp(mock *mockUPGProcessor) *fiber.App {
+	ph := handlers.NewPaymentHandler(processor.Static(mock),
+		handlers.WithLedger(ledger.NewMemoryStore()), handlers.WithReservations(reservations.NewMemoryStore()))
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	property := app.Group("/v1/properties/:propertyId")
+	res := property.Group("/reservations")
+	res.Post("/", ph.CreateReservation)
+	res.Get("/:number", ph.GetReservation)
+	res.Post("/:number/charge", ph.ChargeReservation)
+	res.Post("/:number/reconcile", ph.ReconcileReservation)
+	property.Get("/payments/transactions", ph.ListTransactions)
+	return app
+}
+
+const reservationsPath = "/v1/properties/7/reservations"
+
+func createReservation(t *testing.T, app *fiber.App, body string) {
+	t.Helper()
+	if status, result := doJSON(t, app, http.MethodPost, reservationsPath, body, nil); status != http.StatusCreated {
+		t.Fatalf("create reservation: expected 201, got %d: %v", status, result)
+	}
+}
+
+func TestReservationCharge_PaysOutstandingBalance(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success", TransactionID: "txn_1"}}
+	app := setupReservationApp(mock)
+	createReservation(t, app, `{"reservation_number":"R-100","check_in":"2026-11-01","check_out":"2026-11-03",
+		"status":"confirmed","card_token":"tok_res","total_amount":"100.00","currency":"EUR"}`)
+
+	_, got := doJSON(t, app, http.MethodGet, reservationsPath+"/R-100", "", nil)
+	if got["payment_status"] != "unpaid" || got["outstanding_amount"] != 10000.0 {
+		t.Fatalf("expected an unpaid reservation, got %v", got)
+	}
+
+	status, result := doJSON(t, app, http.MethodPost, reservationsPath+"/R-100/charge",
+		`{"gateway_name":"Stripe","credentials_id":"creds-1","amount":"40.00"}`, nil)
+	if status != http.StatusOK || result["status"] != "Success" {
+		t.Fatalf("expected a successful charge, got %d %v", status, result)
+	}
+	if c := mock.lastCharge; c.CardToken != "tok_res" || c.Amount.Minor != 4000 || c.Amount.Currency.Code != "EUR" {
+		t.Errorf("expected the reservation's card and currency, got %+v", c)
+	}
+	if r, _ := result["reservation"].(map[string]any); r["amount_paid"] != 4000.0 || r["payment_status"] != "partially_paid" || r["outstanding_amount"] != 6000.0 {
+		t.Errorf("expected a partially paid reservation, got %v", result["reservation"])
+	}
+
+	_, result = doJSON(t, app, http.MethodPost, reservationsPath+"/R-100/charge", `{"gateway_name":"Stripe","credentials_id":"creds-1"}`, nil)
+	if result["amount"] != 60.0 {
+		t.Errorf("expected the outstanding balance to be charged, got %v", result)
+	}
+	if r, _ := result["reservation"].(map[string]any); r["payment_status"] != "paid" || r["outstanding_amount"] != 0.0 {
+		t.Errorf("expected a paid reservation, got %v", result["reservation"])
+	}
+
+	if status, _ := doJSON(t, app, http.MethodPost, reservationsPath+"/R-100/charge", `{"gateway_name":"Stripe","credentials_id":"creds-1"}`, nil); status != http.StatusConflict {
+		t.Errorf("expected 409 without an outstanding balance, got %d", status)
+	}
+	_, list := doJSON(t, app, http.MethodGet, "/v1/properties/7/payments/transactions?reservation_number=R-100", "", nil)
+	if txns, _ := list["transactions"].([]any); len(txns) != 2 {
+		t.Errorf("expected both charges recorded against the reservation, got %v", list)
+	}
+}
+
+func TestReservationCharge_DeclineLeavesBalance(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Rejected"}}
+	app := setupReservationApp(mock)
+	createReservation(t, app, `{"reservation_number":"R-1","check_in":"2026-11-01","check_out":"2026-11-02",
+		"card_token":"tok_res","total_amount":"50","currency":"USD"}`)
+
+	_, result := doJSON(t, app, http.MethodPost, reservationsPath+"/R-1/charge", `{"gateway_name":"Stripe","credentials_id":"creds-1"}`, nil)
+	if r, _ := result["reservation"].(map[string]any); result["status"] != "Rejected" || r["amount_paid"] != 0.0 || r["outstanding_amount"] != 5000.0 {
+		t.Errorf("expected the declined amount released, got %v", result)
+	}
+}
+
+// TestReservationCharge_UnknownOutcomeKeepsHold verifies that a charge that
+// may have gone through keeps its amount held, while one that definitely did
+// not releases it.
+func TestReservationCharge_UnknownOutcomeKeepsHold(t *testing.T) {
+	mock := &mockUPGProcessor{err: processor.StatusError("pci_booking_upg", http.StatusBadGateway, nil)}
+	app := setupReservationApp(mock)
+	createReservation(t, app, `{"reservation_number":"R-2","check_in":"2026-11-01","check_out":"2026-11-02",
+		"card_token":"tok_res","total_amount":"50","currency":"USD"}`)
+	charge := `{"gateway_name":"Stripe","credentials_id":"creds-1"}`
+
+	if status, _ := doJSON(t, app, http.MethodPost, reservationsPath+"/R-2/charge", charge, nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", status)
+	}
+	_, got := doJSON(t, app, http.MethodGet, reservationsPath+"/R-2", "", nil)
+	if got["amount_held"] != 5000.0 || got["outstanding_amount"] != 0.0 {
+		t.Errorf("expected the amount kept held, got %v", got)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, reservationsPath+"/R-2/charge", charge, nil); status != http.StatusConflict {
+		t.Errorf("expected a retry to be refused while the amount is held, got %d", status)
+	}
+
+	mock.err = processor.StatusError("pci_booking_upg", http.StatusPaymentRequired, nil)
+	createReservation(t, app, `{"reservation_number":"R-3","check_in":"2026-11-01","check_out":"2026-11-02",
+		"card_token":"tok_res","total_amount":"50","currency":"USD"}`)
+	doJSON(t, app, http.MethodPost, reservationsPath+"/R-3/charge", charge, nil)
+	if _, got := doJSON(t, app, http.MethodGet, reservationsPath+"/R-3", "", nil); got["outstanding_amount"] != 5000.0 {
+		t.Errorf("expected a declined amount released, got %v", got)
+	}
+}
+
+func TestReservationReconcile_TimedOutCharge(t *testing.T) {
+	mock := &mockUPGProcessor{err: processor.TransportError("pci_booking_upg", context.DeadlineExceeded)}
+	app := setupReservationApp(mock)
+	createReservation(t, app, `{"reservation_number":"R-4","check_in":"2026-11-01","check_out":"2026-11-02",
+		"card_token":"tok_res","total_amount":"50","currency":"USD"}`)
+	charge := `{"gateway_name":"Stripe","credentials_id":"creds-1"}`
+
+	if status, _ := doJSON(t, app, http.MethodPost, reservationsPath+"/R-4/charge", charge, nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for a timed-out charge, got %d", status)
+	}
+	_, list := doJSON(t, app, http.MethodGet, "/v1/properties/7/payments/transactions?reservation_number=R-4&status=Unknown", "", nil)
+	txns, _ := list["transactions"].([]any)
+	if len(txns) != 1 {
+		t.Fatalf("expected one charge with unknown outcome, got %v", list["transactions"])
+	}
+	id, _ := txns[0].(map[string]any)["id"].(string)
+
+	if status, _ := doJSON(t, app, http.MethodPost, reservationsPath+"/R-4/reconcile",
+		`{"transaction_id":"`+id+`","status":"Pending"}`, nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for a status that is not an outcome, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, reservationsPath+"/R-1/reconcile",
+		`{"transaction_id":"`+id+`","status":"Rejected"}`, nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 reconciling another reservation's charge, got %d", status)
+	}
+
+	status, got := doJSON(t, app, http.MethodPost, reservationsPath+"/R-4/reconcile",
+		`{"transaction_id":"`+id+`","status":"Rejected"}`, nil)
+	if status != http.StatusOK || got["outstanding_amount"] != 5000.0 || got["payment_status"] != "unpaid" {
+		t.Fatalf("expected the hold released, got %d %v", status, got)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, reservationsPath+"/R-4/reconcile",
+		`{"transaction_id":"`+id+`","status":"Success"}`, nil); status != http.StatusConflict {
+		t.Errorf("expected 409 reconciling a charge twice, got %d", status)
+	}
+	_, list = doJSON(t, app, http.MethodGet, "/v1/properties/7/payments/transactions?reservation_number=R-4&status=Rejected", "", nil)
+	if txns, _ := list["transactions"].([]any); len(txns) != 1 {
+		t.Errorf("expected the reconciled outcome recorded in the ledger, got %v", list["transactions"])
+	}
+
+	mock.err, mock.charge = nil, &processor.UPGChargeResponse{Status: "Success"}
+	if status, _ := doJSON(t, app, http.MethodPost, reservationsPath+"/R-4/charge", charge, nil); status != http.StatusOK {
+		t.Errorf("expected the released amount to be chargeable, got %d", status)
+	}
+}
+
+func TestReservationCharge_Validation(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success"}}
+	app := setupReservationApp(mock)
+	createReservation(t, app, `{"reservation_number":"R-1","check_in":"2026-11-01","check_out":"2026-11-02",
+		"card_token":"tok_res","total_amount":"50","currency":"USD"}`)
+	createReservation(t, app, `{"reservation_number":"R-2","check_in":"2026-11-01","check_out":"2026-11-02",
+		"status":"cancelled","card_token":"tok_res","total_amount":"50","currency":"USD"}`)
+	createReservation(t, app, `{"reservation_number":"R-3","check_in":"2026-11-01","check_out":"2026-11-02",
+		"total_amount":"50","currency":"USD"}`)
+
+	cases := []struct {
+		name, number, body string
+		want               int
+	}{
+		{"currency mismatch", "R-1", `{"gateway_name":"Stripe","credentials_id":"c","currency":"EUR"}`, http.StatusUnprocessableEntity},
+		{"over the balance", "R-1", `{"gateway_name":"Stripe","credentials_id":"c","amount":"50.01"}`, http.StatusUnprocessableEntity},
+		{"without credentials", "R-1", `{"gateway_name":"Stripe"}`, http.StatusBadRequest},
+		{"cancelled", "R-2", `{"gateway_name":"Stripe","credentials_id":"c"}`, http.StatusConflict},
+		{"without a card", "R-3", `{"gateway_name":"Stripe","credentials_id":"c"}`, http.StatusUnprocessableEntity},
+		{"unknown", "R-4", `{"gateway_name":"Stripe","credentials_id":"c"}`, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		if status, result := doJSON(t, app, http.MethodPost, reservationsPath+"/"+tc.number+"/charge", tc.body, nil); status != tc.want {
+			t.Errorf("%s: expected %d, got %d %v", tc.name, tc.want, status, result)
+		}
+	}
+	if mock.calls != 0 {
+		t.Errorf("expected no charge to reach the processor, got %d", mock.calls)
+	}
+
+	if status, _ := doJSON(t, app, http.MethodPost, reservationsPath,
+		`{"reservation_number":"R-1","check_in":"2026-11-01","check_out":"2026-11-02","total_amount":"50","currency":"USD"}`, nil); status != http.StatusConflict {
+		t.Errorf("expected 409 for a duplicate reservation number, got %d", status)
+	}
+}
+
+func TestReservationCharge_RateLimitedPerStoredCard(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success", TransactionID: "txn_1"}}
+	ph := handlers.NewPaymentHandler(processor.Static(mock), handlers.WithReservations(reservations.NewMemoryStore()))
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	res := app.Group("/v1/properties/:propertyId/reservations")
+	res.Post("/", ph.CreateReservation)
+	res.Post("/:number/charge", ph.ReservationCard,
+		middleware.RateLimit(ratelimit.NewMemoryStore(), "charge", middleware.RateLimits{Window: time.Minute, PerCard: 1}),
+		ph.ChargeReservation)
+
+	for _, number := range []string{"R-1", "R-2"} {
+		createReservation(t, app, `{"reservation_number":"`+number+`","check_in":"2026-11-01","check_out":"2026-11-03",
+			"card_token":"tok_res","total_amount":"100.00","currency":"EUR"}`)
+	}
+	body := `{"gateway_name":"Stripe","credentials_id":"creds-1","amount":"10.00"}`
+	if status, result := doJSON(t, app, http.MethodPost, reservationsPath+"/R-1/charge", body, nil); status != http.StatusOK {
+		t.Fatalf("expected the first charge of the card, got %d %v", status, result)
+	}
+	if status, _ := doJSON(t, app, http.MethodPost, reservationsPath+"/R-2/charge", body, nil); status != http.StatusTooManyRequests {
+		t.Errorf("expected 429 charging the same stored card again, got %d", status)
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/ledger"
+	"github.com/CentraGlobal/backend-payment-go/internal/middleware"
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/redact"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// requestID returns the ID assigned to the request by the requestid
+// middleware, or the caller's X-Request-ID header.
+func requestID(c *fiber.Ctx) string {
+	if id, ok := c.Locals("requestid").(string); ok && id != "" {
+		return id
+	}
+	return c.Get(fiber.HeaderXRequestID)
+}
+
+// beginTransaction records txn as pending before the processor is called. The
+// charge must not proceed if it cannot be recorded, nor a capture or void that
+// the authorization's state does not allow. Without a ledger it only marks the
+// charge as attempted.
+func (h *PaymentHandler) beginTransaction(c *fiber.Ctx, txn *ledger.Transaction) error {
+	if h.ledger == nil {
+		middleware.MarkChargeAttempted(c)
+		return nil
+	}
+	if id := propertyID(c); id != "" {
+		txn.PropertyID, _ = strconv.ParseInt(id, 10, 64)
+	}
+	txn.RequestID = requestID(c)
+	err := h.ledger.Create(c.Context(), txn)
+	switch {
+	case err == nil:
+		middleware.MarkChargeAttempted(c)
+		return nil
+	case errors.Is(err, ledger.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "transaction not found")
+	case errors.Is(err, ledger.ErrInvalidState):
+		return fiber.NewError(fiber.StatusConflict, strings.TrimPrefix(err.Error(), "ledger: "))
+	case errors.Is(err, ledger.ErrAmountExceeded):
+		return fiber.NewError(fiber.StatusUnprocessableEntity, strings.TrimPrefix(err.Error(), "ledger: "))
+	}
+	log.Printf("ledger: record %s: %v", txn.Operation, err)
+	return fiber.NewError(fiber.StatusServiceUnavailable, "failed to record transaction")
+}
+
+// completeTransaction records the outcome of a charge. The charge has already
+// happened, so failures are logged rather than returned.
+func (h *PaymentHandler) completeTransaction(c *fiber.Ctx, txn *ledger.Transaction, result *types.ChargeResult) {
+	if h.ledger == nil {
+		return
+	}
+	result.ID = txn.ID
+	if err := h.ledger.Complete(c.Context(), txn.ID, ledger.OutcomeOf(result)); err != nil {
+		log.Printf("ledger: record outcome of %s: %v", txn.ID, err)
+	}
+}
+
+// failTransaction records that the processor call for txn failed with err, as
+// StatusUnknown when the operation may still have gone through.
+func (h *PaymentHandler) failTransaction(c *fiber.Ctx, txn *ledger.Transaction, err error) {
+	if h.ledger == nil {
+		return
+	}
+	status := ledger.StatusError
+	if chargeOutcomeUnknown(c, err) {
+		status = ledger.StatusUnknown
+	}
+	if lerr := h.ledger.Complete(c.Context(), txn.ID, ledger.Outcome{
+		Status:  status,
+		Message: redact.String(err.Error()),
+	}); lerr != nil {
+		log.Printf("ledger: record failure of %s: %v", txn.ID, lerr)
+	}
+}
+
+// chargeOutcomeUnknown reports whether a charge that failed with err may still
+// have gone through at the gateway: the processor was called, and the call
+// timed out, failed in transit or got a 5xx. Declines, rejected requests and
+// failures before the call are definite.
+func chargeOutcomeUnknown(c *fiber.Ctx, err error) bool {
+	if !middleware.ChargeAttempted(c) || errors.Is(err, processor.ErrNotSent) {
+		return false
+	}
+	var pe *processor.Error
+	if !errors.As(err, &pe) {
+		return false
+	}
+	return errors.Is(pe, processor.ErrUpstreamUnavailable) || pe.Kind == nil && pe.StatusCode == 0
+}
+
+// transactionResponse adds the decimal amount to a ledger transaction.
+type transactionResponse struct {
+	ledger.Transaction
+	Amount json.Number `json:"amount,omitempty"`
+}
+
+func newTransactionResponse(t ledger.Transaction) transactionResponse {
+	resp := transactionResponse{Transaction: t}
+	if m, err := money.New(t.AmountMinor, t.Currency); err == nil {
+		resp.Amount = json.Number(m.Decimal())
+	}
+	return resp
+}
+
+func (h *PaymentHandler) requireLedger() error {
+	if h.ledger == nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "transaction ledger unavailable")
+	}
+	return nil
+}
+
+// GetTransaction handles GET /v1/payments/transactions/:id.
+func (h *PaymentHandler) GetTransaction(c *fiber.Ctx) error {
+	txn, err := h.transaction(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(newTransactionResponse(*txn))
+}
+
+// transaction loads the transaction named by the :id path param. Under a
+// property scope only that property's transactions are found.
+func (h *PaymentHandler) transaction(c *fiber.Ctx) (*ledger.Transaction, error) {
+	if err := h.requireLedger(); err != nil {
+		return nil, err
+	}
+	property, err := propertyFilter(c)
+	if err != nil {
+		return nil, err
+	}
+
+	id := c.Params("id")
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fiber.NewError(fiber.StatusNotFound, "transaction not found")
+	}
+	txn, err := h.ledger.Get(c.Context(), id)
+	if errors.Is(err, ledger.ErrNotFound) || err == nil && property != 0 && txn.PropertyID != property {
+		return nil, fiber.NewError(fiber.StatusNotFound, "transaction not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return txn, nil
+}
+
+// ListTransactions handles GET /v1/payments/transactions.
+// Query parameters: operation, parent_id, status, mode, processor, gateway,
+// card_token, reservation_number, from and to (RFC 3339), limit and offset. Under a
+// property scope only that property's transactions are listed.
+func (h *PaymentHandler) ListTransactions(c *fiber.Ctx) error {
+	if err := h.requireLedger(); err != nil {
+		return err
+	}
+	property, err := propertyFilter(c)
+	if err != nil {
+		return err
+	}
+
+	f := ledger.Filter{
+		PropertyID:        property,
+		Operation:         ledger.Operation(c.Query("operation")),
+		Status:            ledger.Status(c.Query("status")),
+		Mode:              ledger.Mode(c.Query("mode")),
+		Processor:         c.Query("processor"),
+		Gateway:           c.Query("gateway"),
+		CardToken:         c.Query("card_token"),
+		ReservationNumber: c.Query("reservation_number"),
+	}
+	if v := c.Query("parent_id"); v != "" {
+		if _, err := uuid.Parse(v); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "parent_id must be a transaction id")
+		}
+		f.ParentID = v
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if v := c.Query(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, p.name+" must be an RFC 3339 timestamp")
+			}
+			*p.dst = t
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &f.Limit}, {"offset", &f.Offset}} {
+		if v := c.Query(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fiber.NewError(fiber.StatusBadRequest, p.name+" must be a non-negative integer")
+			}
+			*p.dst = n
+		}
+	}
+
+	txns, err := h.ledger.List(c.Context(), f)
+	if err != nil {
+		return err
+	}
+	resp := make([]transactionResponse, len(txns))
+	for i, t := range txns {
+		resp[i] = newTransactionResponse(t)
+	}
+	return c.JSON(fiber.Map{
+		"transactions": resp,
+		"offset":       f.Offset,
+	})
+}
+
+// propertyFilter returns the request's property ID, or 0 when unscoped.
+func propertyFilter(c *fiber.Ctx) (int64, error) {
+	id := propertyID(c)
+	if id == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "invalid property id")
+	}
+	return n, nil
+}
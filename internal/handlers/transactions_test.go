@@ -0,0 +1,152 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/handlers"
+	"github.com/CentraGlobal/backend-payment-go/internal/ledger"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/gofiber/fiber/v2"
+)
+
+func setupLedgerApp(mock *mockUPGProcessor, store ledger.Store) *fiber.App {
+	ph := handlers.NewPaymentHandler(processor.Static(mock), handlers.WithLedger(store))
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	payments := app.Group("/v1/payments")
+	payments.Post("/charge", ph.Charge)
+	payments.Get("/transactions", ph.ListTransactions)
+	payments.Get("/transactions/:id", ph.GetTransaction)
+	return app
+}
+
+func doJSON(t *testing.T, app *fiber.App, method, path, body string, headers map[string]string) (int, map[string]any) {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = bytes.NewBufferString(body)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	defer resp.Body.Close()
+	var out map[string]any
+	b, _ := io.ReadAll(resp.Body)
+	json.Unmarshal(b, &out)
+	return resp.StatusCode, out
+}
+
+func TestCharge_RecordsTransaction(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Rejected", TransactionID: "txn_1", Message: "declined"}}
+	store := ledger.NewMemoryStore()
+	app := setupLedgerApp(mock, store)
+
+	body := `{"card_token":"tok_1","amount":12.50,"currency":"EUR","gateway_name":"Stripe","credentials_id":"c","reservation_number":"RES-9"}`
+	status, result := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, map[string]string{
+		handlers.PropertyIDHeader: "42",
+		fiber.HeaderXRequestID:    "req-1",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, result)
+	}
+	id, _ := result["id"].(string)
+	if id == "" {
+		t.Fatalf("expected ledger id in charge response, got %v", result)
+	}
+
+	txn, err := store.Get(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if txn.Status != ledger.StatusRejected || txn.TransactionID != "txn_1" || txn.Message != "declined" {
+		t.Errorf("unexpected outcome: %+v", txn)
+	}
+	if txn.Mode != ledger.ModeUPG || txn.Processor != "mock" || txn.Gateway != "Stripe" {
+		t.Errorf("unexpected routing: %+v", txn)
+	}
+	if txn.PropertyID != 42 || txn.AmountMinor != 1250 || txn.Currency != "EUR" || txn.CardToken != "tok_1" {
+		t.Errorf("unexpected request fields: %+v", txn)
+	}
+	if txn.ReservationNumber != "RES-9" || txn.RequestID != "req-1" {
+		t.Errorf("unexpected references: %+v", txn)
+	}
+
+	status, got := doJSON(t, app, http.MethodGet, "/v1/payments/transactions/"+id, "", map[string]string{handlers.PropertyIDHeader: "42"})
+	if status != http.StatusOK || got["id"] != id || got["amount"] != 12.5 {
+		t.Errorf("GET transaction: %d %v", status, got)
+	}
+	status, _ = doJSON(t, app, http.MethodGet, "/v1/payments/transactions/"+id, "", map[string]string{handlers.PropertyIDHeader: "7"})
+	if status != http.StatusNotFound {
+		t.Errorf("expected 404 for another property's transaction, got %d", status)
+	}
+}
+
+func TestCharge_RecordsProcessorError(t *testing.T) {
+	mock := &mockUPGProcessor{err: processor.NewError(processor.ErrUpstreamUnavailable, "mock", fmt.Errorf("connection refused (%w)", processor.ErrNotSent))}
+	store := ledger.NewMemoryStore()
+	app := setupLedgerApp(mock, store)
+
+	body := `{"card_token":"tok_1","amount":1,"currency":"USD","gateway_name":"Stripe","credentials_id":"c"}`
+	if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", status)
+	}
+
+	txns, _ := store.List(context.Background(), ledger.Filter{})
+	if len(txns) != 1 || txns[0].Status != ledger.StatusError || txns[0].Message == "" {
+		t.Fatalf("expected one errored transaction, got %+v", txns)
+	}
+}
+
+func TestListTransactions_Filters(t *testing.T) {
+	mock := &mockUPGProcessor{charge: &processor.UPGChargeResponse{Status: "Success"}}
+	app := setupLedgerApp(mock, ledger.NewMemoryStore())
+
+	for _, tok := range []string{"tok_a", "tok_b", "tok_a"} {
+		body := `{"card_token":"` + tok + `","amount":1,"currency":"USD","gateway_name":"Stripe","credentials_id":"c"}`
+		if status, _ := doJSON(t, app, http.MethodPost, "/v1/payments/charge", body, map[string]string{handlers.PropertyIDHeader: "1"}); status != http.StatusOK {
+			t.Fatalf("charge: %d", status)
+		}
+	}
+
+	status, got := doJSON(t, app, http.MethodGet, "/v1/payments/transactions?card_token=tok_a&status=Success", "", nil)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+	if txns, _ := got["transactions"].([]any); len(txns) != 2 {
+		t.Errorf("expected 2 transactions for tok_a, got %v", got["transactions"])
+	}
+
+	status, got = doJSON(t, app, http.MethodGet, "/v1/payments/transactions?limit=1&offset=1", "", map[string]string{handlers.PropertyIDHeader: "1"})
+	if txns, _ := got["transactions"].([]any); status != http.StatusOK || len(txns) != 1 || got["offset"] != 1.0 {
+		t.Errorf("expected second page of one, got %d %v", status, got)
+	}
+
+	if status, _ := doJSON(t, app, http.MethodGet, "/v1/payments/transactions", "", map[string]string{handlers.PropertyIDHeader: "2"}); status != http.StatusOK {
+		t.Errorf("expected 200 for empty property, got %d", status)
+	}
+	if status, _ := doJSON(t, app, http.MethodGet, "/v1/payments/transactions?from=yesterday", "", nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad from, got %d", status)
+	}
+}
+
+func TestTransactions_NoLedger_Returns503(t *testing.T) {
+	ph := handlers.NewPaymentHandler(processor.Static(&mockUPGProcessor{}))
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Get("/v1/payments/transactions", ph.ListTransactions)
+
+	if status, _ := doJSON(t, app, http.MethodGet, "/v1/payments/transactions", "", nil); status != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", status)
+	}
+}
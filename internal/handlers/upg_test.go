@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -24,18 +25,36 @@ type mockUPGProcessor struct {
 	sendResp  *processor.SendResponse
 	err       error
 	sendErr   error
+	// caps overrides the declared capabilities; nil declares every capability.
+	caps processor.Capabilities
+	// calls counts invocations that reached the processor.
+	calls int
+	// lastTxn is the request of the last capture, void or refund.
+	lastTxn processor.UPGTransactionRequest
+	// lastCharge is the request of the last charge or pre-authorization.
+	lastCharge processor.UPGChargeRequest
+	// created and deleted record tokenized and deleted cards.
+	created []processor.Card
+	deleted []string
+	// registered and unregistered record the gateways of registered
+	// credentials and the credentials IDs deleted.
+	registered   []string
+	unregistered []string
 }
 
-func (m *mockUPGProcessor) CreateCard(_ context.Context, _ processor.Card) (*processor.CardResponse, error) {
-	return nil, errors.New("not implemented")
+func (m *mockUPGProcessor) CreateCard(_ context.Context, card processor.Card) (*processor.CardResponse, error) {
+	m.created = append(m.created, card)
+	return &processor.CardResponse{CardToken: "tok_created"}, nil
 }
 func (m *mockUPGProcessor) GetCard(_ context.Context, _ string) (*processor.CardResponse, error) {
 	return nil, errors.New("not implemented")
 }
-func (m *mockUPGProcessor) DeleteCard(_ context.Context, _ string) error {
-	return errors.New("not implemented")
+func (m *mockUPGProcessor) DeleteCard(_ context.Context, token string) error {
+	m.deleted = append(m.deleted, token)
+	return nil
 }
 func (m *mockUPGProcessor) SendCard(_ context.Context, _ string, _ processor.SendRequest) (*processor.SendResponse, error) {
+	m.calls++
 	return m.sendResp, m.sendErr
 }
 func (m *mockUPGProcessor) CreateSessionToken(_ context.Context, _ string) (*processor.SessionTokenResponse, error) {
@@ -43,23 +62,74 @@ func (m *mockUPGProcessor) CreateSessionToken(_ context.Context, _ string) (*pro
 }
 func (m *mockUPGProcessor) CaptureFormURL(_ string) string { return "" }
 func (m *mockUPGProcessor) Name() string                   { return "mock" }
+func (m *mockUPGProcessor) Capabilities() processor.Capabilities {
+	if m.caps != nil {
+		return m.caps
+	}
+	return processor.Capabilities{
+		processor.CapabilityTokenize,
+		processor.CapabilityCardRead,
+		processor.CapabilityCardDelete,
+		processor.CapabilityRelay,
+		processor.CapabilitySessionToken,
+		processor.CapabilityCaptureForm,
+		processor.CapabilityUPGGateways,
+		processor.CapabilityUPGCharge,
+		processor.CapabilityUPGAuthorize,
+		processor.CapabilityUPGCredentials,
+		processor.CapabilityRefund,
+	}
+}
 
 func (m *mockUPGProcessor) GetPaymentGateways(_ context.Context) ([]processor.GatewayInfo, error) {
+	m.calls++
 	return m.gateways, m.err
 }
 func (m *mockUPGProcessor) GetCredentialsStructure(_ context.Context, _ string) (map[string]any, error) {
+	m.calls++
 	return m.structure, m.err
 }
-func (m *mockUPGProcessor) ChargeUPG(_ context.Context, _ processor.UPGChargeRequest) (*processor.UPGChargeResponse, error) {
+func (m *mockUPGProcessor) CreateUPGCredentials(_ context.Context, gatewayName string, _ map[string]string) (string, error) {
+	m.registered = append(m.registered, gatewayName)
+	return fmt.Sprintf("creds-%d", len(m.registered)), nil
+}
+func (m *mockUPGProcessor) DeleteUPGCredentials(_ context.Context, credentialsID string) error {
+	m.unregistered = append(m.unregistered, credentialsID)
+	return nil
+}
+func (m *mockUPGProcessor) ChargeUPG(_ context.Context, req processor.UPGChargeRequest) (*processor.UPGChargeResponse, error) {
+	m.calls++
+	m.lastCharge = req
+	return m.charge, m.err
+}
+func (m *mockUPGProcessor) PreAuthorizeUPG(_ context.Context, req processor.UPGChargeRequest) (*processor.UPGChargeResponse, error) {
+	m.calls++
+	m.lastCharge = req
+	return m.charge, m.err
+}
+func (m *mockUPGProcessor) CaptureUPG(_ context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	m.calls++
+	m.lastTxn = req
+	return m.charge, m.err
+}
+func (m *mockUPGProcessor) RefundUPG(_ context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	m.calls++
+	m.lastTxn = req
+	return m.charge, m.err
+}
+func (m *mockUPGProcessor) VoidUPG(_ context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	m.calls++
+	m.lastTxn = req
 	return m.charge, m.err
 }
 
 func setupUnifiedApp(mock *mockUPGProcessor) *fiber.App {
-	ph := handlers.NewPaymentHandler(mock)
-	app := fiber.New()
+	ph := handlers.NewPaymentHandler(processor.Static(mock))
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
 	v1 := app.Group("/v1")
 	payments := v1.Group("/payments")
 	payments.Post("/charge", ph.Charge)
+	v1.Get("/capabilities", ph.GetCapabilities)
 	gateways := v1.Group("/upg/gateways")
 	gateways.Get("/", ph.GetGateways)
 	gateways.Get("/:name/structure", ph.GetGatewayStructure)
@@ -104,6 +174,15 @@ func TestCharge_UPG_Success(t *testing.T) {
 	if result["transaction_id"] != "txn_abc123" {
 		t.Errorf("expected transaction_id txn_abc123, got %v", result["transaction_id"])
 	}
+	if result["gateway"] != "Stripe" || result["processor"] != "mock" {
+		t.Errorf("expected gateway Stripe and processor mock, got %v", result)
+	}
+	if result["amount"] != 100.0 || result["amount_minor"] != 10000.0 || result["currency"] != "USD" {
+		t.Errorf("expected amount 100.00 USD, got %v (%v minor) %v", result["amount"], result["amount_minor"], result["currency"])
+	}
+	if _, ok := result["raw_response"]; ok {
+		t.Error("expected raw_response to be omitted by default")
+	}
 }
 
 func TestCharge_UPG_BadBody(t *testing.T) {
@@ -131,6 +210,9 @@ func TestCharge_UPG_MissingFields(t *testing.T) {
 		{"missing gateway_name", `{"card_token":"tok","amount":100,"currency":"USD","credentials_id":"c1"}`},
 		{"zero amount", `{"card_token":"tok","amount":0,"currency":"USD","gateway_name":"Stripe","credentials_id":"c1"}`},
 		{"negative amount", `{"card_token":"tok","amount":-10,"currency":"USD","gateway_name":"Stripe","credentials_id":"c1"}`},
+		{"unknown currency", `{"card_token":"tok","amount":100,"currency":"XYZ","gateway_name":"Stripe","credentials_id":"c1"}`},
+		{"sub-minor-unit amount", `{"card_token":"tok","amount":10.005,"currency":"EUR","gateway_name":"Stripe","credentials_id":"c1"}`},
+		{"fractional yen", `{"card_token":"tok","amount":100.5,"currency":"JPY","gateway_name":"Stripe","credentials_id":"c1"}`},
 	}
 
 	mock := &mockUPGProcessor{}
@@ -169,9 +251,9 @@ func TestCharge_UPG_ProcessorError(t *testing.T) {
 	}
 }
 
-func TestCharge_UPG_NotSupported_Returns503(t *testing.T) {
+func TestCharge_UPG_NotSupported_Returns501(t *testing.T) {
 	mock := &mockUPGProcessor{
-		err: errors.New("vaultera: UPG is not supported; use the pci_booking_upg provider instead"),
+		err: processor.Unsupported("vaultera", "UPG"),
 	}
 	app := setupUnifiedApp(mock)
 
@@ -185,16 +267,16 @@ func TestCharge_UPG_NotSupported_Returns503(t *testing.T) {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusServiceUnavailable {
-		t.Errorf("expected 503, got %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", resp.StatusCode)
 	}
 
 	respBody, _ := io.ReadAll(resp.Body)
 	var result map[string]any
 	json.Unmarshal(respBody, &result)
 
-	if result["error"] != "PROCESSOR_CONFIGURATION_MISMATCH" {
-		t.Errorf("expected PROCESSOR_CONFIGURATION_MISMATCH error code, got %v", result["error"])
+	if result["error"] != "UNSUPPORTED_OPERATION" {
+		t.Errorf("expected UNSUPPORTED_OPERATION error code, got %v", result["error"])
 	}
 }
 
@@ -284,6 +366,73 @@ func TestCharge_AutoDetect_URL_RoutesToRelay(t *testing.T) {
 	}
 }
 
+func TestCharge_Relay_NormalizedResult(t *testing.T) {
+	mock := &mockUPGProcessor{
+		sendResp: &processor.SendResponse{
+			StatusCode: http.StatusPaymentRequired,
+			Body:       []byte(`{"error":{"code":"card_declined","decline_code":"insufficient_funds","message":"Insufficient funds"},"card":{"number":"4242424242424242"}}`),
+		},
+	}
+	app := setupUnifiedApp(mock)
+
+	tests := []struct {
+		name       string
+		includeRaw bool
+	}{
+		{"without raw", false},
+		{"with raw", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]any{
+				"card_token":  "tok",
+				"url":         "https://api.gateway.test/charges",
+				"method":      "POST",
+				"amount":      25.5,
+				"currency":    "EUR",
+				"include_raw": tc.includeRaw,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/v1/payments/charge", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("app.Test: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected 200, got %d", resp.StatusCode)
+			}
+			var result map[string]any
+			json.NewDecoder(resp.Body).Decode(&result)
+
+			want := map[string]any{
+				"status":       "Rejected",
+				"decline_code": "insufficient_funds",
+				"message":      "Insufficient funds",
+				"gateway":      "api.gateway.test",
+				"processor":    "mock",
+				"amount":       25.5,
+				"currency":     "EUR",
+			}
+			for k, v := range want {
+				if result[k] != v {
+					t.Errorf("expected %s=%v, got %v", k, v, result[k])
+				}
+			}
+
+			raw, ok := result["raw_response"].(map[string]any)
+			if ok != tc.includeRaw {
+				t.Fatalf("raw_response present=%v, expected %v", ok, tc.includeRaw)
+			}
+			if ok && raw["card"].(map[string]any)["number"] != "[REDACTED]" {
+				t.Errorf("expected card number redacted, got %v", raw["card"])
+			}
+		})
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Gateway metadata endpoints
 // ---------------------------------------------------------------------------
@@ -320,9 +469,9 @@ func TestGetGateways_Success(t *testing.T) {
 	}
 }
 
-func TestGetGateways_UPGNotSupported_Returns503(t *testing.T) {
+func TestGetGateways_UPGNotSupported_Returns501(t *testing.T) {
 	mock := &mockUPGProcessor{
-		err: errors.New("vaultera: UPG is not supported; use the pci_booking_upg provider instead"),
+		err: processor.Unsupported("vaultera", "UPG"),
 	}
 	app := setupUnifiedApp(mock)
 
@@ -333,15 +482,15 @@ func TestGetGateways_UPGNotSupported_Returns503(t *testing.T) {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusServiceUnavailable {
-		t.Errorf("expected 503, got %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", resp.StatusCode)
 	}
 
 	respBody, _ := io.ReadAll(resp.Body)
 	var result map[string]any
 	json.Unmarshal(respBody, &result)
-	if result["error"] != "UPG_NOT_AVAILABLE" {
-		t.Errorf("expected UPG_NOT_AVAILABLE error code, got %v", result["error"])
+	if result["error"] != "UNSUPPORTED_OPERATION" {
+		t.Errorf("expected UNSUPPORTED_OPERATION error code, got %v", result["error"])
 	}
 }
 
@@ -389,9 +538,9 @@ func TestGetGatewayStructure_Success(t *testing.T) {
 	}
 }
 
-func TestGetGatewayStructure_UPGNotSupported_Returns503(t *testing.T) {
+func TestGetGatewayStructure_UPGNotSupported_Returns501(t *testing.T) {
 	mock := &mockUPGProcessor{
-		err: errors.New("vaultera: UPG is not supported; use the pci_booking_upg provider instead"),
+		err: processor.Unsupported("vaultera", "UPG"),
 	}
 	app := setupUnifiedApp(mock)
 
@@ -402,15 +551,15 @@ func TestGetGatewayStructure_UPGNotSupported_Returns503(t *testing.T) {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusServiceUnavailable {
-		t.Errorf("expected 503, got %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", resp.StatusCode)
 	}
 
 	respBody, _ := io.ReadAll(resp.Body)
 	var result map[string]any
 	json.Unmarshal(respBody, &result)
-	if result["error"] != "UPG_NOT_AVAILABLE" {
-		t.Errorf("expected UPG_NOT_AVAILABLE error code, got %v", result["error"])
+	if result["error"] != "UNSUPPORTED_OPERATION" {
+		t.Errorf("expected UNSUPPORTED_OPERATION error code, got %v", result["error"])
 	}
 }
 
@@ -428,3 +577,75 @@ func TestGetGatewayStructure_OtherError_Returns502(t *testing.T) {
 		t.Errorf("expected 502, got %d", resp.StatusCode)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Capabilities
+// ---------------------------------------------------------------------------
+
+func TestGetCapabilities(t *testing.T) {
+	mock := &mockUPGProcessor{caps: processor.Capabilities{processor.CapabilityTokenize, processor.CapabilityRelay}}
+	app := setupUnifiedApp(mock)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/v1/capabilities", nil))
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200, got %d", resp.StatusCode)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	var result struct {
+		Processor    string   `json:"processor"`
+		Capabilities []string `json:"capabilities"`
+	}
+	json.Unmarshal(body, &result)
+	if result.Processor != "mock" {
+		t.Errorf("expected processor mock, got %q", result.Processor)
+	}
+	if len(result.Capabilities) != 2 || result.Capabilities[0] != "tokenize" || result.Capabilities[1] != "relay" {
+		t.Errorf("unexpected capabilities %v", result.Capabilities)
+	}
+}
+
+func TestCharge_UPG_MissingCapability_RejectedUpFront(t *testing.T) {
+	mock := &mockUPGProcessor{caps: processor.Capabilities{processor.CapabilityRelay}}
+	app := setupUnifiedApp(mock)
+
+	body := `{"card_token":"tok","amount":100,"currency":"USD","gateway_name":"Stripe","credentials_id":"c1"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/payments/charge", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", resp.StatusCode)
+	}
+	if mock.calls != 0 {
+		t.Errorf("expected processor not to be called, got %d calls", mock.calls)
+	}
+}
+
+func TestGetGateways_MissingCapability_RejectedUpFront(t *testing.T) {
+	mock := &mockUPGProcessor{caps: processor.Capabilities{processor.CapabilityRelay}}
+	app := setupUnifiedApp(mock)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/v1/upg/gateways/", nil))
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", resp.StatusCode)
+	}
+	if mock.calls != 0 {
+		t.Errorf("expected processor not to be called, got %d calls", mock.calls)
+	}
+}
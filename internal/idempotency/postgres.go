@@ -0,0 +1,96 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore is a Store backed by the idempotency_keys table (see
+// migrations/0003_idempotency_keys.sql).
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+// NewPostgresStore creates a PostgresStore backed by the given pool.
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+func (p *PostgresStore) Lock(ctx context.Context, key, requestHash string, ttl time.Duration) (Record, bool, error) {
+	// Insert the key, or take over an expired one.
+	tag, err := p.pool.Exec(ctx,
+		`INSERT INTO idempotency_keys (key, request_hash, state, expires_at)
+		 VALUES ($1, $2, $3, now() + make_interval(secs => $4))
+		 ON CONFLICT (key) DO UPDATE SET
+		     request_hash = EXCLUDED.request_hash,
+		     state        = EXCLUDED.state,
+		     status_code  = NULL,
+		     content_type = NULL,
+		     body         = NULL,
+		     expires_at   = EXCLUDED.expires_at,
+		     created_at   = now()
+		 WHERE idempotency_keys.expires_at <= now()`,
+		key, requestHash, StateInFlight, ttl.Seconds(),
+	)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("idempotency: lock key: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return Record{}, true, nil
+	}
+
+	var rec Record
+	var status *int
+	var contentType *string
+	err = p.pool.QueryRow(ctx,
+		`SELECT request_hash, state, status_code, content_type, body
+		 FROM idempotency_keys WHERE key = $1`,
+		key,
+	).Scan(&rec.RequestHash, &rec.State, &status, &contentType, &rec.Body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Record{}, false, errors.New("idempotency: lock key: key released while locking")
+	}
+	if err != nil {
+		return Record{}, false, fmt.Errorf("idempotency: load key: %w", err)
+	}
+	if status != nil {
+		rec.StatusCode = *status
+	}
+	if contentType != nil {
+		rec.ContentType = *contentType
+	}
+	return rec, false, nil
+}
+
+func (p *PostgresStore) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	_, err := p.pool.Exec(ctx,
+		`INSERT INTO idempotency_keys (key, request_hash, state, status_code, content_type, body, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, now() + make_interval(secs => $7))
+		 ON CONFLICT (key) DO UPDATE SET
+		     request_hash = EXCLUDED.request_hash,
+		     state        = EXCLUDED.state,
+		     status_code  = EXCLUDED.status_code,
+		     content_type = EXCLUDED.content_type,
+		     body         = EXCLUDED.body,
+		     expires_at   = EXCLUDED.expires_at`,
+		key, rec.RequestHash, rec.State, rec.StatusCode, rec.ContentType, rec.Body, ttl.Seconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("idempotency: store record: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) Release(ctx context.Context, key string) error {
+	if _, err := p.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("idempotency: release key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces idempotency keys in Redis.
+const redisKeyPrefix = "idempotency:"
+
+// RedisStore is a Store backed by Redis.
+type RedisStore struct {
+	rdb *goredis.Client
+}
+
+var _ Store = (*RedisStore)(nil)
+
+// NewRedisStore creates a RedisStore.
+func NewRedisStore(rdb *goredis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+func (r *RedisStore) Lock(ctx context.Context, key, requestHash string, ttl time.Duration) (Record, bool, error) {
+	b, err := json.Marshal(Record{RequestHash: requestHash, State: StateInFlight})
+	if err != nil {
+		return Record{}, false, fmt.Errorf("idempotency: marshal record: %w", err)
+	}
+
+	// The existing record can expire between SETNX and GET; try again then.
+	for attempt := 0; attempt < 2; attempt++ {
+		ok, err := r.rdb.SetNX(ctx, redisKeyPrefix+key, b, ttl).Result()
+		if err != nil {
+			return Record{}, false, fmt.Errorf("idempotency: lock key: %w", err)
+		}
+		if ok {
+			return Record{}, true, nil
+		}
+
+		existing, err := r.rdb.Get(ctx, redisKeyPrefix+key).Bytes()
+		if errors.Is(err, goredis.Nil) {
+			continue
+		}
+		if err != nil {
+			return Record{}, false, fmt.Errorf("idempotency: load key: %w", err)
+		}
+		var rec Record
+		if err := json.Unmarshal(existing, &rec); err != nil {
+			return Record{}, false, fmt.Errorf("idempotency: decode record: %w", err)
+		}
+		return rec, false, nil
+	}
+	return Record{}, false, errors.New("idempotency: lock key: key expired repeatedly while locking")
+}
+
+func (r *RedisStore) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("idempotency: marshal record: %w", err)
+	}
+	if err := r.rdb.Set(ctx, redisKeyPrefix+key, b, ttl).Err(); err != nil {
+		return fmt.Errorf("idempotency: store record: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStore) Release(ctx context.Context, key string) error {
+	if err := r.rdb.Del(ctx, redisKeyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("idempotency: release key: %w", err)
+	}
+	return nil
+}
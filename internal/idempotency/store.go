@@ -0,0 +1,163 @@
+// Package idempotency stores the outcome of requests made with an
+// Idempotency-Key so that retried requests are replayed instead of executed
+// twice.
+package idempotency
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// State is the state of an idempotency key.
+type State string
+
+const (
+	// StateInFlight marks a key whose request is still being processed.
+	StateInFlight State = "in_flight"
+	// StateCompleted marks a key whose response has been stored.
+	StateCompleted State = "completed"
+)
+
+// Record is the stored state of an idempotency key.
+type Record struct {
+	// RequestHash identifies the request the key was first used with.
+	RequestHash string `json:"request_hash"`
+	State       State  `json:"state"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Store persists idempotency records.
+type Store interface {
+	// Lock atomically creates an in-flight record for key that expires after
+	// ttl. If the key is already in use it returns the existing record and
+	// false.
+	Lock(ctx context.Context, key, requestHash string, ttl time.Duration) (Record, bool, error)
+	// Complete replaces key's record with rec, kept for ttl.
+	Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error
+	// Release deletes key so the request can be retried with the same key.
+	Release(ctx context.Context, key string) error
+}
+
+// FallbackStore keeps every key in both a primary store (Redis) and a
+// secondary store (Postgres), so that neither a primary outage nor a lost
+// primary record lets a completed request run again.
+//
+// Lock takes the key in both stores and reports an existing record from
+// either. Complete and Release apply to both. A call fails only if every store
+// fails; while the fallback is down keys are kept in the primary alone, and
+// while the primary is down in the fallback alone.
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+}
+
+var _ Store = (*FallbackStore)(nil)
+
+// NewFallbackStore creates a FallbackStore. fallback may be nil.
+func NewFallbackStore(primary, fallback Store) *FallbackStore {
+	return &FallbackStore{primary: primary, fallback: fallback}
+}
+
+func (f *FallbackStore) Lock(ctx context.Context, key, requestHash string, ttl time.Duration) (Record, bool, error) {
+	rec, ok, err := f.primary.Lock(ctx, key, requestHash, ttl)
+	if f.fallback == nil || (err == nil && !ok) {
+		return rec, ok, err
+	}
+	if err != nil {
+		log.Printf("idempotency: primary store failed, using fallback: %v", err)
+		return f.fallback.Lock(ctx, key, requestHash, ttl)
+	}
+
+	rec, ok, err = f.fallback.Lock(ctx, key, requestHash, ttl)
+	if err != nil {
+		log.Printf("idempotency: fallback store failed, using primary only: %v", err)
+		return Record{}, true, nil
+	}
+	if !ok {
+		// The primary lost the key, e.g. after a flush: the fallback's record
+		// stands, and the primary's new lock is dropped.
+		if err := f.primary.Release(ctx, key); err != nil {
+			log.Printf("idempotency: release primary lock: %v", err)
+		}
+	}
+	return rec, ok, nil
+}
+
+func (f *FallbackStore) Complete(ctx context.Context, key string, rec Record, ttl time.Duration) error {
+	return f.both(func(s Store) error { return s.Complete(ctx, key, rec, ttl) })
+}
+
+func (f *FallbackStore) Release(ctx context.Context, key string) error {
+	return f.both(func(s Store) error { return s.Release(ctx, key) })
+}
+
+// both applies call to the primary and fallback stores, failing only if
+// every store fails.
+func (f *FallbackStore) both(call func(Store) error) error {
+	err := call(f.primary)
+	if f.fallback == nil {
+		return err
+	}
+	if err != nil {
+		log.Printf("idempotency: primary store failed, using fallback: %v", err)
+	}
+	fallbackErr := call(f.fallback)
+	if fallbackErr != nil {
+		log.Printf("idempotency: fallback store failed: %v", fallbackErr)
+		if err != nil {
+			return fallbackErr
+		}
+	}
+	return nil
+}
+
+// MemoryStore is a Store held in process memory, for tests and single-instance
+// development.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]memoryRecord
+	now     func() time.Time
+}
+
+type memoryRecord struct {
+	Record
+	expiresAt time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]memoryRecord{}, now: time.Now}
+}
+
+func (m *MemoryStore) Lock(_ context.Context, key, requestHash string, ttl time.Duration) (Record, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.records[key]; ok && m.now().Before(r.expiresAt) {
+		return r.Record, false, nil
+	}
+	m.records[key] = memoryRecord{
+		Record:    Record{RequestHash: requestHash, State: StateInFlight},
+		expiresAt: m.now().Add(ttl),
+	}
+	return Record{}, true, nil
+}
+
+func (m *MemoryStore) Complete(_ context.Context, key string, rec Record, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[key] = memoryRecord{Record: rec, expiresAt: m.now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryStore) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, key)
+	return nil
+}
@@ -0,0 +1,110 @@
+package idempotency_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/idempotency"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// testStore exercises the Store contract against s.
+func testStore(t *testing.T, s idempotency.Store) {
+	t.Helper()
+	ctx := context.Background()
+	key := "test:" + time.Now().Format(time.RFC3339Nano)
+	defer s.Release(ctx, key)
+
+	if _, locked, err := s.Lock(ctx, key, "hash-1", time.Minute); err != nil || !locked {
+		t.Fatalf("first Lock: locked=%v err=%v", locked, err)
+	}
+	rec, locked, err := s.Lock(ctx, key, "hash-1", time.Minute)
+	if err != nil || locked {
+		t.Fatalf("second Lock: locked=%v err=%v", locked, err)
+	}
+	if rec.State != idempotency.StateInFlight || rec.RequestHash != "hash-1" {
+		t.Errorf("expected in-flight record, got %+v", rec)
+	}
+
+	done := idempotency.Record{
+		RequestHash: "hash-1",
+		State:       idempotency.StateCompleted,
+		StatusCode:  201,
+		ContentType: "application/json",
+		Body:        []byte(`{"ok":true}`),
+	}
+	if err := s.Complete(ctx, key, done, time.Minute); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	rec, _, err = s.Lock(ctx, key, "hash-2", time.Minute)
+	if err != nil {
+		t.Fatalf("Lock after Complete: %v", err)
+	}
+	if rec.State != idempotency.StateCompleted || rec.StatusCode != 201 || string(rec.Body) != `{"ok":true}` {
+		t.Errorf("expected completed record, got %+v", rec)
+	}
+
+	if err := s.Release(ctx, key); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if _, locked, err := s.Lock(ctx, key, "hash-2", time.Minute); err != nil || !locked {
+		t.Errorf("Lock after Release: locked=%v err=%v", locked, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, idempotency.NewMemoryStore())
+}
+
+func TestMemoryStore_LockExpires(t *testing.T) {
+	s := idempotency.NewMemoryStore()
+	ctx := context.Background()
+	s.Lock(ctx, "key", "hash", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if _, locked, _ := s.Lock(ctx, "key", "hash", time.Minute); !locked {
+		t.Error("expected an expired lock to be taken over")
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	rdb := goredis.NewClient(&goredis.Options{Addr: "localhost:6379"})
+	defer rdb.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+
+	testStore(t, idempotency.NewRedisStore(rdb))
+}
+
+func TestFallbackStore(t *testing.T) {
+	testStore(t, idempotency.NewFallbackStore(idempotency.NewMemoryStore(), idempotency.NewMemoryStore()))
+}
+
+// TestFallbackStore_PrimaryLosesRecord verifies that a completed request is
+// still replayed when the primary loses its record, e.g. after a Redis flush.
+func TestFallbackStore_PrimaryLosesRecord(t *testing.T) {
+	ctx := context.Background()
+	primary := idempotency.NewMemoryStore()
+	s := idempotency.NewFallbackStore(primary, idempotency.NewMemoryStore())
+
+	if _, locked, err := s.Lock(ctx, "key", "hash", time.Minute); err != nil || !locked {
+		t.Fatalf("Lock: locked=%v err=%v", locked, err)
+	}
+	done := idempotency.Record{RequestHash: "hash", State: idempotency.StateCompleted, StatusCode: 201}
+	if err := s.Complete(ctx, "key", done, time.Minute); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	primary.Release(ctx, "key")
+
+	rec, locked, err := s.Lock(ctx, "key", "hash", time.Minute)
+	if err != nil || locked {
+		t.Fatalf("expected the key to stay taken, got locked=%v err=%v", locked, err)
+	}
+	if rec.State != idempotency.StateCompleted || rec.StatusCode != 201 {
+		t.Errorf("expected the completed record from the fallback, got %+v", rec)
+	}
+}
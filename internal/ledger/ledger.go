@@ -0,0 +1,288 @@
+// Package ledger records every charge attempt made through the service in the
+// primary database, before and after the processor is called.
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound is returned when a transaction does not exist.
+	ErrNotFound = errors.New("ledger: transaction not found")
+	// ErrInvalidState is returned when an operation is not allowed in the
+	// current state of the transaction it applies to, such as capturing a
+	// voided authorization.
+	ErrInvalidState = errors.New("ledger: invalid transaction state")
+	// ErrAmountExceeded is returned when an operation would take the
+	// cumulative amount past what the original transaction allows.
+	ErrAmountExceeded = errors.New("ledger: amount exceeds the remaining amount")
+)
+
+// Mode is how a charge was sent to the processor.
+type Mode string
+
+const (
+	ModeUPG   Mode = "upg"
+	ModeRelay Mode = "relay"
+)
+
+// Operation is what a transaction did.
+type Operation string
+
+const (
+	OperationCharge    Operation = "charge"
+	OperationAuthorize Operation = "authorize"
+	OperationCapture   Operation = "capture"
+	OperationVoid      Operation = "void"
+	OperationRefund    Operation = "refund"
+)
+
+// Status is the state of a recorded transaction: StatusPending while the
+// processor is being called, StatusError when the call failed without a
+// gateway outcome, StatusUnknown when it failed in a way that leaves the
+// outcome unknown (a timeout, a transport failure or a 5xx), and otherwise the
+// normalized charge status.
+type Status string
+
+const (
+	StatusPending          Status = "Pending"
+	StatusError            Status = "Error"
+	StatusUnknown          Status = "Unknown"
+	StatusAccepted                = Status(types.UPGStatusAccepted)
+	StatusSuccess                 = Status(types.UPGStatusSuccess)
+	StatusRejected                = Status(types.UPGStatusRejected)
+	StatusTemporaryFailure        = Status(types.UPGStatusTemporaryFailure)
+	StatusFatalFailure            = Status(types.UPGStatusFatalFailure)
+)
+
+// Transaction is a recorded charge attempt.
+type Transaction struct {
+	ID         string    `json:"id"`
+	PropertyID int64     `json:"property_id,omitempty"`
+	Operation  Operation `json:"operation"`
+	// ParentID is the transaction a capture, void or refund applies to.
+	ParentID      string `json:"parent_id,omitempty"`
+	Mode          Mode   `json:"mode"`
+	Processor     string `json:"processor"`
+	Gateway       string `json:"gateway,omitempty"`
+	CredentialsID string `json:"credentials_id,omitempty"`
+	// AmountMinor is in minor units of Currency; both are empty for relay
+	// charges made without an amount.
+	AmountMinor int64  `json:"amount_minor,omitempty"`
+	Currency    string `json:"currency,omitempty"`
+	Status      Status `json:"status"`
+	// TransactionID is the gateway's or processor's transaction ID.
+	TransactionID     string    `json:"transaction_id,omitempty"`
+	DeclineCode       string    `json:"decline_code,omitempty"`
+	Message           string    `json:"message,omitempty"`
+	CardToken         string    `json:"card_token"`
+	ReservationNumber string    `json:"reservation_number,omitempty"`
+	RequestID         string    `json:"request_id,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// Outcome is the result of the processor call for a pending transaction.
+type Outcome struct {
+	Status        Status
+	Processor     string
+	Gateway       string
+	TransactionID string
+	DeclineCode   string
+	Message       string
+}
+
+// OutcomeOf returns the Outcome for a normalized charge result.
+func OutcomeOf(r *types.ChargeResult) Outcome {
+	return Outcome{
+		Status:        Status(r.Status),
+		Processor:     r.Processor,
+		Gateway:       r.Gateway,
+		TransactionID: r.TransactionID,
+		DeclineCode:   r.DeclineCode,
+		Message:       r.Message,
+	}
+}
+
+// Filter selects transactions to list. Zero-valued fields are ignored.
+type Filter struct {
+	PropertyID        int64
+	Operation         Operation
+	ParentID          string
+	Status            Status
+	Mode              Mode
+	Processor         string
+	Gateway           string
+	CardToken         string
+	ReservationNumber string
+	// From and To bound CreatedAt, inclusive and exclusive respectively.
+	From time.Time
+	To   time.Time
+	// Limit defaults to DefaultLimit and is capped at MaxLimit.
+	Limit  int
+	Offset int
+}
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// limit returns the effective page size.
+func (f Filter) limit() int {
+	switch {
+	case f.Limit <= 0:
+		return DefaultLimit
+	case f.Limit > MaxLimit:
+		return MaxLimit
+	}
+	return f.Limit
+}
+
+// Store persists transactions.
+type Store interface {
+	// Create records txn as pending, assigning its ID and timestamps. A
+	// transaction with a ParentID is checked against the parent's earlier
+	// follow-ups atomically (see checkFollowUp), so concurrent captures or
+	// refunds cannot together exceed the authorized or captured amount.
+	Create(ctx context.Context, txn *Transaction) error
+	// Complete records the outcome of a pending transaction.
+	Complete(ctx context.Context, id string, o Outcome) error
+	// Get returns a transaction or ErrNotFound.
+	Get(ctx context.Context, id string) (*Transaction, error)
+	// List returns transactions matching f, newest first.
+	List(ctx context.Context, f Filter) ([]Transaction, error)
+}
+
+// holds reports whether a follow-up in status s counts against its parent:
+// pending, accepted and unknown operations may still succeed. An unknown one
+// counts until its outcome is recorded with Complete.
+func (s Status) holds() bool {
+	return s == StatusPending || s == StatusAccepted || s == StatusSuccess || s == StatusUnknown
+}
+
+// followUps summarizes the operations recorded against a transaction.
+type followUps struct {
+	// captured counts captures that may still succeed; settled only those
+	// that did.
+	captured int64
+	settled  int64
+	refunded int64
+	voided   bool
+}
+
+func (f *followUps) add(t Transaction) {
+	if !t.Status.holds() {
+		return
+	}
+	switch t.Operation {
+	case OperationCapture:
+		f.captured += t.AmountMinor
+		if t.Status == StatusSuccess {
+			f.settled += t.AmountMinor
+		}
+	case OperationRefund:
+		f.refunded += t.AmountMinor
+	case OperationVoid:
+		f.voided = true
+	}
+}
+
+// checkFollowUp reports whether txn may be recorded against parent given the
+// parent's earlier follow-ups.
+func checkFollowUp(parent Transaction, prior followUps, txn *Transaction) error {
+	switch txn.Operation {
+	case OperationCapture, OperationVoid:
+		return checkAuthorizationFollowUp(parent, prior, txn)
+	case OperationRefund:
+		return checkRefund(parent, prior, txn)
+	}
+	return fmt.Errorf("%w: %s is not a follow-up operation", ErrInvalidState, txn.Operation)
+}
+
+// checkAuthorizationFollowUp allows captures and voids of successful
+// authorizations only; captures may not exceed the authorized amount in
+// total, and nothing follows a void.
+func checkAuthorizationFollowUp(parent Transaction, prior followUps, txn *Transaction) error {
+	if parent.Operation != OperationAuthorize {
+		return fmt.Errorf("%w: only authorizations can be captured or voided", ErrInvalidState)
+	}
+	if parent.Status != StatusSuccess {
+		return fmt.Errorf("%w: authorization is %s", ErrInvalidState, parent.Status)
+	}
+	if prior.voided {
+		return fmt.Errorf("%w: authorization has been voided", ErrInvalidState)
+	}
+	if txn.Operation == OperationVoid {
+		if prior.captured >= parent.AmountMinor {
+			return fmt.Errorf("%w: authorization has been fully captured", ErrInvalidState)
+		}
+		return nil
+	}
+	if err := checkCurrency(parent, txn); err != nil {
+		return err
+	}
+	if remaining := parent.AmountMinor - prior.captured; txn.AmountMinor > remaining {
+		return fmt.Errorf("%w: %s of %s remains to be captured", ErrAmountExceeded,
+			formatMinor(remaining, parent.Currency), formatMinor(parent.AmountMinor, parent.Currency))
+	}
+	return nil
+}
+
+// checkRefund allows refunds of successful charges and of the settled
+// captures of an authorization; refunds, including pending ones, may not
+// exceed the captured amount in total.
+func checkRefund(parent Transaction, prior followUps, txn *Transaction) error {
+	var captured int64
+	switch parent.Operation {
+	case OperationCharge:
+		if parent.Status != StatusSuccess {
+			return fmt.Errorf("%w: charge is %s", ErrInvalidState, parent.Status)
+		}
+		if parent.Currency == "" {
+			return fmt.Errorf("%w: charge was made without an amount", ErrInvalidState)
+		}
+		captured = parent.AmountMinor
+	case OperationAuthorize:
+		captured = prior.settled
+	default:
+		return fmt.Errorf("%w: only charges and authorizations can be refunded", ErrInvalidState)
+	}
+	if err := checkCurrency(parent, txn); err != nil {
+		return err
+	}
+	if remaining := captured - prior.refunded; txn.AmountMinor > remaining {
+		return fmt.Errorf("%w: %s of %s captured remains to be refunded", ErrAmountExceeded,
+			formatMinor(remaining, parent.Currency), formatMinor(captured, parent.Currency))
+	}
+	return nil
+}
+
+func checkCurrency(parent Transaction, txn *Transaction) error {
+	if txn.Currency != parent.Currency {
+		return fmt.Errorf("%w: %s currency %s does not match %s currency %s",
+			ErrInvalidState, txn.Operation, txn.Currency, parent.Operation, parent.Currency)
+	}
+	return nil
+}
+
+// formatMinor formats an amount for error messages, e.g. "10.50 EUR".
+func formatMinor(minor int64, currency string) string {
+	m, err := money.New(minor, currency)
+	if err != nil {
+		return fmt.Sprintf("%d %s", minor, currency)
+	}
+	return m.String()
+}
+
+// newID returns a new transaction ID.
+func newID() string {
+	return uuid.NewString()
+}
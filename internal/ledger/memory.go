@@ -0,0 +1,124 @@
+package ledger
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store held in process memory, for tests and development
+// without a database.
+type MemoryStore struct {
+	mu   sync.Mutex
+	txns map[string]Transaction
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{txns: map[string]Transaction{}}
+}
+
+func (m *MemoryStore) Create(_ context.Context, txn *Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if txn.ParentID != "" {
+		parent, ok := m.txns[txn.ParentID]
+		if !ok {
+			return ErrNotFound
+		}
+		var prior followUps
+		for _, t := range m.txns {
+			if t.ParentID == txn.ParentID {
+				prior.add(t)
+			}
+		}
+		if err := checkFollowUp(parent, prior, txn); err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	txn.ID = newID()
+	txn.Status = StatusPending
+	txn.CreatedAt, txn.UpdatedAt = now, now
+	m.txns[txn.ID] = *txn
+	return nil
+}
+
+func (m *MemoryStore) Complete(_ context.Context, id string, o Outcome) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.txns[id]
+	if !ok {
+		return ErrNotFound
+	}
+	t.Status = o.Status
+	if o.Processor != "" {
+		t.Processor = o.Processor
+	}
+	if o.Gateway != "" {
+		t.Gateway = o.Gateway
+	}
+	t.TransactionID = o.TransactionID
+	t.DeclineCode = o.DeclineCode
+	t.Message = o.Message
+	t.UpdatedAt = time.Now()
+	m.txns[id] = t
+	return nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, id string) (*Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.txns[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &t, nil
+}
+
+func (m *MemoryStore) List(_ context.Context, f Filter) ([]Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	txns := []Transaction{}
+	for _, t := range m.txns {
+		if f.matches(t) {
+			txns = append(txns, t)
+		}
+	}
+	sort.Slice(txns, func(i, j int) bool {
+		if !txns[i].CreatedAt.Equal(txns[j].CreatedAt) {
+			return txns[i].CreatedAt.After(txns[j].CreatedAt)
+		}
+		return txns[i].ID < txns[j].ID
+	})
+
+	if f.Offset >= len(txns) {
+		return []Transaction{}, nil
+	}
+	txns = txns[f.Offset:]
+	if len(txns) > f.limit() {
+		txns = txns[:f.limit()]
+	}
+	return txns, nil
+}
+
+func (f Filter) matches(t Transaction) bool {
+	switch {
+	case f.PropertyID != 0 && t.PropertyID != f.PropertyID,
+		f.Operation != "" && t.Operation != f.Operation,
+		f.ParentID != "" && t.ParentID != f.ParentID,
+		f.Status != "" && t.Status != f.Status,
+		f.Mode != "" && t.Mode != f.Mode,
+		f.Processor != "" && t.Processor != f.Processor,
+		f.Gateway != "" && t.Gateway != f.Gateway,
+		f.CardToken != "" && t.CardToken != f.CardToken,
+		f.ReservationNumber != "" && t.ReservationNumber != f.ReservationNumber,
+		!f.From.IsZero() && t.CreatedAt.Before(f.From),
+		!f.To.IsZero() && !t.CreatedAt.Before(f.To):
+		return false
+	}
+	return true
+}
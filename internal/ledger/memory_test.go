@@ -0,0 +1,103 @@
+package ledger_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/ledger"
+)
+
+func TestMemoryStore_CreateComplete(t *testing.T) {
+	ctx := context.Background()
+	s := ledger.NewMemoryStore()
+
+	txn := &ledger.Transaction{Mode: ledger.ModeUPG, Processor: "pcibooking", AmountMinor: 500, Currency: "USD", CardToken: "tok"}
+	if err := s.Create(ctx, txn); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if txn.ID == "" || txn.Status != ledger.StatusPending || txn.CreatedAt.IsZero() {
+		t.Fatalf("expected pending transaction with ID, got %+v", txn)
+	}
+
+	err := s.Complete(ctx, txn.ID, ledger.Outcome{Status: ledger.StatusSuccess, Processor: "vaultera", TransactionID: "t1"})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	got, err := s.Get(ctx, txn.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Status != ledger.StatusSuccess || got.Processor != "vaultera" || got.TransactionID != "t1" {
+		t.Errorf("unexpected transaction after Complete: %+v", got)
+	}
+
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, ledger.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := s.Complete(ctx, "missing", ledger.Outcome{}); !errors.Is(err, ledger.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemoryStore_List(t *testing.T) {
+	ctx := context.Background()
+	s := ledger.NewMemoryStore()
+	for i, p := range []int64{1, 2, 1, 1} {
+		if err := s.Create(ctx, &ledger.Transaction{PropertyID: p, Mode: ledger.ModeRelay, CardToken: "tok"}); err != nil {
+			t.Fatalf("Create %d: %v", i, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	all, _ := s.List(ctx, ledger.Filter{PropertyID: 1})
+	if len(all) != 3 {
+		t.Fatalf("expected 3 transactions for property 1, got %d", len(all))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].CreatedAt.After(all[i-1].CreatedAt) {
+			t.Errorf("expected newest first, got %v before %v", all[i-1].CreatedAt, all[i].CreatedAt)
+		}
+	}
+
+	page, _ := s.List(ctx, ledger.Filter{PropertyID: 1, Limit: 2, Offset: 2})
+	if len(page) != 1 || page[0].ID != all[2].ID {
+		t.Errorf("expected last transaction on second page, got %+v", page)
+	}
+
+	none, _ := s.List(ctx, ledger.Filter{To: all[2].CreatedAt})
+	if len(none) != 0 {
+		t.Errorf("expected no transactions before the first, got %d", len(none))
+	}
+	pending, _ := s.List(ctx, ledger.Filter{Status: ledger.StatusPending, Mode: ledger.ModeRelay})
+	if len(pending) != 4 {
+		t.Errorf("expected 4 pending relay transactions, got %d", len(pending))
+	}
+}
+
+func TestMemoryStore_UnknownRefundCounts(t *testing.T) {
+	ctx := context.Background()
+	s := ledger.NewMemoryStore()
+
+	charge := &ledger.Transaction{Operation: ledger.OperationCharge, Mode: ledger.ModeUPG, AmountMinor: 500, Currency: "USD", CardToken: "tok"}
+	s.Create(ctx, charge)
+	s.Complete(ctx, charge.ID, ledger.Outcome{Status: ledger.StatusSuccess})
+
+	refund := &ledger.Transaction{Operation: ledger.OperationRefund, ParentID: charge.ID, Mode: ledger.ModeUPG, AmountMinor: 500, Currency: "USD", CardToken: "tok"}
+	if err := s.Create(ctx, refund); err != nil {
+		t.Fatalf("Create refund: %v", err)
+	}
+	s.Complete(ctx, refund.ID, ledger.Outcome{Status: ledger.StatusUnknown})
+
+	again := &ledger.Transaction{Operation: ledger.OperationRefund, ParentID: charge.ID, Mode: ledger.ModeUPG, AmountMinor: 500, Currency: "USD", CardToken: "tok"}
+	if err := s.Create(ctx, again); !errors.Is(err, ledger.ErrAmountExceeded) {
+		t.Fatalf("expected ErrAmountExceeded after a refund with unknown outcome, got %v", err)
+	}
+
+	// Once the refund is found to have failed, the amount is refundable again.
+	s.Complete(ctx, refund.ID, ledger.Outcome{Status: ledger.StatusError})
+	if err := s.Create(ctx, again); err != nil {
+		t.Errorf("expected refund after the unknown one was resolved as failed, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore is a Store backed by the transactions table (see
+// migrations/0004_transactions.sql and 0005_transaction_operations.sql).
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+// NewPostgresStore creates a PostgresStore backed by the given pool.
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+const transactionColumns = `id, COALESCE(property_id, 0), operation, COALESCE(parent_id::text, ''), mode,
+	processor, gateway, credentials_id, amount_minor, currency, status, transaction_id, decline_code, message,
+	card_token, reservation_number, request_id, created_at, updated_at`
+
+// queryRower is satisfied by both the pool and a transaction.
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Create inserts txn. A follow-up is checked and inserted in one database
+// transaction that locks the parent row, serializing follow-ups of the same
+// parent.
+func (p *PostgresStore) Create(ctx context.Context, txn *Transaction) error {
+	if txn.ParentID == "" {
+		return insertTransaction(ctx, p.pool, txn)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("ledger: begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE id = $1 FOR UPDATE`, txn.ParentID)
+	parent, err := scanTransaction(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("ledger: lock parent transaction: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT operation, status, amount_minor FROM transactions WHERE parent_id = $1`, txn.ParentID)
+	if err != nil {
+		return fmt.Errorf("ledger: list follow-ups: %w", err)
+	}
+	var prior followUps
+	for rows.Next() {
+		var t Transaction
+		if err := rows.Scan(&t.Operation, &t.Status, &t.AmountMinor); err != nil {
+			rows.Close()
+			return fmt.Errorf("ledger: scan follow-up: %w", err)
+		}
+		prior.add(t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ledger: list follow-ups: %w", err)
+	}
+
+	if err := checkFollowUp(*parent, prior, txn); err != nil {
+		return err
+	}
+	if err := insertTransaction(ctx, tx, txn); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("ledger: commit: %w", err)
+	}
+	return nil
+}
+
+func insertTransaction(ctx context.Context, q queryRower, txn *Transaction) error {
+	txn.ID = newID()
+	txn.Status = StatusPending
+	err := q.QueryRow(ctx,
+		`INSERT INTO transactions (id, property_id, operation, parent_id, mode, processor, gateway,
+		     credentials_id, amount_minor, currency, status, card_token, reservation_number, request_id)
+		 VALUES ($1, NULLIF($2, 0), $3, NULLIF($4, '')::uuid, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		 RETURNING created_at, updated_at`,
+		txn.ID, txn.PropertyID, txn.Operation, txn.ParentID, txn.Mode, txn.Processor, txn.Gateway,
+		txn.CredentialsID, txn.AmountMinor, txn.Currency, txn.Status, txn.CardToken, txn.ReservationNumber,
+		txn.RequestID,
+	).Scan(&txn.CreatedAt, &txn.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("ledger: insert transaction: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) Complete(ctx context.Context, id string, o Outcome) error {
+	tag, err := p.pool.Exec(ctx,
+		`UPDATE transactions SET
+		     status         = $2,
+		     processor      = COALESCE(NULLIF($3, ''), processor),
+		     gateway        = COALESCE(NULLIF($4, ''), gateway),
+		     transaction_id = $5,
+		     decline_code   = $6,
+		     message        = $7,
+		     updated_at     = now()
+		 WHERE id = $1`,
+		id, o.Status, o.Processor, o.Gateway, o.TransactionID, o.DeclineCode, o.Message,
+	)
+	if err != nil {
+		return fmt.Errorf("ledger: update transaction: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) Get(ctx context.Context, id string) (*Transaction, error) {
+	row := p.pool.QueryRow(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE id = $1`, id)
+	txn, err := scanTransaction(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ledger: get transaction: %w", err)
+	}
+	return txn, nil
+}
+
+func (p *PostgresStore) List(ctx context.Context, f Filter) ([]Transaction, error) {
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.PropertyID != 0 {
+		add("property_id = $%d", f.PropertyID)
+	}
+	if f.Operation != "" {
+		add("operation = $%d", f.Operation)
+	}
+	if f.ParentID != "" {
+		add("parent_id = $%d", f.ParentID)
+	}
+	if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if f.Mode != "" {
+		add("mode = $%d", f.Mode)
+	}
+	if f.Processor != "" {
+		add("processor = $%d", f.Processor)
+	}
+	if f.Gateway != "" {
+		add("gateway = $%d", f.Gateway)
+	}
+	if f.CardToken != "" {
+		add("card_token = $%d", f.CardToken)
+	}
+	if f.ReservationNumber != "" {
+		add("reservation_number = $%d", f.ReservationNumber)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+
+	query := `SELECT ` + transactionColumns + ` FROM transactions`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	args = append(args, f.limit(), f.Offset)
+	query += fmt.Sprintf(` ORDER BY created_at DESC, id LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ledger: list transactions: %w", err)
+	}
+	defer rows.Close()
+
+	txns := []Transaction{}
+	for rows.Next() {
+		txn, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ledger: scan transaction: %w", err)
+		}
+		txns = append(txns, *txn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ledger: list transactions: %w", err)
+	}
+	return txns, nil
+}
+
+func scanTransaction(row pgx.Row) (*Transaction, error) {
+	var t Transaction
+	err := row.Scan(&t.ID, &t.PropertyID, &t.Operation, &t.ParentID, &t.Mode,
+		&t.Processor, &t.Gateway, &t.CredentialsID, &t.AmountMinor, &t.Currency, &t.Status, &t.TransactionID, &t.DeclineCode, &t.Message,
+		&t.CardToken, &t.ReservationNumber, &t.RequestID, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
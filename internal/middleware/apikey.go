@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/apiclients"
+	"github.com/CentraGlobal/backend-payment-go/internal/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// CallerLocal is the Fiber local holding the authenticated *Caller.
+const CallerLocal = "caller"
+
+// AllScopes is the scope of callers with access to every route: those
+// authenticated with the shared secret or a signature, and every request when
+// auth is not required.
+const AllScopes = "*"
+
+// Caller is the identity a request was authenticated as.
+type Caller struct {
+	// ClientID is the API client's ID; it is empty for shared secret callers.
+	ClientID string   `json:"client_id,omitempty"`
+	Name     string   `json:"name"`
+	Scopes   []string `json:"scopes"`
+}
+
+// Can reports whether the caller was granted scope.
+func (c *Caller) Can(scope string) bool {
+	return slices.Contains(c.Scopes, scope) || slices.Contains(c.Scopes, AllScopes)
+}
+
+// CallerFrom returns the caller attached to the request by the auth
+// middleware, or nil if there is none.
+func CallerFrom(c *fiber.Ctx) *Caller {
+	caller, _ := c.Locals(CallerLocal).(*Caller)
+	return caller
+}
+
+// callerID identifies the request's caller: its API client ID, the name of a
+// shared secret, signed or mTLS caller, or "" when the request has none.
+func callerID(c *fiber.Ctx) string {
+	caller := CallerFrom(c)
+	switch {
+	case caller == nil:
+		return ""
+	case caller.ClientID != "":
+		return caller.ClientID
+	default:
+		return caller.Name
+	}
+}
+
+// trustedCaller attaches a caller with every scope, named after how it was
+// authenticated.
+func trustedCaller(c *fiber.Ctx, name string) {
+	c.Locals(CallerLocal, &Caller{Name: name, Scopes: []string{AllScopes}})
+}
+
+// bearerKey returns the key from an "Authorization: Bearer <key>" header.
+func bearerKey(c *fiber.Ctx) string {
+	scheme, key, ok := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(key)
+}
+
+// RequireAPIKey returns a Fiber middleware that authenticates named API
+// clients by the key in the "Authorization: Bearer <key>" header and attaches
+// them as the request's Caller, with the client's scopes.
+//
+// Behavior:
+//   - If cfg.Require is false, all requests pass through (development mode only).
+//   - If the header is absent, returns 401 Unauthorized.
+//   - If the key is unknown or revoked, returns 403 Forbidden.
+//   - If clients is nil or unavailable, returns 503.
+func RequireAPIKey(cfg config.AuthConfig, clients apiclients.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !cfg.Require {
+			trustedCaller(c, "unauthenticated")
+			return c.Next()
+		}
+
+		key := bearerKey(c)
+		if key == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "missing authorization header",
+			})
+		}
+		if clients == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "API client store unavailable",
+			})
+		}
+
+		client, err := clients.FindByKeyHash(c.Context(), apiclients.HashKey(key))
+		if errors.Is(err, apiclients.ErrNotFound) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "invalid authorization",
+			})
+		}
+		if err != nil {
+			log.Printf("auth: find API client: %v", err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "API client store unavailable",
+			})
+		}
+
+		c.Locals(CallerLocal, &Caller{ClientID: client.ID, Name: client.Name, Scopes: client.Scopes})
+		return c.Next()
+	}
+}
+
+// RequireScope returns a Fiber middleware that lets the request through only
+// if its Caller was granted scope, and otherwise returns 403 Forbidden.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		caller := CallerFrom(c)
+		if caller == nil || !caller.Can(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "missing scope " + scope,
+			})
+		}
+		return c.Next()
+	}
+}
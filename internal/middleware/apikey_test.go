@@ -0,0 +1,88 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/apiclients"
+	"github.com/CentraGlobal/backend-payment-go/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestRequireAPIKey_Scopes verifies that API clients are identified by key
+// and limited to the routes their scopes allow.
+func TestRequireAPIKey_Scopes(t *testing.T) {
+	store := apiclients.NewMemoryStore()
+	key, hash, err := apiclients.NewKey()
+	if err != nil {
+		t.Fatalf("NewKey: %v", err)
+	}
+	client := &apiclients.Client{ID: "c-1", Name: "obe", Scopes: []string{apiclients.ScopeCardsRead}}
+	if err := store.Create(context.Background(), client, hash); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	cfg := authConfig("supersecret")
+	cfg.Mode = middleware.AuthModeAPIKey
+	auth, err := middleware.Authenticate(cfg, nil, store)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	app := fiber.New()
+	var caller *middleware.Caller
+	v1 := app.Group("/v1", auth, func(c *fiber.Ctx) error {
+		caller = middleware.CallerFrom(c)
+		return c.Next()
+	})
+	ok := func(c *fiber.Ctx) error { return c.SendString("ok") }
+	v1.Get("/payments/cards/:token", middleware.RequireScope(apiclients.ScopeCardsRead), ok)
+	v1.Delete("/payments/cards/:token", middleware.RequireScope(apiclients.ScopeCardsDelete), ok)
+
+	request := func(method, bearer string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, "/v1/payments/cards/tok_1", nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		return statusOf(t, app, req)
+	}
+
+	if got := request(http.MethodGet, key); got != http.StatusOK {
+		t.Fatalf("expected a scoped route to pass, got %d", got)
+	}
+	if caller == nil || caller.ClientID != "c-1" || caller.Name != "obe" {
+		t.Errorf("expected the client attached to the request, got %+v", caller)
+	}
+	if got := request(http.MethodDelete, key); got != http.StatusForbidden {
+		t.Errorf("expected 403 without the cards:delete scope, got %d", got)
+	}
+	if got := request(http.MethodGet, ""); got != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a key, got %d", got)
+	}
+	if got := request(http.MethodGet, "pk_unknown"); got != http.StatusForbidden {
+		t.Errorf("expected 403 for an unknown key, got %d", got)
+	}
+
+	if err := store.Revoke(context.Background(), "c-1"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if got := request(http.MethodGet, key); got != http.StatusForbidden {
+		t.Errorf("expected 403 for a revoked key, got %d", got)
+	}
+}
+
+// TestRequireScope_SharedSecret verifies that shared secret callers keep
+// access to every route.
+func TestRequireScope_SharedSecret(t *testing.T) {
+	app := fiber.New()
+	app.Delete("/v1/payments/cards/:token", middleware.RequireSharedSecret(authConfig("supersecret")),
+		middleware.RequireScope(apiclients.ScopeCardsDelete), func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/payments/cards/tok_1", nil)
+	req.Header.Set("X-Payment-Service-Auth", "supersecret")
+	if got := statusOf(t, app, req); got != http.StatusOK {
+		t.Errorf("expected 200, got %d", got)
+	}
+}
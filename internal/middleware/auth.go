@@ -2,23 +2,46 @@ package middleware
 
 import (
 	"crypto/subtle"
+	"expvar"
+	"log"
+	"time"
 
 	"github.com/CentraGlobal/backend-payment-go/internal/config"
 	"github.com/gofiber/fiber/v2"
 )
 
+// AuthSecretLocal is the Fiber local set by RequireSharedSecret to the secret
+// the request was authenticated with: "current" or "previous".
+const AuthSecretLocal = "auth_secret"
+
+// previousSecretRequests counts the requests authenticated with the previous
+// shared secret, published with expvar for watching callers move off it.
+var previousSecretRequests = expvar.NewInt("auth_previous_secret_requests")
+
+// PreviousSecretRequests returns the number of requests authenticated with
+// the previous shared secret since the process started.
+func PreviousSecretRequests() int64 {
+	return previousSecretRequests.Value()
+}
+
 // RequireSharedSecret returns a Fiber middleware that enforces server-to-server
 // authentication using a static shared secret transmitted via an HTTP header.
 //
 // Behavior:
 //   - If cfg.Require is false, all requests pass through (development mode only).
 //   - If the configured header is absent, returns 401 Unauthorized.
-//   - If the header value does not match cfg.SharedSecret (constant-time compare),
-//     returns 403 Forbidden.
-//   - Otherwise the request is forwarded to the next handler.
+//   - If the header value matches neither cfg.SharedSecret nor
+//     cfg.PreviousSharedSecret (both compared in constant time), or matches
+//     the previous secret after cfg.PreviousSharedSecretExpiresAt, returns
+//     403 Forbidden.
+//   - Otherwise the request is forwarded to the next handler, with the
+//     matching secret recorded under AuthSecretLocal and a Caller with
+//     AllScopes attached. Requests using the previous secret are also logged
+//     and counted.
 func RequireSharedSecret(cfg config.AuthConfig) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		if !cfg.Require {
+			trustedCaller(c, "unauthenticated")
 			return c.Next()
 		}
 
@@ -29,7 +52,21 @@ func RequireSharedSecret(cfg config.AuthConfig) fiber.Handler {
 			})
 		}
 
-		if subtle.ConstantTimeCompare([]byte(provided), []byte(cfg.SharedSecret)) != 1 {
+		switch secretMatch(cfg, func(secret string) bool {
+			return subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) == 1
+		}) {
+		case secretCurrent:
+			c.Locals(AuthSecretLocal, "current")
+			trustedCaller(c, "shared-secret")
+		case secretPrevious:
+			acceptPrevious(c, "shared secret")
+			trustedCaller(c, "shared-secret")
+		case secretExpired:
+			log.Printf("auth: rejected expired previous shared secret for %s %s", c.Method(), c.Path())
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "invalid authorization",
+			})
+		default:
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"error": "invalid authorization",
 			})
@@ -38,3 +75,44 @@ func RequireSharedSecret(cfg config.AuthConfig) fiber.Handler {
 		return c.Next()
 	}
 }
+
+// secretResult is which configured secret a credential matched.
+type secretResult int
+
+const (
+	secretNone secretResult = iota
+	secretCurrent
+	secretPrevious
+	secretExpired
+)
+
+// secretMatch checks a request credential against the current and previous
+// secrets with match. Both are always checked so the response time does not
+// reveal which one a guess was checked against.
+func secretMatch(cfg config.AuthConfig, match func(secret string) bool) secretResult {
+	current := match(cfg.SharedSecret)
+	previous := cfg.PreviousSharedSecret != "" && match(cfg.PreviousSharedSecret)
+	switch {
+	case current:
+		return secretCurrent
+	case previous && previousSecretExpired(cfg):
+		return secretExpired
+	case previous:
+		return secretPrevious
+	}
+	return secretNone
+}
+
+// acceptPrevious records that the request was authenticated with the
+// previous secret.
+func acceptPrevious(c *fiber.Ctx, how string) {
+	c.Locals(AuthSecretLocal, "previous")
+	previousSecretRequests.Add(1)
+	log.Printf("auth: %s %s authenticated with the previous %s", c.Method(), c.Path(), how)
+}
+
+// previousSecretExpired reports whether the previous secret is past its
+// expiry. A zero expiry never expires.
+func previousSecretExpired(cfg config.AuthConfig) bool {
+	return !cfg.PreviousSharedSecretExpiresAt.IsZero() && time.Now().After(cfg.PreviousSharedSecretExpiresAt)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/CentraGlobal/backend-payment-go/internal/config"
 	"github.com/CentraGlobal/backend-payment-go/internal/middleware"
@@ -162,3 +163,52 @@ func TestRequireSharedSecret_CustomHeader(t *testing.T) {
 		t.Errorf("expected 200, got %d", resp2.StatusCode)
 	}
 }
+
+// TestRequireSharedSecret_PreviousSecret verifies that the previous secret is
+// accepted and counted until it expires, alongside the current one.
+func TestRequireSharedSecret_PreviousSecret(t *testing.T) {
+	cfg := authConfig("newsecret")
+	cfg.PreviousSharedSecret = "oldsecret"
+	app := fiber.New()
+	var matched any
+	app.Use(middleware.RequireSharedSecret(cfg), func(c *fiber.Ctx) error {
+		matched = c.Locals(middleware.AuthSecretLocal)
+		return c.SendString("ok")
+	})
+
+	status := func(secret string) int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/v1/session", nil)
+		req.Header.Set("X-Payment-Service-Auth", secret)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	before := middleware.PreviousSecretRequests()
+	if got := status("newsecret"); got != http.StatusOK || matched != "current" {
+		t.Errorf("expected the current secret accepted, got %d (%v)", got, matched)
+	}
+	if got := status("oldsecret"); got != http.StatusOK || matched != "previous" {
+		t.Errorf("expected the previous secret accepted, got %d (%v)", got, matched)
+	}
+	if n := middleware.PreviousSecretRequests() - before; n != 1 {
+		t.Errorf("expected one request counted against the previous secret, got %d", n)
+	}
+	if got := status("othersecret"); got != http.StatusForbidden {
+		t.Errorf("expected 403 for an unknown secret, got %d", got)
+	}
+
+	cfg.PreviousSharedSecretExpiresAt = time.Now().Add(-time.Minute)
+	app = fiber.New()
+	app.Use(middleware.RequireSharedSecret(cfg), func(c *fiber.Ctx) error { return c.SendString("ok") })
+	if got := status("oldsecret"); got != http.StatusForbidden {
+		t.Errorf("expected 403 for an expired previous secret, got %d", got)
+	}
+	if got := status("newsecret"); got != http.StatusOK {
+		t.Errorf("expected the current secret still accepted, got %d", got)
+	}
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/config"
+	"github.com/CentraGlobal/backend-payment-go/internal/idempotency"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client's idempotency key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set to "true" on replayed responses.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+
+	// chargeAttemptedLocal marks requests that called a processor to move
+	// money; see MarkChargeAttempted.
+	chargeAttemptedLocal = "charge_attempted"
+)
+
+// MarkChargeAttempted records that the request is about to call a processor
+// to move money. Idempotency stores the 5xx responses of such requests, whose
+// outcome is unknown, and releases the key of other 5xx responses so that a
+// retry after a transient outage reaches the processor.
+func MarkChargeAttempted(c *fiber.Ctx) {
+	c.Locals(chargeAttemptedLocal, true)
+}
+
+// ChargeAttempted reports whether MarkChargeAttempted was called for the
+// request.
+func ChargeAttempted(c *fiber.Ctx) bool {
+	attempted, _ := c.Locals(chargeAttemptedLocal).(bool)
+	return attempted
+}
+
+// Idempotency returns a Fiber middleware that deduplicates requests carrying
+// an Idempotency-Key header. Requests without the header pass through.
+//
+// Behavior:
+//   - The first request with a key is locked in store for cfg.LockTTL while it
+//     is processed, and its response is stored for cfg.TTL.
+//   - A repeat of a completed request replays the stored response with
+//     Idempotent-Replayed: true.
+//   - A repeat while the first request is still in flight returns 409.
+//   - Keys are scoped to the authenticated caller, so callers cannot see or
+//     block each other's requests.
+//   - Reusing a key for a different request (method, path, X-Property-ID
+//     header or body) returns 422.
+//   - Responses with status 400 or 429, where the request was rejected before
+//     being executed, and 5xx responses of requests that did not reach
+//     MarkChargeAttempted are not stored; the key can be retried.
+//   - If store is unavailable the request is refused with 503 rather than
+//     risking a duplicate charge.
+func Idempotency(store idempotency.Store, cfg config.IdempotencyConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Idempotency-Key must be at most 255 characters",
+			})
+		}
+
+		// Keys are scoped to the caller and the route, so different callers,
+		// or one caller on different endpoints, may use the same key.
+		storeKey := c.Path() + ":" + key
+		if caller := callerID(c); caller != "" {
+			storeKey = caller + ":" + storeKey
+		}
+		hash := requestHash(c)
+
+		rec, locked, err := store.Lock(c.Context(), storeKey, hash, cfg.LockTTL)
+		if err != nil {
+			log.Printf("idempotency: lock %q: %v", key, err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error":   "IDEMPOTENCY_UNAVAILABLE",
+				"message": "idempotency store unavailable; retry later",
+			})
+		}
+		if !locked {
+			switch {
+			case rec.RequestHash != hash:
+				return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+					"error":   "IDEMPOTENCY_KEY_REUSED",
+					"message": "Idempotency-Key was already used with a different request",
+				})
+			case rec.State != idempotency.StateCompleted:
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error":   "IDEMPOTENCY_CONFLICT",
+					"message": "a request with this Idempotency-Key is still in progress",
+				})
+			}
+			c.Set(IdempotentReplayedHeader, "true")
+			if rec.ContentType != "" {
+				c.Set(fiber.HeaderContentType, rec.ContentType)
+			}
+			return c.Status(rec.StatusCode).Send(rec.Body)
+		}
+
+		// Render errors now so the final response can be stored.
+		if err := c.Next(); err != nil {
+			if herr := c.App().Config().ErrorHandler(c, err); herr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		if status == fiber.StatusBadRequest || status == fiber.StatusTooManyRequests || (status >= 500 && !ChargeAttempted(c)) {
+			if err := store.Release(c.Context(), storeKey); err != nil {
+				log.Printf("idempotency: release %q: %v", key, err)
+			}
+			return nil
+		}
+
+		err = store.Complete(c.Context(), storeKey, idempotency.Record{
+			RequestHash: hash,
+			State:       idempotency.StateCompleted,
+			StatusCode:  status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+		}, cfg.TTL)
+		if err != nil {
+			// The lock expires after LockTTL; until then retries get 409.
+			log.Printf("idempotency: store response for %q: %v", key, err)
+		}
+		return nil
+	}
+}
+
+// requestHash identifies a request by method, path, property and body.
+func requestHash(c *fiber.Ctx) string {
+	h := sha256.New()
+	for _, part := range [][]byte{
+		[]byte(c.Method()),
+		[]byte(c.Path()),
+		[]byte(c.Get("X-Property-ID")),
+		c.Body(),
+	} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
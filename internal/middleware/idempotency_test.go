@@ -0,0 +1,251 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/config"
+	"github.com/CentraGlobal/backend-payment-go/internal/idempotency"
+	"github.com/CentraGlobal/backend-payment-go/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+var idempotencyConfig = config.IdempotencyConfig{TTL: time.Hour, LockTTL: time.Minute}
+
+// setupIdempotentApp mounts handler behind the idempotency middleware and
+// counts how often it runs.
+func setupIdempotentApp(store idempotency.Store, handler fiber.Handler) (*fiber.App, *int32) {
+	var calls int32
+	app := fiber.New()
+	app.Post("/v1/payments/charge", middleware.Idempotency(store, idempotencyConfig), func(c *fiber.Ctx) error {
+		atomic.AddInt32(&calls, 1)
+		return handler(c)
+	})
+	return app, &calls
+}
+
+func chargeRequest(key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/payments/charge", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(middleware.IdempotencyKeyHeader, key)
+	}
+	return req
+}
+
+func send(t *testing.T, app *fiber.App, req *http.Request) (*http.Response, string) {
+	t.Helper()
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestIdempotency_ReplaysCompletedRequest(t *testing.T) {
+	var n int32
+	app, calls := setupIdempotentApp(idempotency.NewMemoryStore(), func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"charge": atomic.AddInt32(&n, 1)})
+	})
+
+	first, firstBody := send(t, app, chargeRequest("key-1", `{"amount":10}`))
+	second, secondBody := send(t, app, chargeRequest("key-1", `{"amount":10}`))
+
+	if *calls != 1 {
+		t.Fatalf("expected handler to run once, ran %d times", *calls)
+	}
+	if second.StatusCode != first.StatusCode || secondBody != firstBody {
+		t.Errorf("expected replay of %d %s, got %d %s", first.StatusCode, firstBody, second.StatusCode, secondBody)
+	}
+	if second.Header.Get(middleware.IdempotentReplayedHeader) != "true" {
+		t.Error("expected Idempotent-Replayed header on replay")
+	}
+	if second.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("expected replayed content type, got %q", second.Header.Get("Content-Type"))
+	}
+}
+
+func TestIdempotency_ScopedToCaller(t *testing.T) {
+	var calls int32
+	app := fiber.New()
+	app.Post("/v1/payments/charge", func(c *fiber.Ctx) error {
+		c.Locals(middleware.CallerLocal, &middleware.Caller{ClientID: c.Get("X-Client"), Name: c.Get("X-Client")})
+		return c.Next()
+	}, middleware.Idempotency(idempotency.NewMemoryStore(), idempotencyConfig), func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"client": c.Get("X-Client"), "charge": atomic.AddInt32(&calls, 1)})
+	})
+
+	request := func(client string) *http.Request {
+		req := chargeRequest("key-1", `{"amount":10}`)
+		req.Header.Set("X-Client", client)
+		return req
+	}
+	_, first := send(t, app, request("client-a"))
+	resp, second := send(t, app, request("client-b"))
+
+	if calls != 2 {
+		t.Fatalf("expected each client's request to run, ran %d times", calls)
+	}
+	if resp.Header.Get(middleware.IdempotentReplayedHeader) != "" || second == first {
+		t.Errorf("expected client-b not to get client-a's response %s, got %s", first, second)
+	}
+	if _, again := send(t, app, request("client-a")); again != first {
+		t.Errorf("expected client-a's retry to replay %s, got %s", first, again)
+	}
+}
+
+func TestIdempotency_RejectsDifferentBody(t *testing.T) {
+	app, calls := setupIdempotentApp(idempotency.NewMemoryStore(), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	send(t, app, chargeRequest("key-1", `{"amount":10}`))
+	resp, _ := send(t, app, chargeRequest("key-1", `{"amount":20}`))
+
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d", resp.StatusCode)
+	}
+	if *calls != 1 {
+		t.Errorf("expected handler to run once, ran %d times", *calls)
+	}
+}
+
+func TestIdempotency_ConcurrentDuplicateConflicts(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	app, _ := setupIdempotentApp(idempotency.NewMemoryStore(), func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	done := make(chan *http.Response)
+	go func() {
+		resp, _ := app.Test(chargeRequest("key-1", `{}`), -1)
+		done <- resp
+	}()
+	<-started
+
+	resp, _ := send(t, app, chargeRequest("key-1", `{}`))
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("expected 409 while in flight, got %d", resp.StatusCode)
+	}
+
+	close(release)
+	if first := <-done; first.StatusCode != http.StatusOK {
+		t.Errorf("expected first request to succeed, got %d", first.StatusCode)
+	}
+}
+
+func TestIdempotency_ValidationFailureReleasesKey(t *testing.T) {
+	var n int32
+	app, calls := setupIdempotentApp(idempotency.NewMemoryStore(), func(c *fiber.Ctx) error {
+		if atomic.AddInt32(&n, 1) == 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid"})
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	send(t, app, chargeRequest("key-1", `{}`))
+	resp, _ := send(t, app, chargeRequest("key-1", `{}`))
+
+	if resp.StatusCode != http.StatusOK || *calls != 2 {
+		t.Errorf("expected retry to run the handler again, got %d after %d calls", resp.StatusCode, *calls)
+	}
+}
+
+func TestIdempotency_StoresErrorResponses(t *testing.T) {
+	app, calls := setupIdempotentApp(idempotency.NewMemoryStore(), func(c *fiber.Ctx) error {
+		middleware.MarkChargeAttempted(c)
+		return fiber.NewError(fiber.StatusBadGateway, "gateway failed")
+	})
+
+	send(t, app, chargeRequest("key-1", `{}`))
+	resp, body := send(t, app, chargeRequest("key-1", `{}`))
+
+	if resp.StatusCode != http.StatusBadGateway || body != "gateway failed" {
+		t.Errorf("expected replayed 502, got %d %s", resp.StatusCode, body)
+	}
+	if *calls != 1 {
+		t.Errorf("expected handler to run once, ran %d times", *calls)
+	}
+}
+
+// TestIdempotency_UnattemptedServerErrorReleasesKey verifies that a 5xx
+// returned before any charge was attempted, such as an unavailable processor,
+// does not block a retry.
+func TestIdempotency_UnattemptedServerErrorReleasesKey(t *testing.T) {
+	var n int32
+	app, calls := setupIdempotentApp(idempotency.NewMemoryStore(), func(c *fiber.Ctx) error {
+		if atomic.AddInt32(&n, 1) == 1 {
+			return fiber.NewError(fiber.StatusServiceUnavailable, "processor unavailable")
+		}
+		middleware.MarkChargeAttempted(c)
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	send(t, app, chargeRequest("key-1", `{}`))
+	resp, _ := send(t, app, chargeRequest("key-1", `{}`))
+
+	if resp.StatusCode != http.StatusOK || *calls != 2 {
+		t.Errorf("expected retry to run the handler again, got %d after %d calls", resp.StatusCode, *calls)
+	}
+}
+
+func TestIdempotency_NoKeyPassesThrough(t *testing.T) {
+	app, calls := setupIdempotentApp(idempotency.NewMemoryStore(), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	send(t, app, chargeRequest("", `{}`))
+	send(t, app, chargeRequest("", `{}`))
+
+	if *calls != 2 {
+		t.Errorf("expected handler to run twice, ran %d times", *calls)
+	}
+}
+
+// failingStore is an idempotency store that is always unavailable.
+type failingStore struct{}
+
+func (failingStore) Lock(context.Context, string, string, time.Duration) (idempotency.Record, bool, error) {
+	return idempotency.Record{}, false, errors.New("connection refused")
+}
+func (failingStore) Complete(context.Context, string, idempotency.Record, time.Duration) error {
+	return errors.New("connection refused")
+}
+func (failingStore) Release(context.Context, string) error { return errors.New("connection refused") }
+
+func TestIdempotency_StoreUnavailable(t *testing.T) {
+	app, calls := setupIdempotentApp(failingStore{}, func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	resp, _ := send(t, app, chargeRequest("key-1", `{}`))
+	if resp.StatusCode != http.StatusServiceUnavailable || *calls != 0 {
+		t.Errorf("expected 503 without running the handler, got %d after %d calls", resp.StatusCode, *calls)
+	}
+}
+
+func TestIdempotency_FallbackStore(t *testing.T) {
+	fallback := idempotency.NewMemoryStore()
+	app, calls := setupIdempotentApp(idempotency.NewFallbackStore(failingStore{}, fallback), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	send(t, app, chargeRequest("key-1", `{}`))
+	resp, _ := send(t, app, chargeRequest("key-1", `{}`))
+
+	if resp.StatusCode != http.StatusOK || *calls != 1 {
+		t.Errorf("expected replay from the fallback store, got %d after %d calls", resp.StatusCode, *calls)
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/apiclients"
+	"github.com/CentraGlobal/backend-payment-go/internal/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// clientCertSubject returns the common name of the verified client
+// certificate the request's connection was made with, or "" without one.
+func clientCertSubject(c *fiber.Ctx) string {
+	state := c.Context().TLSConnectionState()
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return state.VerifiedChains[0][0].Subject.CommonName
+}
+
+// RequireClientCert returns a Fiber middleware that authenticates callers on
+// the mutual TLS listener by their client certificate. The certificate has
+// already been verified against the client CA bundle during the handshake;
+// its subject common name is matched to the API client of the same name,
+// which is attached as the request's Caller with that client's scopes.
+//
+// Behavior:
+//   - If cfg.Require is false, all requests pass through (development mode only).
+//   - If the connection has no verified client certificate, returns 401
+//     Unauthorized.
+//   - If no unrevoked API client has the certificate's common name, returns
+//     403 Forbidden.
+//   - If clients is nil or unavailable, returns 503.
+func RequireClientCert(cfg config.AuthConfig, clients apiclients.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !cfg.Require {
+			trustedCaller(c, "unauthenticated")
+			return c.Next()
+		}
+
+		subject := clientCertSubject(c)
+		if subject == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "client certificate required",
+			})
+		}
+		if clients == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "API client store unavailable",
+			})
+		}
+
+		client, err := clients.FindByName(c.Context(), subject)
+		if errors.Is(err, apiclients.ErrNotFound) {
+			log.Printf("auth: no API client for client certificate %q", subject)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "invalid authorization",
+			})
+		}
+		if err != nil {
+			log.Printf("auth: find API client: %v", err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "API client store unavailable",
+			})
+		}
+
+		c.Locals(CallerLocal, &Caller{ClientID: client.ID, Name: client.Name, Scopes: client.Scopes})
+		return c.Next()
+	}
+}
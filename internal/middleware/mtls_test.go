@@ -0,0 +1,31 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/apiclients"
+	"github.com/CentraGlobal/backend-payment-go/internal/middleware"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestRequireClientCert_NoCertificate verifies that requests without a
+// verified client certificate, such as those over plain HTTP, are rejected.
+// The certificate path is covered by the mtls package's listener test.
+func TestRequireClientCert_NoCertificate(t *testing.T) {
+	cfg := authConfig("supersecret")
+	cfg.Mode = middleware.AuthModeMTLS
+	auth, err := middleware.Authenticate(cfg, nil, apiclients.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	app := fiber.New()
+	app.Get("/v1/session", auth, func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/session", nil)
+	req.Header.Set("X-Payment-Service-Auth", "supersecret")
+	if got := statusOf(t, app, req); got != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a client certificate, got %d", got)
+	}
+}
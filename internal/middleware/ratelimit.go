@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// RateLimitLimitHeader, RateLimitRemainingHeader and RateLimitResetHeader
+	// report the most constrained limit that applied to a request.
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+
+	// rateLimitCardLocal holds the card token set by SetRateLimitCard.
+	rateLimitCardLocal = "ratelimit_card"
+)
+
+// SetRateLimitCard makes token the request's card for RateLimit, for routes
+// that charge a card they do not name, such as a reservation's stored card.
+// It must be called before RateLimit runs.
+func SetRateLimitCard(c *fiber.Ctx, token string) {
+	c.Locals(rateLimitCardLocal, token)
+}
+
+// RateLimits are the requests per Window a route accepts from one caller, for
+// one property and with one card token. A zero limit is not enforced.
+type RateLimits struct {
+	Window      time.Duration
+	PerCaller   int
+	PerProperty int
+	PerCard     int
+}
+
+// RateLimit returns a Fiber middleware that limits the requests to a route,
+// counted in store under the route's name. It must run after authentication,
+// as callers are identified by the Caller attached to the request.
+//
+// Behavior:
+//   - The request is checked against each limit that applies: the caller, the
+//     property (the :propertyId path param or X-Property-ID header) and the
+//     card token (set by SetRateLimitCard, or the :token path param or
+//     card_token body field).
+//   - Callers are API clients by ID. Callers without one share a bucket per
+//     way they authenticated: every shared secret caller is "shared-secret"
+//     and every signed caller "signed", so one of them can use up the limit
+//     of all.
+//   - It is counted against all of them only if none is reached, so that a
+//     request refused by one limit does not use up the others.
+//   - Once any limit is reached, returns 429 with Retry-After until the oldest
+//     counted request leaves the window.
+//   - RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset describe the
+//     limit closest to being reached.
+//   - If store is unavailable the request is let through; limiting must not
+//     take payments down.
+func RateLimit(store ratelimit.Store, route string, limits RateLimits) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		checks := []struct {
+			name  string
+			id    string
+			limit int
+		}{
+			{"card", rateLimitCard(c), limits.PerCard},
+			{"property", rateLimitProperty(c), limits.PerProperty},
+			{"caller", rateLimitCaller(c), limits.PerCaller},
+		}
+
+		var names []string
+		var keys []ratelimit.Limit
+		for _, check := range checks {
+			if check.id == "" || check.limit <= 0 {
+				continue
+			}
+			names = append(names, check.name)
+			keys = append(keys, ratelimit.Limit{
+				Key:    route + ":" + check.name + ":" + check.id,
+				Limit:  check.limit,
+				Window: limits.Window,
+			})
+		}
+		if len(keys) == 0 {
+			return c.Next()
+		}
+		results, err := store.Allow(c.Context(), keys...)
+		if err != nil {
+			log.Printf("ratelimit: %s: %v", route, err)
+			return c.Next()
+		}
+
+		closest := results[0]
+		for i, res := range results {
+			if !res.Allowed {
+				log.Printf("ratelimit: %s: %s limit of %d per %s reached (caller %s)",
+					route, names[i], res.Limit, limits.Window, rateLimitCaller(c))
+				setRateLimitHeaders(c, res)
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(res.Reset)))
+				return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+					"error":   "RATE_LIMITED",
+					"message": "too many requests for this " + names[i] + "; retry later",
+				})
+			}
+			if res.Remaining < closest.Remaining {
+				closest = res
+			}
+		}
+		setRateLimitHeaders(c, closest)
+		return c.Next()
+	}
+}
+
+func setRateLimitHeaders(c *fiber.Ctx, res ratelimit.Result) {
+	c.Set(RateLimitLimitHeader, strconv.Itoa(res.Limit))
+	c.Set(RateLimitRemainingHeader, strconv.Itoa(res.Remaining))
+	c.Set(RateLimitResetHeader, strconv.Itoa(ceilSeconds(res.Reset)))
+}
+
+// ceilSeconds rounds d up to whole seconds, at least 1.
+func ceilSeconds(d time.Duration) int {
+	return max(int(math.Ceil(d.Seconds())), 1)
+}
+
+// rateLimitCaller identifies the request's caller: its API client, the way a
+// trusted caller authenticated (shared by all such callers), or its IP
+// address without a Caller.
+func rateLimitCaller(c *fiber.Ctx) string {
+	if id := callerID(c); id != "" {
+		return id
+	}
+	return "ip-" + c.IP()
+}
+
+func rateLimitProperty(c *fiber.Ctx) string {
+	if id := c.Params("propertyId"); id != "" {
+		return id
+	}
+	return strings.TrimSpace(c.Get("X-Property-ID"))
+}
+
+// rateLimitCard returns a hash of the request's card token, or "" without
+// one. Tokens are hashed so they are not stored as counter keys.
+func rateLimitCard(c *fiber.Ctx) string {
+	token, _ := c.Locals(rateLimitCardLocal).(string)
+	if token == "" {
+		token = c.Params("token")
+	}
+	if token == "" {
+		var body struct {
+			CardToken string `json:"card_token"`
+		}
+		if json.Unmarshal(c.Body(), &body) != nil {
+			return ""
+		}
+		token = body.CardToken
+	}
+	if token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,101 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/middleware"
+	"github.com/CentraGlobal/backend-payment-go/internal/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+func setupRateLimitedApp(limits middleware.RateLimits) *fiber.App {
+	app := fiber.New()
+	v1 := app.Group("/v1", middleware.RequireSharedSecret(authConfig("supersecret")))
+	v1.Post("/payments/charge", middleware.RateLimit(ratelimit.NewMemoryStore(), "charge", limits),
+		func(c *fiber.Ctx) error { return c.SendString("ok") })
+	return app
+}
+
+func chargeCard(t *testing.T, app *fiber.App, property, token string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/payments/charge",
+		strings.NewReader(`{"card_token":"`+token+`","amount":"10.00"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Payment-Service-Auth", "supersecret")
+	req.Header.Set("X-Property-ID", property)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	return resp
+}
+
+// TestRateLimit_PerCard verifies that a card token is refused once its limit
+// is reached, with Retry-After, while other cards are still accepted.
+func TestRateLimit_PerCard(t *testing.T) {
+	app := setupRateLimitedApp(middleware.RateLimits{Window: time.Minute, PerCaller: 10, PerCard: 2})
+
+	resp := chargeCard(t, app, "1", "tok_a")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get(middleware.RateLimitLimitHeader); got != "2" {
+		t.Errorf("expected the card limit reported as closest, got RateLimit-Limit %q", got)
+	}
+	if got := resp.Header.Get(middleware.RateLimitRemainingHeader); got != "1" {
+		t.Errorf("expected RateLimit-Remaining 1, got %q", got)
+	}
+	chargeCard(t, app, "1", "tok_a")
+
+	resp = chargeCard(t, app, "1", "tok_a")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for the third charge of a card, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get(fiber.HeaderRetryAfter); got != "60" {
+		t.Errorf("expected Retry-After 60, got %q", got)
+	}
+	if resp := chargeCard(t, app, "1", "tok_b"); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected another card to be accepted, got %d", resp.StatusCode)
+	}
+}
+
+// TestRateLimit_PerPropertyAndCaller verifies that property and caller limits
+// apply across cards.
+func TestRateLimit_PerPropertyAndCaller(t *testing.T) {
+	app := setupRateLimitedApp(middleware.RateLimits{Window: time.Minute, PerCaller: 3, PerProperty: 2})
+
+	chargeCard(t, app, "1", "tok_a")
+	chargeCard(t, app, "1", "tok_b")
+	if resp := chargeCard(t, app, "1", "tok_c"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 once the property limit is reached, got %d", resp.StatusCode)
+	}
+	if resp := chargeCard(t, app, "2", "tok_d"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected another property to be accepted, got %d", resp.StatusCode)
+	}
+	if resp := chargeCard(t, app, "3", "tok_e"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected 429 once the caller limit is reached, got %d", resp.StatusCode)
+	}
+}
+
+// TestRateLimit_RefusedRequestsNotCounted verifies that a request refused by
+// one limit does not use up the others.
+func TestRateLimit_RefusedRequestsNotCounted(t *testing.T) {
+	app := setupRateLimitedApp(middleware.RateLimits{Window: time.Minute, PerProperty: 1, PerCard: 3})
+
+	chargeCard(t, app, "1", "tok_a")
+	for range 3 {
+		if resp := chargeCard(t, app, "1", "tok_a"); resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected 429 once the property limit is reached, got %d", resp.StatusCode)
+		}
+	}
+	if resp := chargeCard(t, app, "2", "tok_a"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the card's refused charges not to count, got %d", resp.StatusCode)
+	}
+	if got := chargeCard(t, app, "3", "tok_a").Header.Get(middleware.RateLimitRemainingHeader); got != "0" {
+		t.Errorf("expected the card's third counted charge to use its last slot, got RateLimit-Remaining %q", got)
+	}
+}
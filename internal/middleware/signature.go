@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/apiclients"
+	"github.com/CentraGlobal/backend-payment-go/internal/config"
+	"github.com/CentraGlobal/backend-payment-go/internal/nonce"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Request signing headers.
+const (
+	// SignatureTimestampHeader carries the signing time in Unix seconds.
+	SignatureTimestampHeader = "X-Payment-Service-Timestamp"
+	// SignatureNonceHeader carries a value unique to the request.
+	SignatureNonceHeader = "X-Payment-Service-Nonce"
+	// SignatureHeader carries the hex-encoded HMAC-SHA256 signature.
+	SignatureHeader = "X-Payment-Service-Signature"
+
+	maxNonceLength = 128
+)
+
+// Auth modes selected by AUTH_MODE.
+const (
+	AuthModeSharedSecret = "shared_secret"
+	AuthModeHMAC         = "hmac"
+	AuthModeAPIKey       = "api_key"
+	// AuthModeMTLS identifies callers by their client certificate on the
+	// mutual TLS listener.
+	AuthModeMTLS = "mtls"
+	// AuthModeEither accepts API keys, signed requests, the shared secret
+	// header and client certificates, so callers can move between them.
+	AuthModeEither = "either"
+)
+
+// UsesSharedSecret reports whether callers can authenticate with the shared
+// secret in auth mode, by header or by signing with it.
+func UsesSharedSecret(mode string) bool {
+	switch mode {
+	case AuthModeSharedSecret, AuthModeHMAC, AuthModeEither:
+		return true
+	}
+	return false
+}
+
+// Authenticate returns the /v1 auth middleware for cfg.Mode: the shared
+// secret header, request signatures, per-client API keys, client
+// certificates, or any of them, so that callers can be moved one at a time.
+// In AuthModeEither a request is verified by API key when it carries a bearer
+// Authorization header, by signature when it carries SignatureHeader, by
+// client certificate when it carries neither the shared secret header nor
+// those but was made over mutual TLS, and by shared secret otherwise.
+func Authenticate(cfg config.AuthConfig, nonces nonce.Store, clients apiclients.Store) (fiber.Handler, error) {
+	sharedSecret := RequireSharedSecret(cfg)
+	signature := RequireSignature(cfg, nonces)
+	apiKey := RequireAPIKey(cfg, clients)
+	clientCert := RequireClientCert(cfg, clients)
+	switch cfg.Mode {
+	case AuthModeSharedSecret:
+		return sharedSecret, nil
+	case AuthModeHMAC:
+		return signature, nil
+	case AuthModeAPIKey:
+		return apiKey, nil
+	case AuthModeMTLS:
+		return clientCert, nil
+	case AuthModeEither:
+		return func(c *fiber.Ctx) error {
+			switch {
+			case bearerKey(c) != "":
+				return apiKey(c)
+			case c.Get(SignatureHeader) != "":
+				return signature(c)
+			case c.Get(cfg.HeaderName) == "" && clientCertSubject(c) != "":
+				return clientCert(c)
+			}
+			return sharedSecret(c)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown auth mode %q (supported: %s, %s, %s, %s, %s)", cfg.Mode,
+		AuthModeSharedSecret, AuthModeHMAC, AuthModeAPIKey, AuthModeMTLS, AuthModeEither)
+}
+
+// SignRequest returns the hex-encoded HMAC-SHA256, keyed by secret, of the
+// string to sign: the method, the path with its query string, the timestamp,
+// the nonce and the hex-encoded SHA-256 of the body, joined by newlines.
+func SignRequest(secret, method, path, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{
+		strings.ToUpper(method), path, timestamp, nonce, hex.EncodeToString(bodyHash[:]),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RequireSignature returns a Fiber middleware that authenticates requests
+// signed with the shared secret (see SignRequest), so that a request seen in
+// transit cannot be replayed or altered.
+//
+// Behavior:
+//   - If cfg.Require is false, all requests pass through (development mode only).
+//   - If a signing header is absent, returns 401 Unauthorized.
+//   - If the timestamp is more than cfg.HMACMaxSkew away from the server's
+//     clock, returns 401 Unauthorized.
+//   - If the signature matches neither the current nor the unexpired previous
+//     shared secret, returns 403 Forbidden.
+//   - If the nonce was already used within twice the skew window, returns
+//     403 Forbidden. Nonces are only recorded for correctly signed requests.
+//   - If nonces is unavailable the request is refused with 503 rather than
+//     risking a replay.
+//   - Otherwise a Caller with AllScopes is attached and the request forwarded.
+func RequireSignature(cfg config.AuthConfig, nonces nonce.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !cfg.Require {
+			trustedCaller(c, "unauthenticated")
+			return c.Next()
+		}
+
+		timestamp, reqNonce, signature := c.Get(SignatureTimestampHeader), c.Get(SignatureNonceHeader), c.Get(SignatureHeader)
+		if timestamp == "" || reqNonce == "" || signature == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "missing request signature",
+			})
+		}
+		if len(reqNonce) > maxNonceLength {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "request nonce must be at most 128 characters",
+			})
+		}
+		signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil || time.Since(time.Unix(signedAt, 0)).Abs() > cfg.HMACMaxSkew {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "request timestamp is outside the allowed clock skew",
+			})
+		}
+
+		provided, err := hex.DecodeString(signature)
+		if err != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "invalid authorization",
+			})
+		}
+		result := secretMatch(cfg, func(secret string) bool {
+			expected, _ := hex.DecodeString(SignRequest(secret, c.Method(), c.OriginalURL(), timestamp, reqNonce, c.Body()))
+			return hmac.Equal(provided, expected)
+		})
+		if result == secretExpired {
+			log.Printf("auth: rejected signature with expired previous shared secret for %s %s", c.Method(), c.Path())
+		}
+		if result != secretCurrent && result != secretPrevious {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "invalid authorization",
+			})
+		}
+
+		// A nonce only has to outlive the window its timestamp is accepted in.
+		fresh, err := nonces.Claim(c.Context(), reqNonce, 2*cfg.HMACMaxSkew)
+		if err != nil {
+			log.Printf("auth: claim nonce: %v", err)
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "replay protection unavailable; retry later",
+			})
+		}
+		if !fresh {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "request nonce already used",
+			})
+		}
+
+		if result == secretPrevious {
+			acceptPrevious(c, "shared secret as signing key")
+		} else {
+			c.Locals(AuthSecretLocal, "current")
+		}
+		trustedCaller(c, "signed")
+		return c.Next()
+	}
+}
@@ -0,0 +1,127 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/config"
+	"github.com/CentraGlobal/backend-payment-go/internal/middleware"
+	"github.com/CentraGlobal/backend-payment-go/internal/nonce"
+	"github.com/gofiber/fiber/v2"
+)
+
+func setupSignedApp(t *testing.T, cfg config.AuthConfig) *fiber.App {
+	t.Helper()
+	auth, err := middleware.Authenticate(cfg, nonce.NewMemoryStore(), nil)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	app := fiber.New()
+	v1 := app.Group("/v1", auth)
+	v1.Post("/payments/charge", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	return app
+}
+
+func signedConfig(mode string) config.AuthConfig {
+	cfg := authConfig("supersecret")
+	cfg.Mode = mode
+	cfg.HMACMaxSkew = 5 * time.Minute
+	return cfg
+}
+
+// signedRequest builds a request to path signed with secret at signedAt.
+func signedRequest(secret, path, body, nonceValue string, signedAt time.Time) *http.Request {
+	ts := strconv.FormatInt(signedAt.Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(middleware.SignatureTimestampHeader, ts)
+	req.Header.Set(middleware.SignatureNonceHeader, nonceValue)
+	req.Header.Set(middleware.SignatureHeader, middleware.SignRequest(secret, http.MethodPost, path, ts, nonceValue, []byte(body)))
+	return req
+}
+
+func statusOf(t *testing.T, app *fiber.App, req *http.Request) int {
+	t.Helper()
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// TestRequireSignature verifies that correctly signed requests pass once and
+// that altered, stale, replayed and unsigned requests are rejected.
+func TestRequireSignature(t *testing.T) {
+	app := setupSignedApp(t, signedConfig(middleware.AuthModeHMAC))
+	const path, body = "/v1/payments/charge?x=1", `{"card_token":"tok_1"}`
+	now := time.Now()
+
+	if got := statusOf(t, app, signedRequest("supersecret", path, body, "n-1", now)); got != http.StatusOK {
+		t.Fatalf("expected a signed request to pass, got %d", got)
+	}
+	if got := statusOf(t, app, signedRequest("supersecret", path, body, "n-1", now)); got != http.StatusForbidden {
+		t.Errorf("expected 403 for a replayed nonce, got %d", got)
+	}
+
+	altered := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"card_token":"tok_2"}`))
+	altered.Header = signedRequest("supersecret", path, body, "n-2", now).Header
+	if got := statusOf(t, app, altered); got != http.StatusForbidden {
+		t.Errorf("expected 403 for an altered body, got %d", got)
+	}
+	if got := statusOf(t, app, signedRequest("supersecret", "/v1/payments/charge?x=2", body, "n-3", now)); got != http.StatusOK {
+		t.Errorf("expected another signed request to pass, got %d", got)
+	}
+	if got := statusOf(t, app, signedRequest("wrongsecret", path, body, "n-4", now)); got != http.StatusForbidden {
+		t.Errorf("expected 403 for a wrong key, got %d", got)
+	}
+	if got := statusOf(t, app, signedRequest("supersecret", path, body, "n-5", now.Add(-10*time.Minute))); got != http.StatusUnauthorized {
+		t.Errorf("expected 401 outside the clock skew, got %d", got)
+	}
+
+	shared := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	shared.Header.Set("X-Payment-Service-Auth", "supersecret")
+	if got := statusOf(t, app, shared); got != http.StatusUnauthorized {
+		t.Errorf("expected 401 for the shared secret header in hmac mode, got %d", got)
+	}
+}
+
+// TestAuthenticate_Either verifies that both schemes are accepted while
+// callers migrate, and that an unknown mode is refused.
+func TestAuthenticate_Either(t *testing.T) {
+	app := setupSignedApp(t, signedConfig(middleware.AuthModeEither))
+	const path = "/v1/payments/charge"
+
+	if got := statusOf(t, app, signedRequest("supersecret", path, "{}", "n-1", time.Now())); got != http.StatusOK {
+		t.Errorf("expected a signed request to pass, got %d", got)
+	}
+	shared := httptest.NewRequest(http.MethodPost, path, nil)
+	shared.Header.Set("X-Payment-Service-Auth", "supersecret")
+	if got := statusOf(t, app, shared); got != http.StatusOK {
+		t.Errorf("expected the shared secret to pass, got %d", got)
+	}
+	if got := statusOf(t, app, httptest.NewRequest(http.MethodPost, path, nil)); got != http.StatusUnauthorized {
+		t.Errorf("expected 401 without credentials, got %d", got)
+	}
+
+	if _, err := middleware.Authenticate(signedConfig("basic"), nonce.NewMemoryStore(), nil); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestUsesSharedSecret(t *testing.T) {
+	for mode, want := range map[string]bool{
+		middleware.AuthModeSharedSecret: true,
+		middleware.AuthModeHMAC:         true,
+		middleware.AuthModeEither:       true,
+		middleware.AuthModeAPIKey:       false,
+		middleware.AuthModeMTLS:         false,
+	} {
+		if got := middleware.UsesSharedSecret(mode); got != want {
+			t.Errorf("UsesSharedSecret(%q) = %t, want %t", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package money represents monetary amounts as int64 minor units of an
+// ISO 4217 currency, so amounts are never rounded through floating point.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnknownCurrency is returned for codes that are not active ISO 4217 currencies.
+	ErrUnknownCurrency = errors.New("money: unknown currency")
+	// ErrPrecision is returned when an amount has more decimal places than its
+	// currency's exponent allows.
+	ErrPrecision = errors.New("money: amount has more decimal places than the currency allows")
+	// ErrInvalidAmount is returned for amounts that are not decimal numbers or
+	// do not fit in int64 minor units.
+	ErrInvalidAmount = errors.New("money: invalid amount")
+)
+
+// Money is an amount in minor units (e.g. cents) of a currency.
+type Money struct {
+	Minor    int64
+	Currency Currency
+}
+
+// New returns minor units of the currency with the given code.
+func New(minor int64, code string) (Money, error) {
+	cur, err := ParseCurrency(code)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Minor: minor, Currency: cur}, nil
+}
+
+// Parse converts a decimal amount in major units, e.g. "10.50", to Money
+// without going through floating point. Significant digits beyond the
+// currency's exponent (e.g. "10.005" EUR or "1.5" JPY) are ErrPrecision;
+// trailing zeros are accepted.
+func Parse(amount, code string) (Money, error) {
+	cur, err := ParseCurrency(code)
+	if err != nil {
+		return Money{}, err
+	}
+
+	s := strings.TrimSpace(amount)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !digits(whole) || !digits(frac) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+
+	if trimmed := strings.TrimRight(frac, "0"); len(trimmed) > cur.Exponent {
+		return Money{}, fmt.Errorf("%w: %s %s", ErrPrecision, amount, cur.Code)
+	}
+	frac = (frac + strings.Repeat("0", cur.Exponent))[:cur.Exponent]
+
+	minor, err := strconv.ParseInt("0"+whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	if neg {
+		minor = -minor
+	}
+	return Money{Minor: minor, Currency: cur}, nil
+}
+
+// MustParse is like Parse but panics on error. It is intended for constants
+// and tests.
+func MustParse(amount, code string) Money {
+	m, err := Parse(amount, code)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// IsPositive reports whether m is greater than zero.
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+// Decimal formats m in major units with exactly the currency's exponent
+// decimal places, e.g. "10.50", "1000" for JPY or "1.250" for KWD.
+func (m Money) Decimal() string {
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+	}
+	s := strconv.FormatUint(absUint(minor), 10)
+	exp := m.Currency.Exponent
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency.Code
+}
+
+func absUint(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package money_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount, currency string
+		minor            int64
+		decimal          string
+	}{
+		{"10.50", "EUR", 1050, "10.50"},
+		{"10.5", "eur", 1050, "10.50"},
+		{"10", "USD", 1000, "10.00"},
+		{"0.01", "USD", 1, "0.01"},
+		{".5", "GBP", 50, "0.50"},
+		{"1000", "JPY", 1000, "1000"},
+		{"1000.00", "JPY", 1000, "1000"},
+		{"1.250", "KWD", 1250, "1.250"},
+		{"0.001", "BHD", 1, "0.001"},
+		{"-3.10", "CHF", -310, "-3.10"},
+		{"0.0001", "CLF", 1, "0.0001"},
+	}
+
+	for _, tc := range tests {
+		m, err := money.Parse(tc.amount, tc.currency)
+		if err != nil {
+			t.Errorf("Parse(%q, %q): %v", tc.amount, tc.currency, err)
+			continue
+		}
+		if m.Minor != tc.minor {
+			t.Errorf("Parse(%q, %q): expected %d minor units, got %d", tc.amount, tc.currency, tc.minor, m.Minor)
+		}
+		if m.Decimal() != tc.decimal {
+			t.Errorf("Parse(%q, %q).Decimal(): expected %q, got %q", tc.amount, tc.currency, tc.decimal, m.Decimal())
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		amount, currency string
+		want             error
+	}{
+		{"10.005", "EUR", money.ErrPrecision},
+		{"1.5", "JPY", money.ErrPrecision},
+		{"1.2345", "KWD", money.ErrPrecision},
+		{"10", "XXX", money.ErrUnknownCurrency},
+		{"10", "", money.ErrUnknownCurrency},
+		{"", "EUR", money.ErrInvalidAmount},
+		{"1e3", "EUR", money.ErrInvalidAmount},
+		{"1,000.00", "EUR", money.ErrInvalidAmount},
+		{"99999999999999999999", "EUR", money.ErrInvalidAmount},
+	}
+
+	for _, tc := range tests {
+		_, err := money.Parse(tc.amount, tc.currency)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("Parse(%q, %q): expected %v, got %v", tc.amount, tc.currency, tc.want, err)
+		}
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	c, err := money.ParseCurrency("gbp")
+	if err != nil {
+		t.Fatalf("ParseCurrency: %v", err)
+	}
+	if c.Code != "GBP" || c.Numeric != 826 || c.Exponent != 2 {
+		t.Errorf("unexpected currency %+v", c)
+	}
+}
@@ -0,0 +1,140 @@
+// Package mtls builds the TLS configuration of the mutual TLS listener and
+// reloads its certificates from disk when they change, so that certificates
+// can be renewed without restarting the service.
+package mtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/config"
+)
+
+// Reloader holds the server certificate and client CA pool of the listener,
+// read from the files named in its config.
+type Reloader struct {
+	cfg config.MTLSConfig
+
+	mu      sync.RWMutex
+	tls     *tls.Config
+	modTime time.Time
+}
+
+// NewReloader loads the certificate, key and client CA bundle named in cfg.
+func NewReloader(cfg config.MTLSConfig) (*Reloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" || cfg.ClientCAFile == "" {
+		return nil, errors.New("mtls: certificate, key and client CA files are required")
+	}
+	r := &Reloader{cfg: cfg}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns the listener's TLS configuration. Each handshake uses the
+// certificates loaded last, and requires a client certificate signed by the
+// client CA bundle.
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			return r.tls, nil
+		},
+	}
+}
+
+// Reload reads the files again and replaces the certificates in use. On error
+// the previous certificates stay in use.
+func (r *Reloader) Reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("mtls: load certificate: %w", err)
+	}
+	bundle, err := os.ReadFile(r.cfg.ClientCAFile)
+	if err != nil {
+		return fmt.Errorf("mtls: read client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return fmt.Errorf("mtls: no certificates in client CA bundle %s", r.cfg.ClientCAFile)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tls = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	r.modTime = modTime
+	return nil
+}
+
+// Watch checks the files every cfg.ReloadInterval until ctx is done, and
+// reloads them when any has been modified. Failed reloads are logged and
+// retried at the next check.
+func (r *Reloader) Watch(ctx context.Context) {
+	if r.cfg.ReloadInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.cfg.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.reloadIfChanged(); err != nil {
+				log.Printf("mtls: reload certificates: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Reloader) reloadIfChanged() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	changed := !modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	if err := r.Reload(); err != nil {
+		return err
+	}
+	log.Printf("mtls: reloaded certificates from %s", r.cfg.CertFile)
+	return nil
+}
+
+// latestModTime returns the most recent modification time of the files.
+func (r *Reloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("mtls: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,155 @@
+package mtls_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/apiclients"
+	"github.com/CentraGlobal/backend-payment-go/internal/config"
+	"github.com/CentraGlobal/backend-payment-go/internal/middleware"
+	"github.com/CentraGlobal/backend-payment-go/internal/mtls"
+	"github.com/gofiber/fiber/v2"
+)
+
+// issue creates a certificate for cn signed by parent, or self-signed CA
+// when parent is nil.
+func issue(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func writePEM(t *testing.T, path string, cert *x509.Certificate, key *ecdsa.PrivateKey) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if key != nil {
+		der, _ := x509.MarshalECPrivateKey(key)
+		os.WriteFile(path+".key", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := issue(t, "test-ca", nil, nil)
+	server, serverKey := issue(t, "server-1", ca, caKey)
+	client, clientKey := issue(t, "booking-api", ca, caKey)
+	writePEM(t, filepath.Join(dir, "ca.pem"), ca, nil)
+	writePEM(t, filepath.Join(dir, "server.pem"), server, serverKey)
+
+	cfg := config.MTLSConfig{
+		CertFile:       filepath.Join(dir, "server.pem"),
+		KeyFile:        filepath.Join(dir, "server.pem.key"),
+		ClientCAFile:   filepath.Join(dir, "ca.pem"),
+		ReloadInterval: 10 * time.Millisecond,
+	}
+	reloader, err := mtls.NewReloader(cfg)
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx)
+
+	clients := apiclients.NewMemoryStore()
+	clients.Create(ctx, &apiclients.Client{ID: "c-1", Name: "booking-api", Scopes: []string{apiclients.ScopeCardsRead}}, "hash")
+	auth := config.AuthConfig{Mode: middleware.AuthModeMTLS, Require: true}
+	app := fiber.New()
+	app.Get("/v1/whoami", middleware.RequireClientCert(auth, clients), func(c *fiber.Ctx) error {
+		return c.SendString(middleware.CallerFrom(c).Name)
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(tls.NewListener(ln, reloader.TLSConfig()))
+	defer app.Shutdown()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	get := func(withCert bool) (*http.Response, error) {
+		tlsCfg := &tls.Config{RootCAs: roots}
+		if withCert {
+			tlsCfg.Certificates = []tls.Certificate{{Certificate: [][]byte{client.Raw}, PrivateKey: clientKey}}
+		}
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsCfg}}
+		return c.Get("https://" + ln.Addr().String() + "/v1/whoami")
+	}
+
+	resp, err := get(true)
+	if err != nil {
+		t.Fatalf("request with a client certificate: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS.PeerCertificates[0].Subject.CommonName != "server-1" {
+		t.Fatalf("expected 200 from server-1, got %d from %s", resp.StatusCode, resp.TLS.PeerCertificates[0].Subject.CommonName)
+	}
+	if resp, err := get(false); err == nil {
+		resp.Body.Close()
+		t.Error("expected the handshake to fail without a client certificate")
+	}
+
+	renewed, renewedKey := issue(t, "server-2", ca, caKey)
+	writePEM(t, filepath.Join(dir, "server.pem"), renewed, renewedKey)
+	future := time.Now().Add(time.Minute)
+	for _, name := range []string{cfg.CertFile, cfg.KeyFile} {
+		os.Chtimes(name, future, future)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		resp, err := get(true)
+		if err == nil {
+			resp.Body.Close()
+			if resp.TLS.PeerCertificates[0].Subject.CommonName == "server-2" {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the renewed certificate to be served without a restart")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestNewReloader_RequiresFiles(t *testing.T) {
+	if _, err := mtls.NewReloader(config.MTLSConfig{CertFile: "server.pem"}); err == nil {
+		t.Error("expected an error without key and client CA files")
+	}
+}
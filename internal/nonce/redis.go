@@ -0,0 +1,32 @@
+package nonce
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces nonces in Redis.
+const redisKeyPrefix = "auth:nonce:"
+
+// RedisStore is a Store backed by Redis, shared by every instance.
+type RedisStore struct {
+	rdb *goredis.Client
+}
+
+var _ Store = (*RedisStore)(nil)
+
+// NewRedisStore creates a RedisStore.
+func NewRedisStore(rdb *goredis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+func (r *RedisStore) Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	ok, err := r.rdb.SetNX(ctx, redisKeyPrefix+nonce, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("nonce: claim: %w", err)
+	}
+	return ok, nil
+}
@@ -0,0 +1,46 @@
+// Package nonce records the nonces of signed requests so that a captured
+// request cannot be replayed while its timestamp is still accepted.
+package nonce
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store records used nonces.
+type Store interface {
+	// Claim atomically records nonce for ttl. It returns false if the nonce
+	// was already recorded and has not expired.
+	Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// MemoryStore is a Store held in process memory, for tests and single
+// instance development. Nonces are not shared between instances.
+type MemoryStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nonces: map[string]time.Time{}}
+}
+
+func (m *MemoryStore) Claim(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for n, expires := range m.nonces {
+		if now.After(expires) {
+			delete(m.nonces, n)
+		}
+	}
+	if _, ok := m.nonces[nonce]; ok {
+		return false, nil
+	}
+	m.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
@@ -10,12 +10,18 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/CentraGlobal/backend-payment-go/internal/processor"
 )
 
-var _ processor.Processor = (*Client)(nil)
+var (
+	_ processor.Relayer       = (*Client)(nil)
+	_ processor.UPG           = (*Client)(nil)
+	_ processor.UPGAuthorizer = (*Client)(nil)
+	_ processor.Refunder      = (*Client)(nil)
+)
 
 type Client struct {
 	apiKey     string
@@ -37,6 +43,23 @@ func (c *Client) Name() string {
 	return "pcibooking"
 }
 
+// Capabilities returns the operations supported by PCI Booking, including UPG.
+func (c *Client) Capabilities() processor.Capabilities {
+	return processor.Capabilities{
+		processor.CapabilityTokenize,
+		processor.CapabilityCardRead,
+		processor.CapabilityCardDelete,
+		processor.CapabilityRelay,
+		processor.CapabilitySessionToken,
+		processor.CapabilityCaptureForm,
+		processor.CapabilityUPGGateways,
+		processor.CapabilityUPGCharge,
+		processor.CapabilityUPGAuthorize,
+		processor.CapabilityUPGCredentials,
+		processor.CapabilityRefund,
+	}
+}
+
 func (c *Client) do(ctx context.Context, method, path string, queryParams url.Values, body any) ([]byte, int, error) {
 	endpoint := c.baseURL + path
 	if queryParams == nil {
@@ -68,17 +91,17 @@ func (c *Client) do(ctx context.Context, method, path string, queryParams url.Va
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, 0, fmt.Errorf("pcibooking: http do: %w", err)
+		return nil, 0, processor.TransportError("pcibooking", err)
 	}
 	defer resp.Body.Close()
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, resp.StatusCode, fmt.Errorf("pcibooking: read response: %w", err)
+		return nil, resp.StatusCode, processor.NewError(processor.ErrUpstreamUnavailable, "pcibooking", fmt.Errorf("pcibooking: read response: %w", err))
 	}
 
 	if resp.StatusCode >= 400 {
-		return nil, resp.StatusCode, fmt.Errorf("pcibooking: API error %d: %s", resp.StatusCode, string(data))
+		return nil, resp.StatusCode, processor.StatusError("pcibooking", resp.StatusCode, data)
 	}
 
 	return data, resp.StatusCode, nil
@@ -162,7 +185,9 @@ func (c *Client) CreateCard(ctx context.Context, card processor.Card) (*processo
 
 	data, _, err := c.do(ctx, http.MethodPost, "/api/payments/paycard/capture", nil, req)
 	if err != nil {
-		return nil, err
+		// The request carries only the card, so a rejected request is a
+		// rejected card.
+		return nil, processor.CardRejected(err)
 	}
 
 	var resp tokenizationResponse
@@ -272,12 +297,15 @@ type upgGatewayInfo struct {
 
 // upgChargeRequest is the payload sent to POST /api/paymentGateway.
 type upgChargeRequest struct {
-	Operation     string  `json:"Operation"`
-	CardToken     string  `json:"CardToken"`
-	Amount        float64 `json:"Amount"`
-	Currency      string  `json:"Currency"`
-	GatewayName   string  `json:"GatewayName"`
-	CredentialsID string  `json:"CredentialsID"`
+	Operation string `json:"Operation"`
+	CardToken string `json:"CardToken,omitempty"`
+	// TransactionID references the transaction to capture, void or refund.
+	TransactionID string `json:"TransactionID,omitempty"`
+	// Amount is a decimal in major units with the currency's exponent, e.g. 10.50.
+	Amount        json.Number `json:"Amount,omitempty"`
+	Currency      string      `json:"Currency,omitempty"`
+	GatewayName   string      `json:"GatewayName"`
+	CredentialsID string      `json:"CredentialsID"`
 }
 
 // upgChargeResponse is the raw response shape returned by POST /api/paymentGateway.
@@ -288,6 +316,17 @@ type upgChargeResponse struct {
 	Raw           json.RawMessage `json:"Raw,omitempty"`
 }
 
+// upgCredentialsRequest is the payload sent to POST /api/credentials.
+type upgCredentialsRequest struct {
+	GatewayName string            `json:"GatewayName"`
+	Credentials map[string]string `json:"Credentials"`
+}
+
+// upgCredentialsResponse is the raw response shape returned by POST /api/credentials.
+type upgCredentialsResponse struct {
+	CredentialsID string `json:"CredentialsID"`
+}
+
 // GetPaymentGateways returns the list of payment gateways supported by PCI Booking UPG.
 // API: GET /api/paymentGateway
 func (c *Client) GetPaymentGateways(ctx context.Context) ([]processor.GatewayInfo, error) {
@@ -326,18 +365,100 @@ func (c *Client) GetCredentialsStructure(ctx context.Context, gatewayName string
 	return structure, nil
 }
 
+// CreateUPGCredentials stores a gateway's credentials in PCI Booking and
+// returns the CredentialsID that UPG operations take.
+// API: POST /api/credentials
+func (c *Client) CreateUPGCredentials(ctx context.Context, gatewayName string, fields map[string]string) (string, error) {
+	data, _, err := c.do(ctx, http.MethodPost, "/api/credentials", nil, upgCredentialsRequest{
+		GatewayName: gatewayName,
+		Credentials: fields,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var resp upgCredentialsResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", fmt.Errorf("pcibooking: decode credentials response: %w", err)
+	}
+	if resp.CredentialsID == "" {
+		return "", fmt.Errorf("pcibooking: credentials response has no CredentialsID")
+	}
+	return resp.CredentialsID, nil
+}
+
+// DeleteUPGCredentials removes stored gateway credentials from PCI Booking.
+// API: DELETE /api/credentials/{credentialsID}
+func (c *Client) DeleteUPGCredentials(ctx context.Context, credentialsID string) error {
+	_, _, err := c.do(ctx, http.MethodDelete, "/api/credentials/"+url.PathEscape(credentialsID), nil, nil)
+	return err
+}
+
 // ChargeUPG processes a charge via the PCI Booking Universal Payment Gateway.
 // API: POST /api/paymentGateway with Operation=Charge
 func (c *Client) ChargeUPG(ctx context.Context, req processor.UPGChargeRequest) (*processor.UPGChargeResponse, error) {
-	upgReq := upgChargeRequest{
+	return c.upg(ctx, upgChargeRequest{
 		Operation:     "Charge",
 		CardToken:     req.CardToken,
-		Amount:        req.Amount,
-		Currency:      req.Currency,
+		Amount:        json.Number(req.Amount.Decimal()),
+		Currency:      req.Amount.Currency.Code,
 		GatewayName:   req.GatewayName,
 		CredentialsID: req.CredentialsID,
-	}
+	})
+}
+
+// PreAuthorizeUPG places a hold on the card via UPG.
+// API: POST /api/paymentGateway with Operation=PreAuth
+func (c *Client) PreAuthorizeUPG(ctx context.Context, req processor.UPGChargeRequest) (*processor.UPGChargeResponse, error) {
+	return c.upg(ctx, upgChargeRequest{
+		Operation:     "PreAuth",
+		CardToken:     req.CardToken,
+		Amount:        json.Number(req.Amount.Decimal()),
+		Currency:      req.Amount.Currency.Code,
+		GatewayName:   req.GatewayName,
+		CredentialsID: req.CredentialsID,
+	})
+}
+
+// CaptureUPG captures all or part of a pre-authorization via UPG.
+// API: POST /api/paymentGateway with Operation=Capture
+func (c *Client) CaptureUPG(ctx context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	return c.upg(ctx, upgChargeRequest{
+		Operation:     "Capture",
+		TransactionID: req.TransactionID,
+		Amount:        json.Number(req.Amount.Decimal()),
+		Currency:      req.Amount.Currency.Code,
+		GatewayName:   req.GatewayName,
+		CredentialsID: req.CredentialsID,
+	})
+}
+
+// VoidUPG releases a pre-authorization via UPG.
+// API: POST /api/paymentGateway with Operation=Void
+func (c *Client) VoidUPG(ctx context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	return c.upg(ctx, upgChargeRequest{
+		Operation:     "Void",
+		TransactionID: req.TransactionID,
+		GatewayName:   req.GatewayName,
+		CredentialsID: req.CredentialsID,
+	})
+}
+
+// RefundUPG refunds all or part of an earlier charge or capture via UPG.
+// API: POST /api/paymentGateway with Operation=Refund
+func (c *Client) RefundUPG(ctx context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	return c.upg(ctx, upgChargeRequest{
+		Operation:     "Refund",
+		TransactionID: req.TransactionID,
+		Amount:        json.Number(req.Amount.Decimal()),
+		Currency:      req.Amount.Currency.Code,
+		GatewayName:   req.GatewayName,
+		CredentialsID: req.CredentialsID,
+	})
+}
 
+// upg sends a UPG operation and decodes its result.
+func (c *Client) upg(ctx context.Context, upgReq upgChargeRequest) (*processor.UPGChargeResponse, error) {
 	data, _, err := c.do(ctx, http.MethodPost, "/api/paymentGateway", nil, upgReq)
 	if err != nil {
 		return nil, err
@@ -345,7 +466,7 @@ func (c *Client) ChargeUPG(ctx context.Context, req processor.UPGChargeRequest)
 
 	var resp upgChargeResponse
 	if err := json.Unmarshal(data, &resp); err != nil {
-		return nil, fmt.Errorf("pcibooking: decode upg charge response: %w", err)
+		return nil, fmt.Errorf("pcibooking: decode upg %s response: %w", strings.ToLower(upgReq.Operation), err)
 	}
 
 	return &processor.UPGChargeResponse{
@@ -3,6 +3,7 @@ package pcibooking_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -219,4 +220,7 @@ func TestClient_APIError(t *testing.T) {
 	if err == nil {
 		t.Error("expected error on API failure")
 	}
+	if !errors.Is(err, processor.ErrInvalidCard) {
+		t.Errorf("expected ErrInvalidCard, got %v", err)
+	}
 }
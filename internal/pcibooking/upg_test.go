@@ -3,10 +3,14 @@ package pcibooking_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
 	"github.com/CentraGlobal/backend-payment-go/internal/pcibooking"
 	"github.com/CentraGlobal/backend-payment-go/internal/processor"
 )
@@ -102,10 +106,14 @@ func TestClient_ChargeUPG(t *testing.T) {
 			t.Errorf("unexpected path %s", r.URL.Path)
 		}
 
+		raw, _ := io.ReadAll(r.Body)
 		var body map[string]any
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		if err := json.Unmarshal(raw, &body); err != nil {
 			t.Errorf("failed to decode request body: %v", err)
 		}
+		if !strings.Contains(string(raw), `"Amount":150.00,"Currency":"USD"`) {
+			t.Errorf("expected decimal amount and currency on the wire, got %s", raw)
+		}
 		if body["Operation"] != "Charge" {
 			t.Errorf("expected Operation=Charge, got %v", body["Operation"])
 		}
@@ -126,8 +134,7 @@ func TestClient_ChargeUPG(t *testing.T) {
 
 	req := processor.UPGChargeRequest{
 		CardToken:     "tok_test123",
-		Amount:        150.00,
-		Currency:      "USD",
+		Amount:        money.MustParse("150.00", "USD"),
 		GatewayName:   "Stripe",
 		CredentialsID: "hotel-123-stripe-creds",
 	}
@@ -156,12 +163,12 @@ func TestClient_ChargeUPG_APIError(t *testing.T) {
 
 	_, err := client.ChargeUPG(context.Background(), processor.UPGChargeRequest{
 		CardToken:     "tok_test",
-		Currency:      "USD",
+		Amount:        money.MustParse("150.00", "USD"),
 		GatewayName:   "Stripe",
 		CredentialsID: "creds-123",
 	})
-	if err == nil {
-		t.Error("expected error on API failure")
+	if !errors.Is(err, processor.ErrBadRequest) || errors.Is(err, processor.ErrInvalidCard) {
+		t.Errorf("expected ErrBadRequest, got %v", err)
 	}
 }
 
@@ -179,3 +186,113 @@ func TestClient_GetPaymentGateways_APIError(t *testing.T) {
 		t.Error("expected error on API failure")
 	}
 }
+
+func TestClient_CaptureAndVoidUPG(t *testing.T) {
+	var bodies []map[string]any
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		json.NewEncoder(w).Encode(map[string]any{"Status": "Success", "TransactionID": "txn_" + body["Operation"].(string)})
+	}))
+	defer mockServer.Close()
+
+	client := newTestClient(mockServer.URL)
+	ctx := context.Background()
+
+	auth, err := client.PreAuthorizeUPG(ctx, processor.UPGChargeRequest{
+		CardToken: "tok", Amount: money.MustParse("80.00", "EUR"), GatewayName: "Stripe", CredentialsID: "creds",
+	})
+	if err != nil || auth.TransactionID != "txn_PreAuth" {
+		t.Fatalf("PreAuthorizeUPG: %v %+v", err, auth)
+	}
+	if _, err := client.CaptureUPG(ctx, processor.UPGTransactionRequest{
+		TransactionID: auth.TransactionID, Amount: money.MustParse("50.00", "EUR"), GatewayName: "Stripe", CredentialsID: "creds",
+	}); err != nil {
+		t.Fatalf("CaptureUPG: %v", err)
+	}
+	if _, err := client.VoidUPG(ctx, processor.UPGTransactionRequest{
+		TransactionID: auth.TransactionID, GatewayName: "Stripe", CredentialsID: "creds",
+	}); err != nil {
+		t.Fatalf("VoidUPG: %v", err)
+	}
+
+	if len(bodies) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(bodies))
+	}
+	if bodies[0]["Operation"] != "PreAuth" || bodies[0]["CardToken"] != "tok" || bodies[0]["Amount"] != 80.0 {
+		t.Errorf("unexpected pre-auth request: %v", bodies[0])
+	}
+	capture := bodies[1]
+	if capture["Operation"] != "Capture" || capture["TransactionID"] != "txn_PreAuth" || capture["Amount"] != 50.0 || capture["Currency"] != "EUR" {
+		t.Errorf("unexpected capture request: %v", capture)
+	}
+	if _, ok := capture["CardToken"]; ok {
+		t.Errorf("expected no CardToken on capture, got %v", capture)
+	}
+	void := bodies[2]
+	if void["Operation"] != "Void" || void["TransactionID"] != "txn_PreAuth" {
+		t.Errorf("unexpected void request: %v", void)
+	}
+	if _, ok := void["Amount"]; ok {
+		t.Errorf("expected no Amount on void, got %v", void)
+	}
+}
+
+func TestClient_RefundUPG(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(raw), `"Operation":"Refund","TransactionID":"txn_abc123","Amount":25.50,"Currency":"USD"`) {
+			t.Errorf("unexpected refund request %s", raw)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"Status": "Success", "TransactionID": "rfd_1"})
+	}))
+	defer mockServer.Close()
+
+	resp, err := newTestClient(mockServer.URL).RefundUPG(context.Background(), processor.UPGTransactionRequest{
+		TransactionID: "txn_abc123",
+		Amount:        money.MustParse("25.50", "USD"),
+		GatewayName:   "Stripe",
+		CredentialsID: "creds-123",
+	})
+	if err != nil {
+		t.Fatalf("RefundUPG failed: %v", err)
+	}
+	if resp.Status != "Success" || resp.TransactionID != "rfd_1" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestClient_UPGCredentials(t *testing.T) {
+	var deleted string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/credentials":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			creds, _ := body["Credentials"].(map[string]any)
+			if body["GatewayName"] != "Stripe" || creds["secret_key"] != "sk_1" {
+				t.Errorf("unexpected credentials request %v", body)
+			}
+			json.NewEncoder(w).Encode(map[string]any{"CredentialsID": "cred-123"})
+		case r.Method == http.MethodDelete:
+			deleted = r.URL.Path
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer mockServer.Close()
+
+	client := newTestClient(mockServer.URL)
+	id, err := client.CreateUPGCredentials(context.Background(), "Stripe", map[string]string{"secret_key": "sk_1"})
+	if err != nil || id != "cred-123" {
+		t.Fatalf("CreateUPGCredentials: %q %v", id, err)
+	}
+	if err := client.DeleteUPGCredentials(context.Background(), id); err != nil {
+		t.Fatalf("DeleteUPGCredentials: %v", err)
+	}
+	if deleted != "/api/credentials/cred-123" {
+		t.Errorf("unexpected delete path %q", deleted)
+	}
+}
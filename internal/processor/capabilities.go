@@ -0,0 +1,54 @@
+package processor
+
+// Capability names an operation a processor may support.
+type Capability string
+
+const (
+	CapabilityTokenize       Capability = "tokenize"
+	CapabilityCardRead       Capability = "card_read"
+	CapabilityCardDelete     Capability = "card_delete"
+	CapabilityRelay          Capability = "relay"
+	CapabilitySessionToken   Capability = "session_token"
+	CapabilityCaptureForm    Capability = "capture_form"
+	CapabilityUPGGateways    Capability = "upg_gateways"
+	CapabilityUPGCharge      Capability = "upg_charge"
+	CapabilityUPGAuthorize   Capability = "upg_authorize"   // pre-authorize, capture and void
+	CapabilityUPGCredentials Capability = "upg_credentials" // register gateway credentials
+	CapabilityRefund         Capability = "refund"
+	CapabilityThreeDS        Capability = "three_d_secure" // UPG charges may return Accepted pending 3DS
+)
+
+// Capabilities is the set of operations a processor declares support for.
+type Capabilities []Capability
+
+// Has reports whether c is in the set.
+func (cs Capabilities) Has(c Capability) bool {
+	for _, have := range cs {
+		if have == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Require returns an ErrUnsupported error when p does not declare c.
+func Require(p Processor, c Capability) error {
+	if p.Capabilities().Has(c) {
+		return nil
+	}
+	return Unsupported(p.Name(), string(c))
+}
+
+// As returns p as the optional interface T when p declares c and implements
+// T, and an ErrUnsupported error otherwise.
+func As[T Processor](p Processor, c Capability) (T, error) {
+	var zero T
+	if err := Require(p, c); err != nil {
+		return zero, err
+	}
+	t, ok := p.(T)
+	if !ok {
+		return zero, Unsupported(p.Name(), string(c))
+	}
+	return t, nil
+}
@@ -0,0 +1,24 @@
+package processor_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+)
+
+func TestAs(t *testing.T) {
+	vault := &vaultStub{name: "vault"}
+
+	if _, err := processor.As[processor.Relayer](vault, processor.CapabilityRelay); err != nil {
+		t.Errorf("As Relayer: %v", err)
+	}
+	// Declared capabilities are checked before the type.
+	if _, err := processor.As[processor.Relayer](vault, processor.CapabilityRefund); !errors.Is(err, processor.ErrUnsupported) {
+		t.Errorf("As Relayer without the capability: expected ErrUnsupported, got %v", err)
+	}
+	// A declared capability without the interface is unsupported too.
+	if _, err := processor.As[processor.UPG](vault, processor.CapabilityTokenize); !errors.Is(err, processor.ErrUnsupported) {
+		t.Errorf("As UPG: expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// Error categories returned by processor implementations. Callers should test
+// for them with errors.Is; the concrete error is usually an *Error.
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrInvalidCard         = errors.New("invalid card")
+	ErrDeclined            = errors.New("declined")
+	ErrUnsupported         = errors.New("unsupported operation")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	ErrRateLimited         = errors.New("rate limited")
+	ErrAuthFailure         = errors.New("authentication failure")
+	// ErrBadRequest is a request the processor rejected as invalid (400 or
+	// 422) for a reason other than the card details.
+	ErrBadRequest = errors.New("bad request")
+)
+
+// ErrNotSent marks failures where the request provably never reached the
+// upstream API, such as a refused connection, a DNS failure or an open circuit
+// breaker. Unlike other ErrUpstreamUnavailable failures (timeouts, 5xx
+// responses), it is safe to send a call that moves money elsewhere after one.
+var ErrNotSent = errors.New("request not sent")
+
+// Error is a failure reported by a processor. Kind is one of the Err*
+// categories above, or nil when the failure does not fit any of them. Err holds
+// the underlying cause, which may include the upstream response body and must
+// therefore never be returned to API callers.
+type Error struct {
+	Kind       error
+	Processor  string
+	StatusCode int
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	if e.Kind != nil {
+		return e.Processor + ": " + e.Kind.Error()
+	}
+	return e.Processor + ": unknown error"
+}
+
+// Unwrap exposes both the category and the cause to errors.Is/As.
+func (e *Error) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// NewError returns an *Error of the given kind for the named processor.
+func NewError(kind error, processorName string, err error) *Error {
+	return &Error{Kind: kind, Processor: processorName, Err: err}
+}
+
+// Unsupported returns an ErrUnsupported error for operation on the named processor.
+func Unsupported(processorName, operation string) *Error {
+	return NewError(ErrUnsupported, processorName,
+		fmt.Errorf("%s: %s is not supported", processorName, operation))
+}
+
+// StatusError classifies an upstream HTTP error response. The response body is
+// kept in the cause for logging only.
+func StatusError(processorName string, statusCode int, body []byte) *Error {
+	return &Error{
+		Kind:       KindForStatus(statusCode),
+		Processor:  processorName,
+		StatusCode: statusCode,
+		Err:        fmt.Errorf("%s: API error %d: %s", processorName, statusCode, string(body)),
+	}
+}
+
+// CardRejected reclassifies an ErrBadRequest error from a call that submits
+// nothing but card details, such as tokenization, as ErrInvalidCard. Other
+// errors are returned unchanged.
+func CardRejected(err error) error {
+	var pe *Error
+	if !errors.As(err, &pe) || pe.Kind != ErrBadRequest {
+		return err
+	}
+	rejected := *pe
+	rejected.Kind = ErrInvalidCard
+	return &rejected
+}
+
+// TransportError classifies a failure to reach the upstream API. Failures to
+// connect are also marked ErrNotSent.
+func TransportError(processorName string, err error) *Error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		err = fmt.Errorf("%w (%w)", err, ErrNotSent)
+	}
+	return NewError(ErrUpstreamUnavailable, processorName, fmt.Errorf("%s: http do: %w", processorName, err))
+}
+
+// KindForStatus maps an upstream HTTP status code to an error category. It
+// returns nil for statuses with no specific category. A 400 or 422 is
+// ErrBadRequest: only the processor can tell whether the card was at fault
+// (see CardRejected).
+func KindForStatus(statusCode int) error {
+	switch {
+	case statusCode == http.StatusNotFound:
+		return ErrNotFound
+	case statusCode == http.StatusBadRequest, statusCode == http.StatusUnprocessableEntity:
+		return ErrBadRequest
+	case statusCode == http.StatusPaymentRequired:
+		return ErrDeclined
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return ErrAuthFailure
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode == http.StatusNotImplemented:
+		return ErrUnsupported
+	case statusCode >= 500:
+		return ErrUpstreamUnavailable
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package processor_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+)
+
+func TestStatusError_Kinds(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, processor.ErrNotFound},
+		{http.StatusBadRequest, processor.ErrBadRequest},
+		{http.StatusUnprocessableEntity, processor.ErrBadRequest},
+		{http.StatusPaymentRequired, processor.ErrDeclined},
+		{http.StatusUnauthorized, processor.ErrAuthFailure},
+		{http.StatusForbidden, processor.ErrAuthFailure},
+		{http.StatusTooManyRequests, processor.ErrRateLimited},
+		{http.StatusInternalServerError, processor.ErrUpstreamUnavailable},
+		{http.StatusBadGateway, processor.ErrUpstreamUnavailable},
+	}
+
+	for _, tc := range tests {
+		err := processor.StatusError("vaultera", tc.status, []byte(`{"error":"x"}`))
+		if !errors.Is(err, tc.want) {
+			t.Errorf("status %d: expected %v, got %v", tc.status, tc.want, err)
+		}
+	}
+}
+
+func TestStatusError_Uncategorized(t *testing.T) {
+	err := processor.StatusError("vaultera", http.StatusConflict, []byte(`conflict`))
+	if err.Kind != nil {
+		t.Errorf("expected no kind for 409, got %v", err.Kind)
+	}
+
+	var pe *processor.Error
+	if !errors.As(error(err), &pe) || pe.StatusCode != http.StatusConflict {
+		t.Errorf("expected *processor.Error with status 409, got %#v", err)
+	}
+	if !strings.Contains(err.Error(), "API error 409") {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
+
+func TestCardRejected(t *testing.T) {
+	err := processor.CardRejected(processor.StatusError("vaultera", http.StatusUnprocessableEntity, []byte(`{}`)))
+	if !errors.Is(err, processor.ErrInvalidCard) || errors.Is(err, processor.ErrBadRequest) {
+		t.Errorf("expected a bad request to become ErrInvalidCard, got %v", err)
+	}
+	var pe *processor.Error
+	if !errors.As(err, &pe) || pe.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected the status code kept, got %#v", err)
+	}
+
+	unavailable := processor.StatusError("vaultera", http.StatusBadGateway, nil)
+	if got := processor.CardRejected(unavailable); got != error(unavailable) {
+		t.Errorf("expected other errors unchanged, got %v", got)
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	err := processor.Unsupported("vaultera", "UPG")
+	if !errors.Is(err, processor.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if err.Error() != "vaultera: UPG is not supported" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
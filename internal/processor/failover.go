@@ -0,0 +1,288 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// TokenMirror maps a card token in one vault to the token for the same card in
+// another vault.
+type TokenMirror interface {
+	// MirrorToken returns the token in the secondary vault for primaryToken, or
+	// an empty string when the card is not known to exist in both vaults.
+	MirrorToken(ctx context.Context, primary, secondary, primaryToken string) (string, error)
+	// Put records that primaryToken and secondaryToken refer to the same card.
+	Put(ctx context.Context, primary, secondary, primaryToken, secondaryToken string) error
+}
+
+// sessionTokenTTL bounds how long Failover remembers which processor issued a
+// session token, which is needed to build the matching capture form URL.
+const sessionTokenTTL = time.Hour
+
+// Failover is a Processor that sends every call to a primary processor and
+// retries SendCard and CreateSessionToken on a secondary processor when the
+// primary is unavailable.
+//
+// CreateSessionToken fails over on any ErrUpstreamUnavailable. SendCard relays
+// a charge, so it only fails over when the request provably never reached the
+// primary (ErrNotSent): after a timeout or a 5xx the charge may already have
+// gone through at the gateway. It also requires the mirror to report that the
+// card token exists in the secondary vault; the mirrored token is used for the
+// secondary call and card placeholders are rewritten to the secondary vault's
+// syntax. All other operations are served by the primary only.
+//
+// Cards tokenized through CreateCard are also tokenized in the secondary vault
+// and recorded in the mirror, and DeleteCard deletes both tokens. Cards
+// captured through the primary's hosted form are not mirrored.
+//
+// The processor that served SendCard and CreateSessionToken is recorded in
+// the Processor field of the response.
+//
+// Failover implements every optional interface; each returns an
+// ErrUnsupported error when the primary does not offer the operation.
+type Failover struct {
+	primary   Processor
+	secondary Processor
+	mirror    TokenMirror
+
+	// sessions records session tokens issued by the secondary processor.
+	mu       sync.Mutex
+	sessions map[string]sessionIssuer
+}
+
+type sessionIssuer struct {
+	p        Processor
+	issuedAt time.Time
+}
+
+var (
+	_ Relayer       = (*Failover)(nil)
+	_ UPG           = (*Failover)(nil)
+	_ UPGAuthorizer = (*Failover)(nil)
+	_ Refunder      = (*Failover)(nil)
+)
+
+// NewFailover wraps primary with failover to secondary. mirror may be nil, in
+// which case SendCard never fails over.
+func NewFailover(primary, secondary Processor, mirror TokenMirror) *Failover {
+	return &Failover{
+		primary:   primary,
+		secondary: secondary,
+		mirror:    mirror,
+		sessions:  map[string]sessionIssuer{},
+	}
+}
+
+// Name returns the primary processor's name.
+func (f *Failover) Name() string {
+	return f.primary.Name()
+}
+
+// Capabilities returns the primary processor's capabilities.
+func (f *Failover) Capabilities() Capabilities {
+	return f.primary.Capabilities()
+}
+
+// CreateCard tokenizes card in the primary vault. When a mirror is set, the
+// card is also tokenized in the secondary vault so that charges with the
+// token can fail over; failing to mirror it does not fail the call.
+func (f *Failover) CreateCard(ctx context.Context, card Card) (*CardResponse, error) {
+	resp, err := f.primary.CreateCard(ctx, card)
+	if err != nil || f.mirror == nil || !f.secondary.Capabilities().Has(CapabilityTokenize) {
+		return resp, err
+	}
+
+	mirrored, mirrorErr := f.secondary.CreateCard(ctx, card)
+	if mirrorErr != nil {
+		log.Printf("failover: tokenize card on %s failed, token not mirrored: %v", f.secondary.Name(), mirrorErr)
+		return resp, nil
+	}
+	mirrorErr = f.mirror.Put(ctx, f.primary.Name(), f.secondary.Name(), resp.CardToken, mirrored.CardToken)
+	if mirrorErr != nil {
+		log.Printf("failover: record mirror on %s failed: %v", f.secondary.Name(), mirrorErr)
+	}
+	return resp, nil
+}
+
+func (f *Failover) GetCard(ctx context.Context, cardToken string) (*CardResponse, error) {
+	return f.primary.GetCard(ctx, cardToken)
+}
+
+// DeleteCard deletes cardToken from the primary vault and its mirrored token,
+// if any, from the secondary vault.
+func (f *Failover) DeleteCard(ctx context.Context, cardToken string) error {
+	if err := f.primary.DeleteCard(ctx, cardToken); err != nil {
+		return err
+	}
+	if f.mirror == nil {
+		return nil
+	}
+	secondaryToken, err := f.mirror.MirrorToken(ctx, f.primary.Name(), f.secondary.Name(), cardToken)
+	if err != nil || secondaryToken == "" {
+		if err != nil {
+			log.Printf("failover: mirror lookup for %s failed: %v", f.secondary.Name(), err)
+		}
+		return nil
+	}
+	if err := f.secondary.DeleteCard(ctx, secondaryToken); err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("failover: delete mirrored card on %s failed: %v", f.secondary.Name(), err)
+	}
+	return nil
+}
+
+func (f *Failover) SendCard(ctx context.Context, cardToken string, req SendRequest) (*SendResponse, error) {
+	primary, err := As[Relayer](f.primary, CapabilityRelay)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := primary.SendCard(ctx, cardToken, req)
+	if err == nil {
+		resp.Processor = f.primary.Name()
+		return resp, nil
+	}
+	if !errors.Is(err, ErrNotSent) || f.mirror == nil {
+		return nil, err
+	}
+	secondary, relayErr := As[Relayer](f.secondary, CapabilityRelay)
+	if relayErr != nil {
+		return nil, err
+	}
+
+	secondaryToken, mirrorErr := f.mirror.MirrorToken(ctx, f.primary.Name(), f.secondary.Name(), cardToken)
+	if mirrorErr != nil {
+		log.Printf("failover: mirror lookup for %s failed: %v", f.secondary.Name(), mirrorErr)
+		return nil, err
+	}
+	if secondaryToken == "" {
+		return nil, err
+	}
+
+	log.Printf("failover: %s unavailable for send, retrying on %s: %v", f.primary.Name(), f.secondary.Name(), err)
+	resp, err = secondary.SendCard(ctx, secondaryToken, translateRequest(req, f.primary.Name(), f.secondary.Name()))
+	if err != nil {
+		return nil, err
+	}
+	resp.Processor = f.secondary.Name()
+	return resp, nil
+}
+
+func (f *Failover) CreateSessionToken(ctx context.Context, scope string) (*SessionTokenResponse, error) {
+	issuer := f.primary
+	resp, err := f.primary.CreateSessionToken(ctx, scope)
+	if err != nil {
+		if !errors.Is(err, ErrUpstreamUnavailable) || !f.secondary.Capabilities().Has(CapabilitySessionToken) {
+			return nil, err
+		}
+		log.Printf("failover: %s unavailable for session token, retrying on %s: %v", f.primary.Name(), f.secondary.Name(), err)
+		issuer = f.secondary
+		resp, err = f.secondary.CreateSessionToken(ctx, scope)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp.Processor = issuer.Name()
+	if issuer == f.secondary {
+		f.rememberSession(resp.Token, issuer)
+	}
+	return resp, nil
+}
+
+// CaptureFormURL builds the capture form URL on whichever processor issued the
+// session token, defaulting to the primary.
+func (f *Failover) CaptureFormURL(sessionToken string) string {
+	f.mu.Lock()
+	s, ok := f.sessions[sessionToken]
+	f.mu.Unlock()
+	if ok {
+		return s.p.CaptureFormURL(sessionToken)
+	}
+	return f.primary.CaptureFormURL(sessionToken)
+}
+
+func (f *Failover) rememberSession(token string, p Processor) {
+	now := time.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for t, s := range f.sessions {
+		if now.Sub(s.issuedAt) > sessionTokenTTL {
+			delete(f.sessions, t)
+		}
+	}
+	f.sessions[token] = sessionIssuer{p: p, issuedAt: now}
+}
+
+func (f *Failover) GetPaymentGateways(ctx context.Context) ([]GatewayInfo, error) {
+	p, err := As[UPG](f.primary, CapabilityUPGGateways)
+	if err != nil {
+		return nil, err
+	}
+	return p.GetPaymentGateways(ctx)
+}
+
+func (f *Failover) GetCredentialsStructure(ctx context.Context, gatewayName string) (map[string]any, error) {
+	p, err := As[UPG](f.primary, CapabilityUPGGateways)
+	if err != nil {
+		return nil, err
+	}
+	return p.GetCredentialsStructure(ctx, gatewayName)
+}
+
+func (f *Failover) CreateUPGCredentials(ctx context.Context, gatewayName string, fields map[string]string) (string, error) {
+	p, err := As[UPG](f.primary, CapabilityUPGCredentials)
+	if err != nil {
+		return "", err
+	}
+	return p.CreateUPGCredentials(ctx, gatewayName, fields)
+}
+
+func (f *Failover) DeleteUPGCredentials(ctx context.Context, credentialsID string) error {
+	p, err := As[UPG](f.primary, CapabilityUPGCredentials)
+	if err != nil {
+		return err
+	}
+	return p.DeleteUPGCredentials(ctx, credentialsID)
+}
+
+func (f *Failover) ChargeUPG(ctx context.Context, req UPGChargeRequest) (*UPGChargeResponse, error) {
+	p, err := As[UPG](f.primary, CapabilityUPGCharge)
+	if err != nil {
+		return nil, err
+	}
+	return p.ChargeUPG(ctx, req)
+}
+
+func (f *Failover) PreAuthorizeUPG(ctx context.Context, req UPGChargeRequest) (*UPGChargeResponse, error) {
+	p, err := As[UPGAuthorizer](f.primary, CapabilityUPGAuthorize)
+	if err != nil {
+		return nil, err
+	}
+	return p.PreAuthorizeUPG(ctx, req)
+}
+
+func (f *Failover) CaptureUPG(ctx context.Context, req UPGTransactionRequest) (*UPGChargeResponse, error) {
+	p, err := As[UPGAuthorizer](f.primary, CapabilityUPGAuthorize)
+	if err != nil {
+		return nil, err
+	}
+	return p.CaptureUPG(ctx, req)
+}
+
+func (f *Failover) VoidUPG(ctx context.Context, req UPGTransactionRequest) (*UPGChargeResponse, error) {
+	p, err := As[UPGAuthorizer](f.primary, CapabilityUPGAuthorize)
+	if err != nil {
+		return nil, err
+	}
+	return p.VoidUPG(ctx, req)
+}
+
+func (f *Failover) RefundUPG(ctx context.Context, req UPGTransactionRequest) (*UPGChargeResponse, error) {
+	p, err := As[Refunder](f.primary, CapabilityRefund)
+	if err != nil {
+		return nil, err
+	}
+	return p.RefundUPG(ctx, req)
+}
@@ -0,0 +1,206 @@
+package processor_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+)
+
+// vaultStub is a Processor whose relay and session calls return fixed results.
+type vaultStub struct {
+	processor.Processor
+	name     string
+	sendErr  error
+	sendToks []string
+	lastReq  processor.SendRequest
+	sessErr  error
+	deleted  []string
+}
+
+func (v *vaultStub) Name() string { return v.name }
+func (v *vaultStub) Capabilities() processor.Capabilities {
+	return processor.Capabilities{processor.CapabilityTokenize, processor.CapabilityRelay, processor.CapabilitySessionToken}
+}
+func (v *vaultStub) CreateCard(_ context.Context, card processor.Card) (*processor.CardResponse, error) {
+	return &processor.CardResponse{CardToken: "tok_" + v.name}, nil
+}
+func (v *vaultStub) DeleteCard(_ context.Context, cardToken string) error {
+	v.deleted = append(v.deleted, cardToken)
+	return nil
+}
+func (v *vaultStub) SendCard(_ context.Context, cardToken string, req processor.SendRequest) (*processor.SendResponse, error) {
+	v.sendToks = append(v.sendToks, cardToken)
+	v.lastReq = req
+	if v.sendErr != nil {
+		return nil, v.sendErr
+	}
+	return &processor.SendResponse{StatusCode: 200}, nil
+}
+func (v *vaultStub) CreateSessionToken(_ context.Context, scope string) (*processor.SessionTokenResponse, error) {
+	if v.sessErr != nil {
+		return nil, v.sessErr
+	}
+	return &processor.SessionTokenResponse{Token: "st_" + v.name, Scope: scope}, nil
+}
+func (v *vaultStub) CaptureFormURL(sessionToken string) string {
+	return "https://" + v.name + "/form?session_token=" + sessionToken
+}
+
+type mirrorStub map[string]string
+
+func (m mirrorStub) MirrorToken(_ context.Context, _, _, primaryToken string) (string, error) {
+	return m[primaryToken], nil
+}
+func (m mirrorStub) Put(_ context.Context, _, _, primaryToken, secondaryToken string) error {
+	m[primaryToken] = secondaryToken
+	return nil
+}
+
+var errDown = processor.TransportError("primary", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+
+func TestFailover_SendCard_PrimaryHealthy(t *testing.T) {
+	primary := &vaultStub{name: "primary"}
+	secondary := &vaultStub{name: "secondary"}
+	f := processor.NewFailover(primary, secondary, mirrorStub{"tok_p": "tok_s"})
+
+	resp, err := f.SendCard(context.Background(), "tok_p", processor.SendRequest{})
+	if err != nil {
+		t.Fatalf("SendCard: %v", err)
+	}
+	if resp.Processor != "primary" {
+		t.Errorf("expected primary to serve, got %q", resp.Processor)
+	}
+	if len(secondary.sendToks) != 0 {
+		t.Error("secondary should not be called")
+	}
+}
+
+func TestFailover_SendCard_FailsOverWithMirroredToken(t *testing.T) {
+	primary := &vaultStub{name: "primary", sendErr: errDown}
+	secondary := &vaultStub{name: "secondary"}
+	f := processor.NewFailover(primary, secondary, mirrorStub{"tok_p": "tok_s"})
+
+	resp, err := f.SendCard(context.Background(), "tok_p", processor.SendRequest{})
+	if err != nil {
+		t.Fatalf("SendCard: %v", err)
+	}
+	if resp.Processor != "secondary" {
+		t.Errorf("expected secondary to serve, got %q", resp.Processor)
+	}
+	if len(secondary.sendToks) != 1 || secondary.sendToks[0] != "tok_s" {
+		t.Errorf("expected secondary called with mirrored token, got %v", secondary.sendToks)
+	}
+}
+
+func TestFailover_SendCard_TranslatesPlaceholders(t *testing.T) {
+	primary := &vaultStub{name: "vaultera", sendErr: errDown}
+	secondary := &vaultStub{name: "pcibooking"}
+	f := processor.NewFailover(primary, secondary, mirrorStub{"tok_p": "tok_s"})
+
+	_, err := f.SendCard(context.Background(), "tok_p", processor.SendRequest{
+		URL:     "https://gateway.example/charge",
+		Headers: map[string]string{"X-Holder": "%CARDHOLDER_NAME%"},
+		Body:    `{"number":"%CARD_NUMBER%","exp":"%CARD_EXPIRATION_MONTH%/%CARD_EXPIRATION_YEAR%"}`,
+	})
+	if err != nil {
+		t.Fatalf("SendCard: %v", err)
+	}
+	want := `{"number":"{{{CardNumber}}}","exp":"{{{ExpirationMM}}}/{{{ExpirationYYYY}}}"}`
+	if secondary.lastReq.Body != want {
+		t.Errorf("expected body %s, got %s", want, secondary.lastReq.Body)
+	}
+	if secondary.lastReq.Headers["X-Holder"] != "{{{CardholderName}}}" {
+		t.Errorf("expected translated header, got %q", secondary.lastReq.Headers["X-Holder"])
+	}
+}
+
+func TestFailover_SendCard_NoFailover(t *testing.T) {
+	tests := []struct {
+		name    string
+		sendErr error
+		mirror  processor.TokenMirror
+	}{
+		{"token not mirrored", errDown, mirrorStub{}},
+		{"no mirror store", errDown, nil},
+		{"non-connectivity error", processor.StatusError("primary", 422, nil), mirrorStub{"tok_p": "tok_s"}},
+		// The charge may have reached the gateway before these failures.
+		{"upstream 5xx", processor.StatusError("primary", 502, nil), mirrorStub{"tok_p": "tok_s"}},
+		{"timeout", processor.TransportError("primary", context.DeadlineExceeded), mirrorStub{"tok_p": "tok_s"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			primary := &vaultStub{name: "primary", sendErr: tc.sendErr}
+			secondary := &vaultStub{name: "secondary"}
+			f := processor.NewFailover(primary, secondary, tc.mirror)
+
+			_, err := f.SendCard(context.Background(), "tok_p", processor.SendRequest{})
+			if !errors.Is(err, tc.sendErr) {
+				t.Errorf("expected primary error, got %v", err)
+			}
+			if len(secondary.sendToks) != 0 {
+				t.Error("secondary should not be called")
+			}
+		})
+	}
+}
+
+func TestFailover_UnsupportedOperations(t *testing.T) {
+	f := processor.NewFailover(&vaultStub{name: "primary"}, &vaultStub{name: "secondary"}, nil)
+
+	if _, err := f.ChargeUPG(context.Background(), processor.UPGChargeRequest{}); !errors.Is(err, processor.ErrUnsupported) {
+		t.Errorf("ChargeUPG: expected ErrUnsupported, got %v", err)
+	}
+	if _, err := f.RefundUPG(context.Background(), processor.UPGTransactionRequest{}); !errors.Is(err, processor.ErrUnsupported) {
+		t.Errorf("RefundUPG: expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestFailover_CreateSessionToken(t *testing.T) {
+	primary := &vaultStub{name: "primary", sessErr: errDown}
+	secondary := &vaultStub{name: "secondary"}
+	f := processor.NewFailover(primary, secondary, nil)
+
+	resp, err := f.CreateSessionToken(context.Background(), "card")
+	if err != nil {
+		t.Fatalf("CreateSessionToken: %v", err)
+	}
+	if resp.Processor != "secondary" || resp.Token != "st_secondary" {
+		t.Errorf("expected secondary session token, got %+v", resp)
+	}
+
+	if got := f.CaptureFormURL(resp.Token); got != "https://secondary/form?session_token=st_secondary" {
+		t.Errorf("expected capture form on secondary, got %q", got)
+	}
+	if got := f.CaptureFormURL("st_other"); got != "https://primary/form?session_token=st_other" {
+		t.Errorf("expected capture form on primary for unknown token, got %q", got)
+	}
+}
+
+func TestFailover_CreateCard_MirrorsToken(t *testing.T) {
+	primary := &vaultStub{name: "primary"}
+	secondary := &vaultStub{name: "secondary"}
+	mirror := mirrorStub{}
+	f := processor.NewFailover(primary, secondary, mirror)
+
+	card, err := f.CreateCard(context.Background(), processor.Card{CardNumber: "4111111111111111"})
+	if err != nil {
+		t.Fatalf("CreateCard: %v", err)
+	}
+	if card.CardToken != "tok_primary" {
+		t.Errorf("expected the primary token, got %q", card.CardToken)
+	}
+	if mirror["tok_primary"] != "tok_secondary" {
+		t.Errorf("expected the secondary token recorded, got %v", mirror)
+	}
+
+	if err := f.DeleteCard(context.Background(), "tok_primary"); err != nil {
+		t.Fatalf("DeleteCard: %v", err)
+	}
+	if len(secondary.deleted) != 1 || secondary.deleted[0] != "tok_secondary" {
+		t.Errorf("expected the mirrored card deleted, got %v", secondary.deleted)
+	}
+}
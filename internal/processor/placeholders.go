@@ -0,0 +1,76 @@
+package processor
+
+import "strings"
+
+// Placeholders are the template tokens a vault replaces with card data when
+// relaying a request via SendCard.
+type Placeholders struct {
+	CardNumber      string
+	ExpirationMonth string
+	ExpirationYear  string
+	CardholderName  string
+	ServiceCode     string
+}
+
+var vaulteraPlaceholders = Placeholders{
+	CardNumber:      "%CARD_NUMBER%",
+	ExpirationMonth: "%CARD_EXPIRATION_MONTH%",
+	ExpirationYear:  "%CARD_EXPIRATION_YEAR%",
+	CardholderName:  "%CARDHOLDER_NAME%",
+	ServiceCode:     "%SERVICE_CODE%",
+}
+
+var pciBookingPlaceholders = Placeholders{
+	CardNumber:      "{{{CardNumber}}}",
+	ExpirationMonth: "{{{ExpirationMM}}}",
+	ExpirationYear:  "{{{ExpirationYYYY}}}",
+	CardholderName:  "{{{CardholderName}}}",
+	ServiceCode:     "{{{CVV}}}",
+}
+
+// PlaceholdersFor returns the relay placeholders used by the named processor.
+// The sandbox uses the Vaultera syntax.
+func PlaceholdersFor(processorName string) (Placeholders, bool) {
+	switch processorName {
+	case "vaultera", "sandbox":
+		return vaulteraPlaceholders, true
+	case "pcibooking", "pci_booking_upg":
+		return pciBookingPlaceholders, true
+	}
+	return Placeholders{}, false
+}
+
+// Translate rewrites every placeholder of p in s to the matching placeholder of to.
+func (p Placeholders) Translate(s string, to Placeholders) string {
+	return strings.NewReplacer(
+		p.CardNumber, to.CardNumber,
+		p.ExpirationMonth, to.ExpirationMonth,
+		p.ExpirationYear, to.ExpirationYear,
+		p.CardholderName, to.CardholderName,
+		p.ServiceCode, to.ServiceCode,
+	).Replace(s)
+}
+
+// translateRequest rewrites req's placeholders from one processor's syntax to
+// another's. It returns req unchanged when either syntax is unknown.
+func translateRequest(req SendRequest, from, to string) SendRequest {
+	src, ok := PlaceholdersFor(from)
+	if !ok {
+		return req
+	}
+	dst, ok := PlaceholdersFor(to)
+	if !ok || src == dst {
+		return req
+	}
+
+	out := req
+	out.URL = src.Translate(req.URL, dst)
+	out.Body = src.Translate(req.Body, dst)
+	if req.Headers != nil {
+		out.Headers = make(map[string]string, len(req.Headers))
+		for k, v := range req.Headers {
+			out.Headers[k] = src.Translate(v, dst)
+		}
+	}
+	return out
+}
@@ -3,6 +3,8 @@ package processor
 import (
 	"context"
 	"encoding/json"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
 )
 
 type Card struct {
@@ -34,11 +36,15 @@ type SendResponse struct {
 	StatusCode int               `json:"status_code"`
 	Headers    map[string]string `json:"headers,omitempty"`
 	Body       json.RawMessage   `json:"body"`
+	// Processor is the name of the processor that served the request.
+	Processor string `json:"processor,omitempty"`
 }
 
 type SessionTokenResponse struct {
 	Token string `json:"token"`
 	Scope string `json:"scope"`
+	// Processor is the name of the processor that issued the token.
+	Processor string `json:"processor,omitempty"`
 }
 
 // GatewayInfo describes a payment gateway supported by the UPG provider.
@@ -49,11 +55,21 @@ type GatewayInfo struct {
 
 // UPGChargeRequest holds the parameters for a UPG charge operation.
 type UPGChargeRequest struct {
-	CardToken     string  `json:"card_token"`
-	Amount        float64 `json:"amount"`
-	Currency      string  `json:"currency"`
-	GatewayName   string  `json:"gateway_name"`
-	CredentialsID string  `json:"credentials_id"`
+	CardToken     string      `json:"card_token"`
+	Amount        money.Money `json:"amount"`
+	GatewayName   string      `json:"gateway_name"`
+	CredentialsID string      `json:"credentials_id"`
+}
+
+// UPGTransactionRequest holds the parameters for a UPG operation on an earlier
+// transaction: capturing or voiding a pre-authorization, or a refund.
+type UPGTransactionRequest struct {
+	// TransactionID is the gateway transaction ID of the earlier transaction.
+	TransactionID string `json:"transaction_id"`
+	// Amount is the amount to capture or refund; it is ignored by void.
+	Amount        money.Money `json:"amount"`
+	GatewayName   string      `json:"gateway_name"`
+	CredentialsID string      `json:"credentials_id"`
 }
 
 // UPGChargeResponse holds the result of a UPG charge operation.
@@ -65,19 +81,62 @@ type UPGChargeResponse struct {
 	Raw           json.RawMessage `json:"raw,omitempty"`
 }
 
+// Processor is a card vault. Every processor tokenizes cards and issues
+// session tokens for its hosted capture form. Relay, UPG and refunds are
+// optional: a processor offers them by implementing Relayer, UPG,
+// UPGAuthorizer or Refunder and declaring the matching capability. Use As to
+// get one.
 type Processor interface {
 	CreateCard(ctx context.Context, card Card) (*CardResponse, error)
 	GetCard(ctx context.Context, cardToken string) (*CardResponse, error)
 	DeleteCard(ctx context.Context, cardToken string) error
-	SendCard(ctx context.Context, cardToken string, req SendRequest) (*SendResponse, error)
 	CreateSessionToken(ctx context.Context, scope string) (*SessionTokenResponse, error)
 	CaptureFormURL(sessionToken string) string
 	Name() string
 
-	// UPG (Universal Payment Gateway) methods.
-	// These are only fully implemented by the pci_booking provider.
-	// Other providers return an error indicating UPG is unsupported.
+	// Capabilities declares the operations this processor supports. Callers
+	// check it before invoking an operation so unsupported requests can be
+	// rejected without a round trip to the provider.
+	Capabilities() Capabilities
+}
+
+// Relayer forwards a request to a third party with the card's details
+// substituted for placeholders in it (CapabilityRelay).
+type Relayer interface {
+	Processor
+	SendCard(ctx context.Context, cardToken string, req SendRequest) (*SendResponse, error)
+}
+
+// UPG charges cards through the provider's Universal Payment Gateway
+// (CapabilityUPGGateways, CapabilityUPGCharge and CapabilityUPGCredentials).
+type UPG interface {
+	Processor
 	GetPaymentGateways(ctx context.Context) ([]GatewayInfo, error)
 	GetCredentialsStructure(ctx context.Context, gatewayName string) (map[string]any, error)
 	ChargeUPG(ctx context.Context, req UPGChargeRequest) (*UPGChargeResponse, error)
+
+	// CreateUPGCredentials registers a gateway's credentials with the
+	// provider and returns the credentials ID that UPG operations take.
+	// DeleteUPGCredentials removes them.
+	CreateUPGCredentials(ctx context.Context, gatewayName string, fields map[string]string) (string, error)
+	DeleteUPGCredentials(ctx context.Context, credentialsID string) error
+}
+
+// UPGAuthorizer places holds through the UPG (CapabilityUPGAuthorize).
+type UPGAuthorizer interface {
+	Processor
+
+	// PreAuthorizeUPG places a hold for the amount without capturing it.
+	// CaptureUPG captures all or part of a pre-authorization and VoidUPG
+	// releases it.
+	PreAuthorizeUPG(ctx context.Context, req UPGChargeRequest) (*UPGChargeResponse, error)
+	CaptureUPG(ctx context.Context, req UPGTransactionRequest) (*UPGChargeResponse, error)
+	VoidUPG(ctx context.Context, req UPGTransactionRequest) (*UPGChargeResponse, error)
+}
+
+// Refunder refunds all or part of an earlier UPG charge or capture
+// (CapabilityRefund).
+type Refunder interface {
+	Processor
+	RefundUPG(ctx context.Context, req UPGTransactionRequest) (*UPGChargeResponse, error)
 }
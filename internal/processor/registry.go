@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownProcessor is returned when a property is routed to a processor
+// name that has not been registered with the Registry.
+var ErrUnknownProcessor = errors.New("processor: unknown processor")
+
+// Resolver picks the Processor that should serve a request for a property.
+// An empty propertyID resolves to the service default.
+type Resolver interface {
+	Resolve(ctx context.Context, propertyID string) (Processor, error)
+}
+
+// RouteStore looks up the processor name assigned to a property. It returns
+// an empty name and a nil error when the property has no explicit route.
+type RouteStore interface {
+	ProcessorFor(ctx context.Context, propertyID string) (string, error)
+}
+
+type staticResolver struct {
+	p Processor
+}
+
+// Static returns a Resolver that always resolves to p.
+func Static(p Processor) Resolver {
+	return staticResolver{p: p}
+}
+
+func (s staticResolver) Resolve(_ context.Context, _ string) (Processor, error) {
+	return s.p, nil
+}
+
+// Registry resolves processors per property using a RouteStore, falling back
+// to a default processor when the property has no route or no store is set.
+type Registry struct {
+	mu          sync.RWMutex
+	processors  map[string]Processor
+	defaultName string
+	routes      RouteStore
+}
+
+var _ Resolver = (*Registry)(nil)
+
+// NewRegistry creates a Registry whose default processor is registered under
+// defaultName. routes may be nil, in which case every request resolves to the
+// default processor.
+func NewRegistry(defaultName string, def Processor, routes RouteStore) *Registry {
+	return &Registry{
+		processors:  map[string]Processor{defaultName: def},
+		defaultName: defaultName,
+		routes:      routes,
+	}
+}
+
+// Register makes p available to properties routed to name.
+func (r *Registry) Register(name string, p Processor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.processors[name] = p
+}
+
+// Names returns the registered processor names in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.processors))
+	for name := range r.processors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Default returns the default processor.
+func (r *Registry) Default() Processor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.processors[r.defaultName]
+}
+
+// Resolve returns the processor routed to propertyID, or the default
+// processor when the property has no route.
+func (r *Registry) Resolve(ctx context.Context, propertyID string) (Processor, error) {
+	name := r.defaultName
+	if propertyID != "" && r.routes != nil {
+		routed, err := r.routes.ProcessorFor(ctx, propertyID)
+		if err != nil {
+			return nil, fmt.Errorf("processor: lookup route for property %s: %w", propertyID, err)
+		}
+		if routed != "" {
+			name = routed
+		}
+	}
+
+	r.mu.RLock()
+	p, ok := r.processors[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProcessor, name)
+	}
+	return p, nil
+}
@@ -0,0 +1,85 @@
+package processor_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+)
+
+// namedProcessor is a minimal Processor whose only meaningful method is Name.
+type namedProcessor struct {
+	processor.Processor
+	name string
+}
+
+func (n namedProcessor) Name() string { return n.name }
+
+type mapRoutes struct {
+	routes map[string]string
+	err    error
+}
+
+func (m mapRoutes) ProcessorFor(_ context.Context, propertyID string) (string, error) {
+	return m.routes[propertyID], m.err
+}
+
+func TestRegistry_Resolve(t *testing.T) {
+	routes := mapRoutes{routes: map[string]string{
+		"10": "pcibooking",
+		"20": "missing",
+	}}
+	reg := processor.NewRegistry("vaultera", namedProcessor{name: "vaultera"}, routes)
+	reg.Register("pcibooking", namedProcessor{name: "pcibooking"})
+
+	tests := []struct {
+		name       string
+		propertyID string
+		want       string
+		wantErr    error
+	}{
+		{"no property uses default", "", "vaultera", nil},
+		{"unrouted property uses default", "99", "vaultera", nil},
+		{"routed property", "10", "pcibooking", nil},
+		{"routed to unregistered processor", "20", "", processor.ErrUnknownProcessor},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := reg.Resolve(context.Background(), tc.propertyID)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if p.Name() != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, p.Name())
+			}
+		})
+	}
+}
+
+func TestRegistry_Resolve_StoreError(t *testing.T) {
+	reg := processor.NewRegistry("vaultera", namedProcessor{name: "vaultera"}, mapRoutes{err: errors.New("db down")})
+
+	if _, err := reg.Resolve(context.Background(), "10"); err == nil {
+		t.Fatal("expected error when route store fails")
+	}
+}
+
+func TestRegistry_NilStore(t *testing.T) {
+	reg := processor.NewRegistry("vaultera", namedProcessor{name: "vaultera"}, nil)
+
+	p, err := reg.Resolve(context.Background(), "10")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if p.Name() != "vaultera" {
+		t.Errorf("expected vaultera, got %s", p.Name())
+	}
+}
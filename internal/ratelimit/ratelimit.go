@@ -0,0 +1,138 @@
+// Package ratelimit counts requests in a sliding window so that callers can be
+// limited to a number of requests per window for a key.
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Limit allows at most Limit requests per Window counted under Key.
+type Limit struct {
+	Key    string
+	Limit  int
+	Window time.Duration
+}
+
+// Result is the outcome of checking a request against one limit.
+type Result struct {
+	// Allowed reports whether the limit had room for the request. The request
+	// was counted only if every limit it was checked against allowed it.
+	Allowed bool
+	Limit   int
+	// Remaining is how many more requests the window accepts.
+	Remaining int
+	// Reset is how long until the oldest counted request leaves the window,
+	// freeing a slot.
+	Reset time.Duration
+}
+
+// Store counts requests per key.
+type Store interface {
+	// Allow checks a request against every limit and, only if each of them
+	// has fewer than its limit of requests in its window, counts it under all
+	// of their keys. The check and the count are atomic. It returns a result
+	// per limit, in order. A request refused by one limit is counted under
+	// none, so that it does not use up the others.
+	Allow(ctx context.Context, limits ...Limit) ([]Result, error)
+}
+
+// FallbackStore uses a primary store (Redis) and falls back to a secondary
+// store (memory) for any call the primary fails.
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+}
+
+var _ Store = (*FallbackStore)(nil)
+
+// NewFallbackStore creates a FallbackStore. fallback may be nil.
+func NewFallbackStore(primary, fallback Store) *FallbackStore {
+	return &FallbackStore{primary: primary, fallback: fallback}
+}
+
+func (f *FallbackStore) Allow(ctx context.Context, limits ...Limit) ([]Result, error) {
+	res, err := f.primary.Allow(ctx, limits...)
+	if err != nil && f.fallback != nil {
+		log.Printf("ratelimit: primary store failed, using fallback: %v", err)
+		return f.fallback.Allow(ctx, limits...)
+	}
+	return res, err
+}
+
+// MemoryStore is a Store held in process memory, for tests, single-instance
+// development and as a fallback while Redis is down. Counts are not shared
+// between instances.
+type MemoryStore struct {
+	mu       sync.Mutex
+	requests map[string][]time.Time
+	now      func() time.Time
+	swept    time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{requests: map[string][]time.Time{}, now: time.Now}
+}
+
+func (m *MemoryStore) Allow(_ context.Context, limits ...Limit) ([]Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var longest time.Duration
+	for _, l := range limits {
+		longest = max(longest, l.Window)
+	}
+	m.sweep(now, longest)
+
+	windows := make([][]time.Time, len(limits))
+	allowed := true
+	for i, l := range limits {
+		windows[i] = inWindow(m.requests[l.Key], now, l.Window)
+		allowed = allowed && len(windows[i]) < l.Limit
+	}
+
+	results := make([]Result, len(limits))
+	for i, l := range limits {
+		times := windows[i]
+		res := Result{Limit: l.Limit, Allowed: len(times) < l.Limit}
+		if allowed {
+			times = append(times, now)
+		}
+		m.requests[l.Key] = times
+		res.Remaining = max(l.Limit-len(times), 0)
+		if len(times) > 0 {
+			res.Reset = times[0].Add(l.Window).Sub(now)
+		}
+		results[i] = res
+	}
+	return results, nil
+}
+
+// sweep drops keys without requests in the last window, at most once per
+// window, so that keys seen once do not accumulate.
+func (m *MemoryStore) sweep(now time.Time, window time.Duration) {
+	if now.Sub(m.swept) < window {
+		return
+	}
+	m.swept = now
+	for key, times := range m.requests {
+		if len(inWindow(times, now, window)) == 0 {
+			delete(m.requests, key)
+		}
+	}
+}
+
+// inWindow returns the suffix of the ascending times that is within window of
+// now.
+func inWindow(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	i := 0
+	for i < len(times) && !times[i].After(now.Add(-window)) {
+		i++
+	}
+	return times[i:]
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces rate limit counters in Redis.
+const redisKeyPrefix = "ratelimit:"
+
+// slidingWindow keeps the timestamps (in milliseconds) of the requests counted
+// for each key in a sorted set. It drops those older than the key's window,
+// adds the request to every set if each is below its limit, and returns
+// whether it did, then per key the number of requests in the window and the
+// oldest one's timestamp. ARGV holds the time and the request's member,
+// followed by each key's window and limit.
+var slidingWindow = goredis.NewScript(`
+local now = tonumber(ARGV[1])
+local counts = {}
+local allowed = 1
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[1 + 2 * i])
+	local limit = tonumber(ARGV[2 + 2 * i])
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+	counts[i] = redis.call('ZCARD', key)
+	if counts[i] >= limit then
+		allowed = 0
+	end
+end
+local reply = {allowed}
+for i, key in ipairs(KEYS) do
+	if allowed == 1 then
+		redis.call('ZADD', key, now, ARGV[2])
+		redis.call('PEXPIRE', key, tonumber(ARGV[1 + 2 * i]))
+		counts[i] = counts[i] + 1
+	end
+	local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+	table.insert(reply, counts[i])
+	if oldest[2] == nil then
+		table.insert(reply, now)
+	else
+		table.insert(reply, tonumber(oldest[2]))
+	end
+end
+return reply
+`)
+
+// RedisStore is a Store backed by Redis, shared by every instance.
+type RedisStore struct {
+	rdb *goredis.Client
+}
+
+var _ Store = (*RedisStore)(nil)
+
+// NewRedisStore creates a RedisStore.
+func NewRedisStore(rdb *goredis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+func (r *RedisStore) Allow(ctx context.Context, limits ...Limit) ([]Result, error) {
+	if len(limits) == 0 {
+		return nil, nil
+	}
+	now := time.Now().UnixMilli()
+	keys := make([]string, len(limits))
+	args := []any{now, fmt.Sprintf("%d-%s", now, uuid.NewString())}
+	for i, l := range limits {
+		keys[i] = redisKeyPrefix + l.Key
+		args = append(args, l.Window.Milliseconds(), l.Limit)
+	}
+	vals, err := slidingWindow.Run(ctx, r.rdb, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit: count request: %w", err)
+	}
+	if len(vals) != 1+2*len(limits) {
+		return nil, fmt.Errorf("ratelimit: count request: unexpected reply %v", vals)
+	}
+
+	results := make([]Result, len(limits))
+	for i, l := range limits {
+		count, oldest := vals[1+2*i], vals[2+2*i]
+		// The limit had room if the request was counted or, when it was
+		// refused, if the limit's count is below it.
+		hadRoom := vals[0] == 1 || int(count) < l.Limit
+		results[i] = Result{
+			Allowed:   hadRoom,
+			Limit:     l.Limit,
+			Remaining: max(l.Limit-int(count), 0),
+			Reset:     time.Duration(oldest+l.Window.Milliseconds()-now) * time.Millisecond,
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,95 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/ratelimit"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// allow counts a request against a single limit.
+func allow(t *testing.T, s ratelimit.Store, key string, limit int, window time.Duration) ratelimit.Result {
+	t.Helper()
+	res, err := s.Allow(context.Background(), ratelimit.Limit{Key: key, Limit: limit, Window: window})
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("expected one result, got %+v", res)
+	}
+	return res[0]
+}
+
+// testStore exercises the Store contract against s.
+func testStore(t *testing.T, s ratelimit.Store) {
+	t.Helper()
+	key := "test:" + time.Now().Format(time.RFC3339Nano)
+	window := 200 * time.Millisecond
+
+	for i := 2; i >= 0; i-- {
+		res := allow(t, s, key, 3, window)
+		if !res.Allowed || res.Limit != 3 || res.Remaining != i {
+			t.Fatalf("expected request allowed with %d remaining, got %+v", i, res)
+		}
+	}
+	res := allow(t, s, key, 3, window)
+	if res.Allowed || res.Remaining != 0 {
+		t.Errorf("expected the fourth request refused, got %+v", res)
+	}
+	if res.Reset <= 0 || res.Reset > window {
+		t.Errorf("expected a reset within the window, got %s", res.Reset)
+	}
+	if other := allow(t, s, key+":other", 3, window); !other.Allowed {
+		t.Error("expected keys to be limited separately")
+	}
+
+	time.Sleep(window + 50*time.Millisecond)
+	if res := allow(t, s, key, 3, window); !res.Allowed || res.Remaining != 2 {
+		t.Errorf("expected the window to have slid past, got %+v", res)
+	}
+
+	// A request refused by one limit is not counted against the others.
+	narrow := ratelimit.Limit{Key: key + ":narrow", Limit: 1, Window: window}
+	wide := ratelimit.Limit{Key: key + ":wide", Limit: 3, Window: window}
+	for i, wantAllowed := range []bool{true, false, false} {
+		results, err := s.Allow(context.Background(), narrow, wide)
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		if len(results) != 2 || results[0].Allowed != wantAllowed || !results[1].Allowed {
+			t.Fatalf("request %d: unexpected results %+v", i, results)
+		}
+		if results[1].Remaining != 2 {
+			t.Errorf("request %d: expected the wide limit counted once, got %+v", i, results[1])
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, ratelimit.NewMemoryStore())
+}
+
+type failingStore struct{}
+
+func (failingStore) Allow(context.Context, ...ratelimit.Limit) ([]ratelimit.Result, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestFallbackStore(t *testing.T) {
+	testStore(t, ratelimit.NewFallbackStore(failingStore{}, ratelimit.NewMemoryStore()))
+}
+
+func TestRedisStore(t *testing.T) {
+	rdb := goredis.NewClient(&goredis.Options{Addr: "localhost:6379"})
+	defer rdb.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+
+	testStore(t, ratelimit.NewRedisStore(rdb))
+}
@@ -0,0 +1,113 @@
+// Package redact removes card data and secrets from gateway and processor
+// payloads before they are returned to API clients or logged.
+package redact
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Mask replaces redacted values.
+const Mask = "[REDACTED]"
+
+// sensitiveKeys are object keys, compared case-insensitively with separators
+// removed, whose values are always redacted.
+var sensitiveKeys = map[string]bool{
+	"number":         true,
+	"cardnumber":     true,
+	"pan":            true,
+	"cvv":            true,
+	"cvc":            true,
+	"cv2":            true,
+	"securitycode":   true,
+	"servicecode":    true,
+	"password":       true,
+	"secret":         true,
+	"secretkey":      true,
+	"apikey":         true,
+	"clientsecret":   true,
+	"authorization":  true,
+	"accesstoken":    true,
+	"trackdata":      true,
+	"magstripe":      true,
+	"cardholderdata": true,
+}
+
+// panPattern matches runs of 13-19 digits, optionally separated by single
+// spaces or dashes, that may be card numbers.
+var panPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+// JSON returns a copy of b with the values of sensitive keys replaced by Mask
+// and card numbers in any string masked to their last four digits. A body that
+// is not valid JSON is returned as a JSON string with card numbers masked.
+func JSON(b json.RawMessage) json.RawMessage {
+	if len(b) == 0 {
+		return b
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		out, _ := json.Marshal(String(string(b)))
+		return out
+	}
+	out, err := json.Marshal(value(v))
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// String masks every Luhn-valid card number in s to its last four digits.
+func String(s string) string {
+	return panPattern.ReplaceAllStringFunc(s, func(m string) string {
+		digits := strings.NewReplacer(" ", "", "-", "").Replace(m)
+		if !luhnValid(digits) {
+			return m
+		}
+		return strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+	})
+}
+
+func value(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if sensitiveKey(k) {
+				t[k] = Mask
+				continue
+			}
+			t[k] = value(child)
+		}
+		return t
+	case []any:
+		for i, child := range t {
+			t[i] = value(child)
+		}
+		return t
+	case string:
+		return String(t)
+	}
+	return v
+}
+
+func sensitiveKey(k string) bool {
+	k = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(k))
+	return sensitiveKeys[k]
+}
+
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,59 @@
+package redact_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/redact"
+)
+
+func TestJSON_RedactsSensitiveKeys(t *testing.T) {
+	in := `{"id":"ch_1","card":{"number":"4242424242424242","CVC":"123","exp_month":12},"api-key":"sk_live","items":[{"client_secret":"pi_secret"}]}`
+
+	var got map[string]any
+	if err := json.Unmarshal(redact.JSON(json.RawMessage(in)), &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	card := got["card"].(map[string]any)
+	if card["number"] != redact.Mask || card["CVC"] != redact.Mask {
+		t.Errorf("expected card fields redacted, got %v", card)
+	}
+	if card["exp_month"] != float64(12) {
+		t.Errorf("expected exp_month kept, got %v", card["exp_month"])
+	}
+	if got["api-key"] != redact.Mask {
+		t.Errorf("expected api-key redacted, got %v", got["api-key"])
+	}
+	if got["items"].([]any)[0].(map[string]any)["client_secret"] != redact.Mask {
+		t.Errorf("expected nested client_secret redacted, got %v", got["items"])
+	}
+	if got["id"] != "ch_1" {
+		t.Errorf("expected id kept, got %v", got["id"])
+	}
+}
+
+func TestJSON_MasksCardNumbersInStrings(t *testing.T) {
+	in := `{"message":"card 4242 4242 4242 4242 declined","reference":"1234567890123456"}`
+
+	out := string(redact.JSON(json.RawMessage(in)))
+	if strings.Contains(out, "4242 4242") || !strings.Contains(out, "************4242") {
+		t.Errorf("expected card number masked, got %s", out)
+	}
+	// Not Luhn-valid, so not a card number.
+	if !strings.Contains(out, "1234567890123456") {
+		t.Errorf("expected non-card digits kept, got %s", out)
+	}
+}
+
+func TestJSON_NonJSONBody(t *testing.T) {
+	out := redact.JSON(json.RawMessage(`<CardNumber>4976000000003436</CardNumber>`))
+
+	var s string
+	if err := json.Unmarshal(out, &s); err != nil {
+		t.Fatalf("expected a JSON string, got %s", out)
+	}
+	if s != "<CardNumber>************3436</CardNumber>" {
+		t.Errorf("unexpected redaction %q", s)
+	}
+}
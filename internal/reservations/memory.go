@@ -0,0 +1,123 @@
+package reservations
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+)
+
+// MemoryStore is a Store held in process memory, for tests and development
+// without a database.
+type MemoryStore struct {
+	mu     sync.Mutex
+	nextID int
+	res    map[string]types.Reservation
+	// holds are the kept holds, keyed by transaction ID.
+	holds map[string]keptHold
+}
+
+type keptHold struct {
+	key    string
+	amount int64
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{res: map[string]types.Reservation{}, holds: map[string]keptHold{}}
+}
+
+func key(propertyID int64, number string) string {
+	return strconv.FormatInt(propertyID, 10) + "/" + number
+}
+
+func (m *MemoryStore) Create(_ context.Context, r *types.Reservation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := key(int64(r.PropertyID), r.ReservationNumber)
+	if _, ok := m.res[k]; ok {
+		return ErrExists
+	}
+	m.nextID++
+	now := time.Now()
+	r.ID, r.CreatedAt, r.UpdatedAt = m.nextID, now, now
+	r.AmountPaid, r.AmountHeld = 0, 0
+	r.PaymentStatus = PaymentStatus(r.TotalAmount, 0)
+	m.res[k] = *r
+	return nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, propertyID int64, number string) (*types.Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r, ok := m.res[key(propertyID, number)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &r, nil
+}
+
+func (m *MemoryStore) Hold(_ context.Context, propertyID int64, number string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := key(propertyID, number)
+	r, ok := m.res[k]
+	if !ok {
+		return ErrNotFound
+	}
+	if amount > r.Outstanding() {
+		return ErrAmountExceeded
+	}
+	r.AmountHeld += amount
+	r.UpdatedAt = time.Now()
+	m.res[k] = r
+	return nil
+}
+
+func (m *MemoryStore) Settle(_ context.Context, propertyID int64, number string, amount int64, paid bool) (*types.Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.settle(key(propertyID, number), amount, paid)
+}
+
+func (m *MemoryStore) Keep(_ context.Context, propertyID int64, number, transactionID string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := key(propertyID, number)
+	if _, ok := m.res[k]; !ok {
+		return ErrNotFound
+	}
+	m.holds[transactionID] = keptHold{key: k, amount: amount}
+	return nil
+}
+
+func (m *MemoryStore) Reconcile(_ context.Context, propertyID int64, number, transactionID string, paid bool) (*types.Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.holds[transactionID]
+	if !ok || h.key != key(propertyID, number) {
+		return nil, ErrHoldNotFound
+	}
+	delete(m.holds, transactionID)
+	return m.settle(h.key, h.amount, paid)
+}
+
+// settle releases a hold of amount on the reservation k. m.mu must be held.
+func (m *MemoryStore) settle(k string, amount int64, paid bool) (*types.Reservation, error) {
+	r, ok := m.res[k]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	r.AmountHeld = max(r.AmountHeld-amount, 0)
+	if paid {
+		r.AmountPaid += amount
+	}
+	r.PaymentStatus = PaymentStatus(r.TotalAmount, r.AmountPaid)
+	r.UpdatedAt = time.Now()
+	m.res[k] = r
+	return &r, nil
+}
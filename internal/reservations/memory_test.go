@@ -0,0 +1,64 @@
+package reservations_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/reservations"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+)
+
+func TestMemoryStore_HoldAndSettle(t *testing.T) {
+	ctx := context.Background()
+	s := reservations.NewMemoryStore()
+	if err := s.Create(ctx, &types.Reservation{ReservationNumber: "R-1", PropertyID: 7, TotalAmount: 1000, Currency: "EUR"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := s.Hold(ctx, 7, "R-1", 600); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if err := s.Hold(ctx, 7, "R-1", 500); !errors.Is(err, reservations.ErrAmountExceeded) {
+		t.Errorf("expected the held amount to count against the balance, got %v", err)
+	}
+	r, err := s.Settle(ctx, 7, "R-1", 600, true)
+	if err != nil || r.AmountPaid != 600 || r.AmountHeld != 0 || r.PaymentStatus != types.ReservationPaymentPartiallyPaid {
+		t.Fatalf("expected a partial payment, got %+v, %v", r, err)
+	}
+
+	if err := s.Hold(ctx, 7, "R-1", 400); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if r, _ = s.Settle(ctx, 7, "R-1", 400, false); r.AmountPaid != 600 || r.Outstanding() != 400 {
+		t.Errorf("expected an unpaid hold to be released, got %+v", r)
+	}
+	if r, _ = s.Settle(ctx, 7, "R-1", 400, true); r.PaymentStatus != types.ReservationPaymentPaid {
+		t.Errorf("expected the reservation paid, got %+v", r)
+	}
+
+	if err := s.Hold(ctx, 8, "R-1", 1); !errors.Is(err, reservations.ErrNotFound) {
+		t.Errorf("expected reservations to be scoped by property, got %v", err)
+	}
+}
+
+func TestMemoryStore_KeepAndReconcile(t *testing.T) {
+	ctx := context.Background()
+	s := reservations.NewMemoryStore()
+	s.Create(ctx, &types.Reservation{ReservationNumber: "R-1", PropertyID: 7, TotalAmount: 1000, Currency: "EUR"})
+
+	s.Hold(ctx, 7, "R-1", 600)
+	if err := s.Keep(ctx, 7, "R-1", "txn-1", 600); err != nil {
+		t.Fatalf("Keep: %v", err)
+	}
+	if _, err := s.Reconcile(ctx, 8, "R-1", "txn-1", true); !errors.Is(err, reservations.ErrHoldNotFound) {
+		t.Errorf("expected holds to be scoped by reservation, got %v", err)
+	}
+	r, err := s.Reconcile(ctx, 7, "R-1", "txn-1", true)
+	if err != nil || r.AmountPaid != 600 || r.AmountHeld != 0 {
+		t.Fatalf("expected the kept hold paid, got %+v, %v", r, err)
+	}
+	if _, err := s.Reconcile(ctx, 7, "R-1", "txn-1", true); !errors.Is(err, reservations.ErrHoldNotFound) {
+		t.Errorf("expected a hold to be reconciled once, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package reservations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore is a Store backed by the reservations and reservation_holds
+// tables (see migrations/0011_reservations.sql and 0015_reservation_holds.sql).
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+// NewPostgresStore creates a PostgresStore backed by the given pool.
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+const reservationColumns = `id, reservation_number, property_id, guest_name, guest_email, check_in, check_out,
+	status, card_token, total_amount, currency, amount_paid, amount_held, payment_status, created_at, updated_at`
+
+func (p *PostgresStore) Create(ctx context.Context, r *types.Reservation) error {
+	r.AmountPaid, r.AmountHeld = 0, 0
+	r.PaymentStatus = PaymentStatus(r.TotalAmount, 0)
+	err := p.pool.QueryRow(ctx,
+		`INSERT INTO reservations (reservation_number, property_id, guest_name, guest_email, check_in, check_out,
+		     status, card_token, total_amount, currency, payment_status)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 ON CONFLICT (property_id, reservation_number) DO NOTHING
+		 RETURNING id, created_at, updated_at`,
+		r.ReservationNumber, r.PropertyID, r.GuestName, r.GuestEmail, r.CheckIn, r.CheckOut,
+		r.Status, r.CardToken, r.TotalAmount, r.Currency, r.PaymentStatus,
+	).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrExists
+	}
+	if err != nil {
+		return fmt.Errorf("reservations: insert reservation: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) Get(ctx context.Context, propertyID int64, number string) (*types.Reservation, error) {
+	row := p.pool.QueryRow(ctx,
+		`SELECT `+reservationColumns+` FROM reservations WHERE property_id = $1 AND reservation_number = $2`,
+		propertyID, number)
+	r, err := scanReservation(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reservations: get reservation: %w", err)
+	}
+	return r, nil
+}
+
+func (p *PostgresStore) Hold(ctx context.Context, propertyID int64, number string, amount int64) error {
+	tag, err := p.pool.Exec(ctx,
+		`UPDATE reservations SET amount_held = amount_held + $3, updated_at = now()
+		 WHERE property_id = $1 AND reservation_number = $2 AND total_amount - amount_paid - amount_held >= $3`,
+		propertyID, number, amount)
+	if err != nil {
+		return fmt.Errorf("reservations: hold amount: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := p.Get(ctx, propertyID, number); err != nil {
+			return err
+		}
+		return ErrAmountExceeded
+	}
+	return nil
+}
+
+func (p *PostgresStore) Settle(ctx context.Context, propertyID int64, number string, amount int64, paid bool) (*types.Reservation, error) {
+	var paidAmount int64
+	if paid {
+		paidAmount = amount
+	}
+	row := p.pool.QueryRow(ctx,
+		`UPDATE reservations SET
+		     amount_held    = GREATEST(amount_held - $3, 0),
+		     amount_paid    = amount_paid + $4,
+		     payment_status = CASE
+		         WHEN amount_paid + $4 >= total_amount THEN 'paid'
+		         WHEN amount_paid + $4 > 0 THEN 'partially_paid'
+		         ELSE 'unpaid'
+		     END,
+		     updated_at     = now()
+		 WHERE property_id = $1 AND reservation_number = $2
+		 RETURNING `+reservationColumns,
+		propertyID, number, amount, paidAmount)
+	r, err := scanReservation(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reservations: settle amount: %w", err)
+	}
+	return r, nil
+}
+
+func (p *PostgresStore) Keep(ctx context.Context, propertyID int64, number, transactionID string, amount int64) error {
+	_, err := p.pool.Exec(ctx,
+		`INSERT INTO reservation_holds (transaction_id, property_id, reservation_number, amount)
+		 VALUES ($1, $2, $3, $4)`,
+		transactionID, propertyID, number, amount)
+	if err != nil {
+		return fmt.Errorf("reservations: keep hold: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) Reconcile(ctx context.Context, propertyID int64, number, transactionID string, paid bool) (*types.Reservation, error) {
+	// Deleting the kept hold and settling it in one statement means a hold
+	// is only ever settled once.
+	row := p.pool.QueryRow(ctx,
+		`WITH hold AS (
+		     DELETE FROM reservation_holds
+		     WHERE transaction_id = $3 AND property_id = $1 AND reservation_number = $2
+		     RETURNING CASE WHEN $4::boolean THEN amount ELSE 0 END AS paid, amount
+		 )
+		 UPDATE reservations SET
+		     amount_held    = GREATEST(amount_held - hold.amount, 0),
+		     amount_paid    = amount_paid + hold.paid,
+		     payment_status = CASE
+		         WHEN amount_paid + hold.paid >= total_amount THEN 'paid'
+		         WHEN amount_paid + hold.paid > 0 THEN 'partially_paid'
+		         ELSE 'unpaid'
+		     END,
+		     updated_at     = now()
+		 FROM hold
+		 WHERE property_id = $1 AND reservation_number = $2
+		 RETURNING `+reservationColumns,
+		propertyID, number, transactionID, paid)
+	r, err := scanReservation(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reservations: reconcile hold: %w", err)
+	}
+	return r, nil
+}
+
+func scanReservation(row pgx.Row) (*types.Reservation, error) {
+	var r types.Reservation
+	err := row.Scan(&r.ID, &r.ReservationNumber, &r.PropertyID, &r.GuestName, &r.GuestEmail, &r.CheckIn, &r.CheckOut,
+		&r.Status, &r.CardToken, &r.TotalAmount, &r.Currency, &r.AmountPaid, &r.AmountHeld, &r.PaymentStatus,
+		&r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,60 @@
+// Package reservations stores hotel reservations in the primary database and
+// tracks how much of each has been paid, so that a charge can be made by
+// reservation number against the reservation's stored card.
+package reservations
+
+import (
+	"context"
+	"errors"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+)
+
+var (
+	// ErrNotFound is returned when a reservation does not exist.
+	ErrNotFound = errors.New("reservations: reservation not found")
+	// ErrExists is returned when a property already has a reservation with
+	// the same number.
+	ErrExists = errors.New("reservations: reservation number already exists")
+	// ErrAmountExceeded is returned when a charge would take the amount paid
+	// and held past the reservation's total.
+	ErrAmountExceeded = errors.New("reservations: amount exceeds the outstanding balance")
+	// ErrHoldNotFound is returned when no hold is kept for a transaction,
+	// such as one that has already been reconciled.
+	ErrHoldNotFound = errors.New("reservations: no hold kept for the transaction")
+)
+
+// Store persists reservations and their payment state. Reservations are
+// identified by property and reservation number.
+type Store interface {
+	// Create inserts r and sets its ID, payment status and timestamps.
+	Create(ctx context.Context, r *types.Reservation) error
+	Get(ctx context.Context, propertyID int64, number string) (*types.Reservation, error)
+	// Hold sets amount aside from the outstanding balance for a charge in
+	// progress, so that concurrent charges cannot exceed the total.
+	Hold(ctx context.Context, propertyID int64, number string, amount int64) error
+	// Settle releases a hold of amount once its charge has an outcome, adding
+	// it to the amount paid when paid is true, and returns the reservation.
+	Settle(ctx context.Context, propertyID int64, number string, amount int64, paid bool) (*types.Reservation, error)
+	// Keep records that a hold of amount belongs to the charge recorded in
+	// the ledger as transactionID, whose outcome is unknown, so that it can
+	// be reconciled later.
+	Keep(ctx context.Context, propertyID int64, number, transactionID string, amount int64) error
+	// Reconcile settles the hold kept for transactionID as Settle does, once
+	// the charge's outcome is known, and forgets it. It returns
+	// ErrHoldNotFound when no hold is kept for the transaction, so that a
+	// hold is settled only once.
+	Reconcile(ctx context.Context, propertyID int64, number, transactionID string, paid bool) (*types.Reservation, error)
+}
+
+// PaymentStatus returns the payment status of a reservation with the given
+// total and amount paid.
+func PaymentStatus(total, paid int64) types.ReservationPaymentStatus {
+	switch {
+	case paid >= total:
+		return types.ReservationPaymentPaid
+	case paid > 0:
+		return types.ReservationPaymentPartiallyPaid
+	}
+	return types.ReservationPaymentUnpaid
+}
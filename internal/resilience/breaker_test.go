@@ -0,0 +1,82 @@
+package resilience_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/resilience"
+)
+
+func TestBreaker_OpensAfterThreshold(t *testing.T) {
+	b := resilience.NewBreaker(3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if !b.Allow() {
+			t.Fatalf("call %d should be allowed", i)
+		}
+		b.Record(true)
+	}
+	if b.State() != resilience.StateClosed {
+		t.Fatalf("expected closed after 2 failures, got %s", b.State())
+	}
+
+	b.Allow()
+	b.Record(true)
+	if b.State() != resilience.StateOpen {
+		t.Fatalf("expected open after 3 failures, got %s", b.State())
+	}
+	if b.Allow() {
+		t.Error("open breaker should reject calls")
+	}
+}
+
+func TestBreaker_SuccessResetsFailures(t *testing.T) {
+	b := resilience.NewBreaker(2, time.Hour)
+
+	b.Allow()
+	b.Record(true)
+	b.Allow()
+	b.Record(false)
+	b.Allow()
+	b.Record(true)
+
+	if b.State() != resilience.StateClosed {
+		t.Errorf("expected closed, got %s", b.State())
+	}
+}
+
+func TestBreaker_HalfOpenTrial(t *testing.T) {
+	b := resilience.NewBreaker(1, 10*time.Millisecond)
+	b.Allow()
+	b.Record(true)
+
+	time.Sleep(20 * time.Millisecond)
+	if b.State() != resilience.StateHalfOpen {
+		t.Fatalf("expected half_open after cooldown, got %s", b.State())
+	}
+	if !b.Allow() {
+		t.Fatal("half-open breaker should allow a trial call")
+	}
+	if b.Allow() {
+		t.Fatal("half-open breaker should allow only one trial call")
+	}
+
+	b.Record(false)
+	if b.State() != resilience.StateClosed {
+		t.Errorf("expected closed after successful trial, got %s", b.State())
+	}
+}
+
+func TestBreaker_HalfOpenFailureReopens(t *testing.T) {
+	b := resilience.NewBreaker(1, 10*time.Millisecond)
+	b.Allow()
+	b.Record(true)
+
+	time.Sleep(20 * time.Millisecond)
+	b.Allow()
+	b.Record(true)
+
+	if b.State() != resilience.StateOpen {
+		t.Errorf("expected open after failed trial, got %s", b.State())
+	}
+}
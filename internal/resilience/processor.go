@@ -0,0 +1,297 @@
+// Package resilience provides a processor.Processor decorator that adds
+// per-operation timeouts, bounded retries for idempotent calls and a circuit
+// breaker per provider.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/config"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+)
+
+// Processor wraps a processor.Processor with timeouts, retries and a circuit
+// breaker.
+//
+// Only GetCard, GetPaymentGateways and GetCredentialsStructure are retried.
+// Calls that create or move money (SendCard, ChargeUPG, CaptureUPG, ...) are
+// attempted exactly once, since a timed-out attempt may still have succeeded
+// upstream.
+//
+// Processor implements every optional processor interface; each returns an
+// ErrUnsupported error when the wrapped processor does not offer the
+// operation.
+type Processor struct {
+	next    processor.Processor
+	cfg     config.ResilienceConfig
+	breaker *Breaker
+}
+
+var (
+	_ processor.Relayer       = (*Processor)(nil)
+	_ processor.UPG           = (*Processor)(nil)
+	_ processor.UPGAuthorizer = (*Processor)(nil)
+	_ processor.Refunder      = (*Processor)(nil)
+)
+
+// Wrap decorates p according to cfg.
+func Wrap(p processor.Processor, cfg config.ResilienceConfig) *Processor {
+	return &Processor{
+		next:    p,
+		cfg:     cfg,
+		breaker: NewBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// CircuitState returns the state of the provider's circuit breaker.
+func (r *Processor) CircuitState() State {
+	return r.breaker.State()
+}
+
+func (r *Processor) Name() string {
+	return r.next.Name()
+}
+
+func (r *Processor) Capabilities() processor.Capabilities {
+	return r.next.Capabilities()
+}
+
+func (r *Processor) CaptureFormURL(sessionToken string) string {
+	return r.next.CaptureFormURL(sessionToken)
+}
+
+func (r *Processor) CreateCard(ctx context.Context, card processor.Card) (*processor.CardResponse, error) {
+	var resp *processor.CardResponse
+	err := r.once(ctx, r.cfg.Timeout, func(ctx context.Context) (err error) {
+		resp, err = r.next.CreateCard(ctx, card)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Processor) GetCard(ctx context.Context, cardToken string) (*processor.CardResponse, error) {
+	var resp *processor.CardResponse
+	err := r.retry(ctx, func(ctx context.Context) (err error) {
+		resp, err = r.next.GetCard(ctx, cardToken)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Processor) DeleteCard(ctx context.Context, cardToken string) error {
+	return r.once(ctx, r.cfg.Timeout, func(ctx context.Context) error {
+		return r.next.DeleteCard(ctx, cardToken)
+	})
+}
+
+func (r *Processor) SendCard(ctx context.Context, cardToken string, req processor.SendRequest) (*processor.SendResponse, error) {
+	next, err := processor.As[processor.Relayer](r.next, processor.CapabilityRelay)
+	if err != nil {
+		return nil, err
+	}
+	var resp *processor.SendResponse
+	err = r.once(ctx, r.cfg.ChargeTimeout, func(ctx context.Context) (err error) {
+		resp, err = next.SendCard(ctx, cardToken, req)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Processor) CreateSessionToken(ctx context.Context, scope string) (*processor.SessionTokenResponse, error) {
+	var resp *processor.SessionTokenResponse
+	err := r.once(ctx, r.cfg.Timeout, func(ctx context.Context) (err error) {
+		resp, err = r.next.CreateSessionToken(ctx, scope)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Processor) GetPaymentGateways(ctx context.Context) ([]processor.GatewayInfo, error) {
+	next, err := processor.As[processor.UPG](r.next, processor.CapabilityUPGGateways)
+	if err != nil {
+		return nil, err
+	}
+	var resp []processor.GatewayInfo
+	err = r.retry(ctx, func(ctx context.Context) (err error) {
+		resp, err = next.GetPaymentGateways(ctx)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Processor) GetCredentialsStructure(ctx context.Context, gatewayName string) (map[string]any, error) {
+	next, err := processor.As[processor.UPG](r.next, processor.CapabilityUPGGateways)
+	if err != nil {
+		return nil, err
+	}
+	var resp map[string]any
+	err = r.retry(ctx, func(ctx context.Context) (err error) {
+		resp, err = next.GetCredentialsStructure(ctx, gatewayName)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Processor) CreateUPGCredentials(ctx context.Context, gatewayName string, fields map[string]string) (string, error) {
+	next, err := processor.As[processor.UPG](r.next, processor.CapabilityUPGCredentials)
+	if err != nil {
+		return "", err
+	}
+	var id string
+	err = r.once(ctx, r.cfg.Timeout, func(ctx context.Context) (err error) {
+		id, err = next.CreateUPGCredentials(ctx, gatewayName, fields)
+		return err
+	})
+	return id, err
+}
+
+func (r *Processor) DeleteUPGCredentials(ctx context.Context, credentialsID string) error {
+	next, err := processor.As[processor.UPG](r.next, processor.CapabilityUPGCredentials)
+	if err != nil {
+		return err
+	}
+	return r.once(ctx, r.cfg.Timeout, func(ctx context.Context) error {
+		return next.DeleteUPGCredentials(ctx, credentialsID)
+	})
+}
+
+func (r *Processor) ChargeUPG(ctx context.Context, req processor.UPGChargeRequest) (*processor.UPGChargeResponse, error) {
+	next, err := processor.As[processor.UPG](r.next, processor.CapabilityUPGCharge)
+	if err != nil {
+		return nil, err
+	}
+	var resp *processor.UPGChargeResponse
+	err = r.once(ctx, r.cfg.ChargeTimeout, func(ctx context.Context) (err error) {
+		resp, err = next.ChargeUPG(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Processor) PreAuthorizeUPG(ctx context.Context, req processor.UPGChargeRequest) (*processor.UPGChargeResponse, error) {
+	next, err := processor.As[processor.UPGAuthorizer](r.next, processor.CapabilityUPGAuthorize)
+	if err != nil {
+		return nil, err
+	}
+	var resp *processor.UPGChargeResponse
+	err = r.once(ctx, r.cfg.ChargeTimeout, func(ctx context.Context) (err error) {
+		resp, err = next.PreAuthorizeUPG(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Processor) CaptureUPG(ctx context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	next, err := processor.As[processor.UPGAuthorizer](r.next, processor.CapabilityUPGAuthorize)
+	if err != nil {
+		return nil, err
+	}
+	var resp *processor.UPGChargeResponse
+	err = r.once(ctx, r.cfg.ChargeTimeout, func(ctx context.Context) (err error) {
+		resp, err = next.CaptureUPG(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Processor) VoidUPG(ctx context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	next, err := processor.As[processor.UPGAuthorizer](r.next, processor.CapabilityUPGAuthorize)
+	if err != nil {
+		return nil, err
+	}
+	var resp *processor.UPGChargeResponse
+	err = r.once(ctx, r.cfg.ChargeTimeout, func(ctx context.Context) (err error) {
+		resp, err = next.VoidUPG(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Processor) RefundUPG(ctx context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	next, err := processor.As[processor.Refunder](r.next, processor.CapabilityRefund)
+	if err != nil {
+		return nil, err
+	}
+	var resp *processor.UPGChargeResponse
+	err = r.once(ctx, r.cfg.ChargeTimeout, func(ctx context.Context) (err error) {
+		resp, err = next.RefundUPG(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// once makes a single attempt through the circuit breaker with a timeout.
+func (r *Processor) once(ctx context.Context, timeout time.Duration, call func(context.Context) error) error {
+	if !r.breaker.Allow() {
+		return processor.NewError(processor.ErrUpstreamUnavailable, r.next.Name(),
+			fmt.Errorf("%w (%w)", ErrCircuitOpen, processor.ErrNotSent))
+	}
+
+	callCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := call(callCtx)
+	// A caller that went away is not evidence of an unhealthy provider.
+	r.breaker.Record(isUpstreamFailure(err) && ctx.Err() == nil)
+	return err
+}
+
+// retry attempts an idempotent call up to RetryMax+1 times with exponential
+// backoff, retrying only transient upstream failures.
+func (r *Processor) retry(ctx context.Context, call func(context.Context) error) error {
+	delay := r.cfg.RetryBaseDelay
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = r.once(ctx, r.cfg.Timeout, call)
+		if err == nil || !isRetryable(err) || errors.Is(err, ErrCircuitOpen) || attempt >= r.cfg.RetryMax {
+			return err
+		}
+		if sleepErr := sleepCtx(ctx, delay); sleepErr != nil {
+			return err
+		}
+		delay *= 2
+		if r.cfg.RetryMaxDelay > 0 && delay > r.cfg.RetryMaxDelay {
+			delay = r.cfg.RetryMaxDelay
+		}
+	}
+}
+
+// isUpstreamFailure reports whether err counts against the circuit breaker.
+func isUpstreamFailure(err error) bool {
+	return errors.Is(err, processor.ErrUpstreamUnavailable)
+}
+
+// isRetryable reports whether an idempotent call that failed with err may be retried.
+func isRetryable(err error) bool {
+	return errors.Is(err, processor.ErrUpstreamUnavailable) || errors.Is(err, processor.ErrRateLimited)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Circuits reports the circuit breaker state of a set of wrapped processors.
+type Circuits []*Processor
+
+// CircuitStates returns the breaker state keyed by processor name.
+func (cs Circuits) CircuitStates() map[string]string {
+	states := make(map[string]string, len(cs))
+	for _, p := range cs {
+		states[p.Name()] = string(p.CircuitState())
+	}
+	return states
+}
@@ -0,0 +1,163 @@
+package resilience_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/config"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/resilience"
+)
+
+// flakyProcessor fails the first `failures` calls with err and counts calls.
+type flakyProcessor struct {
+	processor.UPG
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyProcessor) Name() string { return "flaky" }
+
+func (f *flakyProcessor) Capabilities() processor.Capabilities {
+	return processor.Capabilities{processor.CapabilityCardRead, processor.CapabilityRelay, processor.CapabilityUPGCharge}
+}
+
+func (f *flakyProcessor) fail() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyProcessor) GetCard(_ context.Context, token string) (*processor.CardResponse, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return &processor.CardResponse{CardToken: token}, nil
+}
+
+func (f *flakyProcessor) SendCard(_ context.Context, _ string, _ processor.SendRequest) (*processor.SendResponse, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return &processor.SendResponse{StatusCode: 200}, nil
+}
+
+func (f *flakyProcessor) ChargeUPG(ctx context.Context, _ processor.UPGChargeRequest) (*processor.UPGChargeResponse, error) {
+	f.calls++
+	<-ctx.Done()
+	return nil, processor.TransportError("flaky", ctx.Err())
+}
+
+func testConfig() config.ResilienceConfig {
+	return config.ResilienceConfig{
+		Timeout:          time.Second,
+		ChargeTimeout:    20 * time.Millisecond,
+		RetryMax:         2,
+		RetryBaseDelay:   time.Millisecond,
+		RetryMaxDelay:    5 * time.Millisecond,
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Hour,
+	}
+}
+
+var errUnavailable = processor.TransportError("flaky", errors.New("connection refused"))
+
+func TestProcessor_RetriesIdempotentCalls(t *testing.T) {
+	next := &flakyProcessor{failures: 2, err: errUnavailable}
+	p := resilience.Wrap(next, testConfig())
+
+	card, err := p.GetCard(context.Background(), "tok")
+	if err != nil {
+		t.Fatalf("GetCard: %v", err)
+	}
+	if card.CardToken != "tok" || next.calls != 3 {
+		t.Errorf("expected success on third attempt, got %d calls", next.calls)
+	}
+}
+
+func TestProcessor_RetriesAreBounded(t *testing.T) {
+	next := &flakyProcessor{failures: 10, err: errUnavailable}
+	cfg := testConfig()
+	cfg.BreakerThreshold = 0
+	p := resilience.Wrap(next, cfg)
+
+	if _, err := p.GetCard(context.Background(), "tok"); !errors.Is(err, processor.ErrUpstreamUnavailable) {
+		t.Fatalf("expected ErrUpstreamUnavailable, got %v", err)
+	}
+	if next.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", next.calls)
+	}
+}
+
+func TestProcessor_DoesNotRetryClientErrors(t *testing.T) {
+	next := &flakyProcessor{failures: 10, err: processor.StatusError("flaky", 404, nil)}
+	p := resilience.Wrap(next, testConfig())
+
+	if _, err := p.GetCard(context.Background(), "tok"); !errors.Is(err, processor.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if next.calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", next.calls)
+	}
+}
+
+func TestProcessor_NeverRetriesSendCard(t *testing.T) {
+	next := &flakyProcessor{failures: 1, err: errUnavailable}
+	p := resilience.Wrap(next, testConfig())
+
+	if _, err := p.SendCard(context.Background(), "tok", processor.SendRequest{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if next.calls != 1 {
+		t.Errorf("expected exactly 1 attempt, got %d", next.calls)
+	}
+}
+
+func TestProcessor_ChargeTimeout(t *testing.T) {
+	next := &flakyProcessor{}
+	p := resilience.Wrap(next, testConfig())
+
+	start := time.Now()
+	_, err := p.ChargeUPG(context.Background(), processor.UPGChargeRequest{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("charge timeout was not applied")
+	}
+	if next.calls != 1 {
+		t.Errorf("expected exactly 1 attempt, got %d", next.calls)
+	}
+}
+
+func TestProcessor_CircuitOpensAndFailsFast(t *testing.T) {
+	next := &flakyProcessor{failures: 100, err: errUnavailable}
+	cfg := testConfig()
+	cfg.RetryMax = 0
+	p := resilience.Wrap(next, cfg)
+
+	for i := 0; i < 3; i++ {
+		p.SendCard(context.Background(), "tok", processor.SendRequest{})
+	}
+	if p.CircuitState() != resilience.StateOpen {
+		t.Fatalf("expected open circuit, got %s", p.CircuitState())
+	}
+
+	_, err := p.SendCard(context.Background(), "tok", processor.SendRequest{})
+	if !errors.Is(err, resilience.ErrCircuitOpen) || !errors.Is(err, processor.ErrUpstreamUnavailable) {
+		t.Errorf("expected circuit open error, got %v", err)
+	}
+	if next.calls != 3 {
+		t.Errorf("expected no call while open, got %d calls", next.calls)
+	}
+
+	states := resilience.Circuits{p}.CircuitStates()
+	if states["flaky"] != "open" {
+		t.Errorf("expected flaky=open, got %v", states)
+	}
+}
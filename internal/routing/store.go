@@ -0,0 +1,49 @@
+// Package routing stores the per-property processor routing table in the
+// primary database.
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ processor.RouteStore = (*Store)(nil)
+
+// Store reads property → processor assignments from the property_processors
+// table (see migrations/0001_property_processors.sql).
+type Store struct {
+	pool *pgxpool.Pool
+}
+
+// NewStore creates a Store backed by the given pool.
+func NewStore(pool *pgxpool.Pool) *Store {
+	return &Store{pool: pool}
+}
+
+// ProcessorFor returns the processor name assigned to propertyID, or an empty
+// string when the property has no explicit route.
+func (s *Store) ProcessorFor(ctx context.Context, propertyID string) (string, error) {
+	id, err := strconv.ParseInt(propertyID, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("routing: invalid property id %q: %w", propertyID, err)
+	}
+
+	var name string
+	err = s.pool.QueryRow(ctx,
+		`SELECT processor_name FROM property_processors WHERE property_id = $1`,
+		id,
+	).Scan(&name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("routing: query property route: %w", err)
+	}
+	return name, nil
+}
@@ -0,0 +1,388 @@
+// Package sandbox provides an in-process processor.Processor for local
+// development and QA. It needs no provider credentials: cards are tokenized
+// into a Store and charge outcomes are selected by magic card numbers.
+package sandbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+)
+
+// Outcome is the simulated result of charging a sandbox card.
+type Outcome string
+
+const (
+	OutcomeApproved          Outcome = "approved"
+	OutcomeDeclined          Outcome = "declined"
+	OutcomeInsufficientFunds Outcome = "insufficient_funds"
+	OutcomeExpiredCard       Outcome = "expired_card"
+	OutcomeThreeDSRequired   Outcome = "three_ds_required"
+)
+
+// Magic card numbers. Any other Luhn-valid number is approved.
+const (
+	CardApproved          = "4242424242424242"
+	CardDeclined          = "4000000000000002"
+	CardInsufficientFunds = "4000000000009995"
+	CardExpired           = "4000000000000069"
+	CardThreeDSRequired   = "4000000000003220"
+)
+
+var magicCards = map[string]Outcome{
+	CardApproved:          OutcomeApproved,
+	CardDeclined:          OutcomeDeclined,
+	CardInsufficientFunds: OutcomeInsufficientFunds,
+	CardExpired:           OutcomeExpiredCard,
+	CardThreeDSRequired:   OutcomeThreeDSRequired,
+}
+
+// gateways are the simulated UPG gateways and their credential structures.
+var gateways = []struct {
+	name      string
+	structure map[string]any
+}{
+	{"SandboxGateway", map[string]any{
+		"api_key": map[string]any{"type": "string", "required": true},
+	}},
+	{"Stripe", map[string]any{
+		"secret_key": map[string]any{"type": "string", "required": true},
+	}},
+}
+
+var (
+	_ processor.Relayer       = (*Client)(nil)
+	_ processor.UPG           = (*Client)(nil)
+	_ processor.UPGAuthorizer = (*Client)(nil)
+	_ processor.Refunder      = (*Client)(nil)
+)
+
+// Client is the sandbox processor.
+type Client struct {
+	store Store
+}
+
+// NewClient creates a sandbox Client backed by store.
+func NewClient(store Store) *Client {
+	return &Client{store: store}
+}
+
+func (c *Client) Name() string {
+	return "sandbox"
+}
+
+// Capabilities returns every operation the sandbox simulates, including UPG.
+func (c *Client) Capabilities() processor.Capabilities {
+	return processor.Capabilities{
+		processor.CapabilityTokenize,
+		processor.CapabilityCardRead,
+		processor.CapabilityCardDelete,
+		processor.CapabilityRelay,
+		processor.CapabilitySessionToken,
+		processor.CapabilityCaptureForm,
+		processor.CapabilityUPGGateways,
+		processor.CapabilityUPGCharge,
+		processor.CapabilityUPGAuthorize,
+		processor.CapabilityUPGCredentials,
+		processor.CapabilityRefund,
+		processor.CapabilityThreeDS,
+	}
+}
+
+func (c *Client) CreateCard(ctx context.Context, card processor.Card) (*processor.CardResponse, error) {
+	number := strings.ReplaceAll(card.CardNumber, " ", "")
+	if !luhnValid(number) {
+		return nil, processor.NewError(processor.ErrInvalidCard, c.Name(), errors.New("sandbox: card number fails Luhn check"))
+	}
+	if card.ExpirationMonth == "" || card.ExpirationYear == "" {
+		return nil, processor.NewError(processor.ErrInvalidCard, c.Name(), errors.New("sandbox: expiration is required"))
+	}
+
+	outcome, ok := magicCards[number]
+	if !ok {
+		outcome = OutcomeApproved
+	}
+	cardType := card.CardType
+	if cardType == "" {
+		cardType = detectCardType(number)
+	}
+
+	stored := StoredCard{
+		Token:           "sbx_tok_" + randomHex(12),
+		Mask:            number[:6] + strings.Repeat("*", len(number)-10) + number[len(number)-4:],
+		CardType:        cardType,
+		CardholderName:  card.CardholderName,
+		ExpirationMonth: card.ExpirationMonth,
+		ExpirationYear:  card.ExpirationYear,
+		Outcome:         outcome,
+	}
+	if err := c.store.Put(ctx, stored); err != nil {
+		return nil, processor.NewError(processor.ErrUpstreamUnavailable, c.Name(), err)
+	}
+	return cardResponse(stored), nil
+}
+
+func (c *Client) GetCard(ctx context.Context, cardToken string) (*processor.CardResponse, error) {
+	stored, err := c.lookup(ctx, cardToken)
+	if err != nil {
+		return nil, err
+	}
+	return cardResponse(stored), nil
+}
+
+func (c *Client) DeleteCard(ctx context.Context, cardToken string) error {
+	ok, err := c.store.Delete(ctx, cardToken)
+	if err != nil {
+		return processor.NewError(processor.ErrUpstreamUnavailable, c.Name(), err)
+	}
+	if !ok {
+		return processor.NewError(processor.ErrNotFound, c.Name(), fmt.Errorf("sandbox: card %s not found", cardToken))
+	}
+	return nil
+}
+
+// sendResponseBody is the simulated gateway response returned by SendCard.
+type sendResponseBody struct {
+	ID          string  `json:"id"`
+	Status      Outcome `json:"status"`
+	DeclineCode string  `json:"decline_code,omitempty"`
+	Gateway     string  `json:"gateway"`
+}
+
+// SendCard simulates relaying the request to a gateway: the card's outcome
+// decides the response, approved and 3DS-required cards returning 200 and
+// declines returning 402.
+func (c *Client) SendCard(ctx context.Context, cardToken string, req processor.SendRequest) (*processor.SendResponse, error) {
+	stored, err := c.lookup(ctx, cardToken)
+	if err != nil {
+		return nil, err
+	}
+
+	gateway := req.URL
+	if u, err := url.Parse(req.URL); err == nil && u.Host != "" {
+		gateway = u.Host
+	}
+	body := sendResponseBody{
+		ID:      "sbx_txn_" + randomHex(12),
+		Status:  stored.Outcome,
+		Gateway: gateway,
+	}
+	status := 200
+	if declined(stored.Outcome) {
+		status = 402
+		body.DeclineCode = string(stored.Outcome)
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: marshal send response: %w", err)
+	}
+	return &processor.SendResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       b,
+	}, nil
+}
+
+func (c *Client) CreateSessionToken(_ context.Context, scope string) (*processor.SessionTokenResponse, error) {
+	return &processor.SessionTokenResponse{
+		Token: "sbx_st_" + randomHex(12),
+		Scope: scope,
+	}, nil
+}
+
+// CaptureFormURL returns a placeholder URL; the sandbox has no hosted form.
+func (c *Client) CaptureFormURL(sessionToken string) string {
+	params := url.Values{}
+	params.Set("session_token", sessionToken)
+	return "sandbox://capture_form?" + params.Encode()
+}
+
+func (c *Client) GetPaymentGateways(_ context.Context) ([]processor.GatewayInfo, error) {
+	infos := make([]processor.GatewayInfo, len(gateways))
+	for i, g := range gateways {
+		fields := make([]string, 0, len(g.structure))
+		for f := range g.structure {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		infos[i] = processor.GatewayInfo{Name: g.name, CredentialFields: fields}
+	}
+	return infos, nil
+}
+
+func (c *Client) GetCredentialsStructure(_ context.Context, gatewayName string) (map[string]any, error) {
+	for _, g := range gateways {
+		if strings.EqualFold(g.name, gatewayName) {
+			return g.structure, nil
+		}
+	}
+	return nil, processor.NewError(processor.ErrNotFound, c.Name(), fmt.Errorf("sandbox: unknown gateway %q", gatewayName))
+}
+
+// CreateUPGCredentials simulates registering gateway credentials for a known
+// gateway. The fields are not kept.
+func (c *Client) CreateUPGCredentials(ctx context.Context, gatewayName string, _ map[string]string) (string, error) {
+	if _, err := c.GetCredentialsStructure(ctx, gatewayName); err != nil {
+		return "", err
+	}
+	return "sbx_cred_" + randomHex(12), nil
+}
+
+// DeleteUPGCredentials simulates removing registered credentials; it
+// succeeds for credentials IDs issued by the sandbox.
+func (c *Client) DeleteUPGCredentials(_ context.Context, credentialsID string) error {
+	if !strings.HasPrefix(credentialsID, "sbx_cred_") {
+		return processor.NewError(processor.ErrNotFound, c.Name(), fmt.Errorf("sandbox: credentials %s not found", credentialsID))
+	}
+	return nil
+}
+
+// ChargeUPG simulates a UPG charge. Declined cards return Rejected and
+// 3DS-required cards return Accepted, pending authentication.
+func (c *Client) ChargeUPG(ctx context.Context, req processor.UPGChargeRequest) (*processor.UPGChargeResponse, error) {
+	return c.authorize(ctx, req, "approved")
+}
+
+// PreAuthorizeUPG simulates a UPG pre-authorization with the same outcomes
+// as ChargeUPG.
+func (c *Client) PreAuthorizeUPG(ctx context.Context, req processor.UPGChargeRequest) (*processor.UPGChargeResponse, error) {
+	return c.authorize(ctx, req, "authorized")
+}
+
+// CaptureUPG simulates capturing a sandbox pre-authorization; it always
+// succeeds for transaction IDs issued by the sandbox.
+func (c *Client) CaptureUPG(ctx context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	return c.followUp(ctx, req, "captured")
+}
+
+// VoidUPG simulates voiding a sandbox pre-authorization; it always succeeds
+// for transaction IDs issued by the sandbox.
+func (c *Client) VoidUPG(ctx context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	return c.followUp(ctx, req, "voided")
+}
+
+// RefundUPG simulates a refund; it always succeeds for transaction IDs issued
+// by the sandbox.
+func (c *Client) RefundUPG(ctx context.Context, req processor.UPGTransactionRequest) (*processor.UPGChargeResponse, error) {
+	return c.followUp(ctx, req, "refunded")
+}
+
+func (c *Client) authorize(ctx context.Context, req processor.UPGChargeRequest, approved string) (*processor.UPGChargeResponse, error) {
+	if _, err := c.GetCredentialsStructure(ctx, req.GatewayName); err != nil {
+		return nil, err
+	}
+	stored, err := c.lookup(ctx, req.CardToken)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &processor.UPGChargeResponse{TransactionID: "sbx_txn_" + randomHex(12)}
+	switch {
+	case stored.Outcome == OutcomeThreeDSRequired:
+		resp.Status = string(types.UPGStatusAccepted)
+		resp.Message = "3DS authentication required"
+	case declined(stored.Outcome):
+		resp.Status = string(types.UPGStatusRejected)
+		resp.Message = string(stored.Outcome)
+	default:
+		resp.Status = string(types.UPGStatusSuccess)
+		resp.Message = approved
+	}
+	resp.Raw, _ = json.Marshal(map[string]any{"sandbox": true, "outcome": stored.Outcome})
+	return resp, nil
+}
+
+func (c *Client) followUp(ctx context.Context, req processor.UPGTransactionRequest, message string) (*processor.UPGChargeResponse, error) {
+	if _, err := c.GetCredentialsStructure(ctx, req.GatewayName); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(req.TransactionID, "sbx_txn_") {
+		return nil, processor.NewError(processor.ErrNotFound, c.Name(), fmt.Errorf("sandbox: transaction %s not found", req.TransactionID))
+	}
+	resp := &processor.UPGChargeResponse{
+		Status:        string(types.UPGStatusSuccess),
+		TransactionID: "sbx_txn_" + randomHex(12),
+		Message:       message,
+	}
+	resp.Raw, _ = json.Marshal(map[string]any{"sandbox": true, "transaction_id": req.TransactionID})
+	return resp, nil
+}
+
+func (c *Client) lookup(ctx context.Context, cardToken string) (StoredCard, error) {
+	stored, ok, err := c.store.Get(ctx, cardToken)
+	if err != nil {
+		return StoredCard{}, processor.NewError(processor.ErrUpstreamUnavailable, c.Name(), err)
+	}
+	if !ok {
+		return StoredCard{}, processor.NewError(processor.ErrNotFound, c.Name(), fmt.Errorf("sandbox: card %s not found", cardToken))
+	}
+	return stored, nil
+}
+
+func cardResponse(s StoredCard) *processor.CardResponse {
+	return &processor.CardResponse{
+		CardToken:       s.Token,
+		CardMask:        s.Mask,
+		CardType:        s.CardType,
+		CardholderName:  s.CardholderName,
+		ExpirationMonth: s.ExpirationMonth,
+		ExpirationYear:  s.ExpirationYear,
+	}
+}
+
+func declined(o Outcome) bool {
+	return o == OutcomeDeclined || o == OutcomeInsufficientFunds || o == OutcomeExpiredCard
+}
+
+func luhnValid(number string) bool {
+	if len(number) < 12 || len(number) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func detectCardType(number string) string {
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case strings.HasPrefix(number, "5"):
+		return "mastercard"
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return "amex"
+	}
+	return "unknown"
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("sandbox: crypto/rand: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
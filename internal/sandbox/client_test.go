@@ -0,0 +1,229 @@
+package sandbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/money"
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/sandbox"
+	"github.com/CentraGlobal/backend-payment-go/internal/types"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func tokenize(t *testing.T, c *sandbox.Client, number string) string {
+	t.Helper()
+	card, err := c.CreateCard(context.Background(), processor.Card{
+		CardNumber:      number,
+		CardholderName:  "JOHN DOE",
+		ExpirationMonth: "12",
+		ExpirationYear:  "2030",
+	})
+	if err != nil {
+		t.Fatalf("CreateCard(%s): %v", number, err)
+	}
+	return card.CardToken
+}
+
+func TestCreateCard_MasksAndStores(t *testing.T) {
+	c := sandbox.NewClient(sandbox.NewMemoryStore())
+	token := tokenize(t, c, sandbox.CardApproved)
+
+	card, err := c.GetCard(context.Background(), token)
+	if err != nil {
+		t.Fatalf("GetCard: %v", err)
+	}
+	if card.CardMask != "424242******4242" {
+		t.Errorf("unexpected mask %q", card.CardMask)
+	}
+	if card.CardType != "visa" {
+		t.Errorf("expected visa, got %q", card.CardType)
+	}
+}
+
+func TestCreateCard_InvalidNumber(t *testing.T) {
+	c := sandbox.NewClient(sandbox.NewMemoryStore())
+
+	_, err := c.CreateCard(context.Background(), processor.Card{
+		CardNumber:      "4242424242424241",
+		ExpirationMonth: "12",
+		ExpirationYear:  "2030",
+	})
+	if !errors.Is(err, processor.ErrInvalidCard) {
+		t.Errorf("expected ErrInvalidCard, got %v", err)
+	}
+}
+
+func TestDeleteCard(t *testing.T) {
+	c := sandbox.NewClient(sandbox.NewMemoryStore())
+	token := tokenize(t, c, sandbox.CardApproved)
+
+	if err := c.DeleteCard(context.Background(), token); err != nil {
+		t.Fatalf("DeleteCard: %v", err)
+	}
+	if _, err := c.GetCard(context.Background(), token); !errors.Is(err, processor.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+	if err := c.DeleteCard(context.Background(), token); !errors.Is(err, processor.ErrNotFound) {
+		t.Errorf("expected ErrNotFound on second delete, got %v", err)
+	}
+}
+
+func TestSendCard_MagicNumbers(t *testing.T) {
+	tests := []struct {
+		number string
+		status int
+		want   sandbox.Outcome
+	}{
+		{sandbox.CardApproved, 200, sandbox.OutcomeApproved},
+		{"5555555555554444", 200, sandbox.OutcomeApproved},
+		{sandbox.CardDeclined, 402, sandbox.OutcomeDeclined},
+		{sandbox.CardInsufficientFunds, 402, sandbox.OutcomeInsufficientFunds},
+		{sandbox.CardExpired, 402, sandbox.OutcomeExpiredCard},
+		{sandbox.CardThreeDSRequired, 200, sandbox.OutcomeThreeDSRequired},
+	}
+
+	c := sandbox.NewClient(sandbox.NewMemoryStore())
+	for _, tc := range tests {
+		t.Run(string(tc.want)+"/"+tc.number, func(t *testing.T) {
+			token := tokenize(t, c, tc.number)
+			resp, err := c.SendCard(context.Background(), token, processor.SendRequest{
+				Method: "POST",
+				URL:    "https://api.stripe.com/v1/payment_intents",
+			})
+			if err != nil {
+				t.Fatalf("SendCard: %v", err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Errorf("expected status %d, got %d", tc.status, resp.StatusCode)
+			}
+			var body struct {
+				Status  sandbox.Outcome `json:"status"`
+				Gateway string          `json:"gateway"`
+			}
+			json.Unmarshal(resp.Body, &body)
+			if body.Status != tc.want {
+				t.Errorf("expected outcome %s, got %s", tc.want, body.Status)
+			}
+			if body.Gateway != "api.stripe.com" {
+				t.Errorf("expected gateway api.stripe.com, got %q", body.Gateway)
+			}
+		})
+	}
+}
+
+func TestChargeUPG(t *testing.T) {
+	if !sandbox.NewClient(sandbox.NewMemoryStore()).Capabilities().Has(processor.CapabilityThreeDS) {
+		t.Error("expected the sandbox to declare three_d_secure, since its 3DS card returns Accepted")
+	}
+
+	tests := []struct {
+		number string
+		want   types.UPGStatus
+	}{
+		{sandbox.CardApproved, types.UPGStatusSuccess},
+		{sandbox.CardDeclined, types.UPGStatusRejected},
+		{sandbox.CardThreeDSRequired, types.UPGStatusAccepted},
+	}
+
+	c := sandbox.NewClient(sandbox.NewMemoryStore())
+	for _, tc := range tests {
+		token := tokenize(t, c, tc.number)
+		resp, err := c.ChargeUPG(context.Background(), processor.UPGChargeRequest{
+			CardToken:   token,
+			Amount:      money.MustParse("10", "USD"),
+			GatewayName: "SandboxGateway",
+		})
+		if err != nil {
+			t.Fatalf("ChargeUPG(%s): %v", tc.number, err)
+		}
+		if resp.Status != string(tc.want) {
+			t.Errorf("%s: expected %s, got %s", tc.number, tc.want, resp.Status)
+		}
+	}
+}
+
+func TestChargeUPG_UnknownGateway(t *testing.T) {
+	c := sandbox.NewClient(sandbox.NewMemoryStore())
+	token := tokenize(t, c, sandbox.CardApproved)
+
+	_, err := c.ChargeUPG(context.Background(), processor.UPGChargeRequest{CardToken: token, GatewayName: "Nope"})
+	if !errors.Is(err, processor.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPreAuthorizeCaptureVoidRefund(t *testing.T) {
+	c := sandbox.NewClient(sandbox.NewMemoryStore())
+	ctx := context.Background()
+	token := tokenize(t, c, sandbox.CardApproved)
+
+	auth, err := c.PreAuthorizeUPG(ctx, processor.UPGChargeRequest{
+		CardToken:   token,
+		Amount:      money.MustParse("10", "USD"),
+		GatewayName: "SandboxGateway",
+	})
+	if err != nil || auth.Status != string(types.UPGStatusSuccess) {
+		t.Fatalf("PreAuthorizeUPG: %v %+v", err, auth)
+	}
+
+	req := processor.UPGTransactionRequest{TransactionID: auth.TransactionID, Amount: money.MustParse("4", "USD"), GatewayName: "SandboxGateway"}
+	if resp, err := c.CaptureUPG(ctx, req); err != nil || resp.Status != string(types.UPGStatusSuccess) {
+		t.Errorf("CaptureUPG: %v %+v", err, resp)
+	}
+	if resp, err := c.VoidUPG(ctx, req); err != nil || resp.Status != string(types.UPGStatusSuccess) {
+		t.Errorf("VoidUPG: %v %+v", err, resp)
+	}
+	if resp, err := c.RefundUPG(ctx, req); err != nil || resp.Status != string(types.UPGStatusSuccess) {
+		t.Errorf("RefundUPG: %v %+v", err, resp)
+	}
+
+	req.TransactionID = "txn_elsewhere"
+	if _, err := c.CaptureUPG(ctx, req); !errors.Is(err, processor.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for unknown transaction, got %v", err)
+	}
+}
+
+func TestGetPaymentGateways(t *testing.T) {
+	c := sandbox.NewClient(sandbox.NewMemoryStore())
+
+	gateways, err := c.GetPaymentGateways(context.Background())
+	if err != nil {
+		t.Fatalf("GetPaymentGateways: %v", err)
+	}
+	if len(gateways) == 0 {
+		t.Fatal("expected simulated gateways")
+	}
+	for _, g := range gateways {
+		if _, err := c.GetCredentialsStructure(context.Background(), g.Name); err != nil {
+			t.Errorf("GetCredentialsStructure(%s): %v", g.Name, err)
+		}
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	rdb := goredis.NewClient(&goredis.Options{Addr: "localhost:6379"})
+	defer rdb.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Skipf("redis not available: %v", err)
+	}
+
+	c := sandbox.NewClient(sandbox.NewRedisStore(rdb, time.Minute))
+	token := tokenize(t, c, sandbox.CardDeclined)
+
+	card, err := c.GetCard(context.Background(), token)
+	if err != nil {
+		t.Fatalf("GetCard: %v", err)
+	}
+	if card.CardToken != token {
+		t.Errorf("expected token %s, got %s", token, card.CardToken)
+	}
+	if err := c.DeleteCard(context.Background(), token); err != nil {
+		t.Fatalf("DeleteCard: %v", err)
+	}
+}
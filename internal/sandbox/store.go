@@ -0,0 +1,119 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// StoredCard is a tokenized sandbox card. Only the mask is kept; the full
+// number is never stored, only the outcome it selects.
+type StoredCard struct {
+	Token           string  `json:"token"`
+	Mask            string  `json:"mask"`
+	CardType        string  `json:"card_type"`
+	CardholderName  string  `json:"cardholder_name"`
+	ExpirationMonth string  `json:"expiration_month"`
+	ExpirationYear  string  `json:"expiration_year"`
+	Outcome         Outcome `json:"outcome"`
+}
+
+// Store persists sandbox card tokens.
+type Store interface {
+	Put(ctx context.Context, card StoredCard) error
+	// Get returns the card for token, or false when it does not exist.
+	Get(ctx context.Context, token string) (StoredCard, bool, error)
+	// Delete removes the card for token, returning false when it did not exist.
+	Delete(ctx context.Context, token string) (bool, error)
+}
+
+// MemoryStore is an in-process Store. Cards are lost on restart.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	cards map[string]StoredCard
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{cards: map[string]StoredCard{}}
+}
+
+func (m *MemoryStore) Put(_ context.Context, card StoredCard) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cards[card.Token] = card
+	return nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, token string) (StoredCard, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	card, ok := m.cards[token]
+	return card, ok, nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, token string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.cards[token]
+	delete(m.cards, token)
+	return ok, nil
+}
+
+// redisKeyPrefix namespaces sandbox cards in Redis.
+const redisKeyPrefix = "sandbox:card:"
+
+// RedisStore is a Store backed by Redis, shared across service instances.
+// Cards expire after ttl.
+type RedisStore struct {
+	rdb *goredis.Client
+	ttl time.Duration
+}
+
+var _ Store = (*RedisStore)(nil)
+
+// NewRedisStore creates a RedisStore. A zero ttl keeps cards indefinitely.
+func NewRedisStore(rdb *goredis.Client, ttl time.Duration) *RedisStore {
+	return &RedisStore{rdb: rdb, ttl: ttl}
+}
+
+func (r *RedisStore) Put(ctx context.Context, card StoredCard) error {
+	b, err := json.Marshal(card)
+	if err != nil {
+		return fmt.Errorf("sandbox: marshal card: %w", err)
+	}
+	if err := r.rdb.Set(ctx, redisKeyPrefix+card.Token, b, r.ttl).Err(); err != nil {
+		return fmt.Errorf("sandbox: store card: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisStore) Get(ctx context.Context, token string) (StoredCard, bool, error) {
+	b, err := r.rdb.Get(ctx, redisKeyPrefix+token).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return StoredCard{}, false, nil
+	}
+	if err != nil {
+		return StoredCard{}, false, fmt.Errorf("sandbox: load card: %w", err)
+	}
+	var card StoredCard
+	if err := json.Unmarshal(b, &card); err != nil {
+		return StoredCard{}, false, fmt.Errorf("sandbox: decode card: %w", err)
+	}
+	return card, true, nil
+}
+
+func (r *RedisStore) Delete(ctx context.Context, token string) (bool, error) {
+	n, err := r.rdb.Del(ctx, redisKeyPrefix+token).Result()
+	if err != nil {
+		return false, fmt.Errorf("sandbox: delete card: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,60 @@
+// Package tokenmirror stores which card tokens exist in more than one vault,
+// so that relay charges can fail over between vault processors.
+package tokenmirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var _ processor.TokenMirror = (*Store)(nil)
+
+// Store reads token mirrors from the card_token_mirrors table (see
+// migrations/0002_card_token_mirrors.sql).
+type Store struct {
+	pool *pgxpool.Pool
+}
+
+// NewStore creates a Store backed by the given pool.
+func NewStore(pool *pgxpool.Pool) *Store {
+	return &Store{pool: pool}
+}
+
+// MirrorToken returns the token for primaryToken's card in the secondary
+// vault, or an empty string when the card has not been mirrored.
+func (s *Store) MirrorToken(ctx context.Context, primary, secondary, primaryToken string) (string, error) {
+	var token string
+	err := s.pool.QueryRow(ctx,
+		`SELECT secondary_token FROM card_token_mirrors
+		 WHERE primary_processor = $1 AND secondary_processor = $2 AND primary_token = $3`,
+		primary, secondary, primaryToken,
+	).Scan(&token)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("tokenmirror: query mirror: %w", err)
+	}
+	return token, nil
+}
+
+// Put records that primaryToken in the primary vault and secondaryToken in the
+// secondary vault refer to the same card.
+func (s *Store) Put(ctx context.Context, primary, secondary, primaryToken, secondaryToken string) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO card_token_mirrors (primary_processor, secondary_processor, primary_token, secondary_token)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (primary_processor, secondary_processor, primary_token)
+		 DO UPDATE SET secondary_token = EXCLUDED.secondary_token`,
+		primary, secondary, primaryToken, secondaryToken,
+	)
+	if err != nil {
+		return fmt.Errorf("tokenmirror: upsert mirror: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package types
+
+import "encoding/json"
+
+// ChargeResult is the normalized outcome of a charge, returned the same way
+// whether the card was charged via UPG or via the vault relay.
+type ChargeResult struct {
+	// ID is the service's transaction ID, present when the charge was
+	// recorded in the transaction ledger.
+	ID            string    `json:"id,omitempty"`
+	Status        UPGStatus `json:"status"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	// Amount is a decimal in major units with the currency's exponent (e.g.
+	// 10.50, 1000 for JPY); AmountMinor is the same amount in minor units.
+	Amount      json.Number `json:"amount,omitempty"`
+	AmountMinor int64       `json:"amount_minor,omitempty"`
+	Currency    string      `json:"currency,omitempty"`
+	DeclineCode string      `json:"decline_code,omitempty"`
+	Message     string      `json:"message,omitempty"`
+	// Gateway is the payment gateway that processed the charge.
+	Gateway string `json:"gateway,omitempty"`
+	// Processor is the vault or processor that served the charge.
+	Processor string `json:"processor,omitempty"`
+	// Raw is the gateway or processor response, only returned on request and
+	// with card data and secrets redacted.
+	Raw json.RawMessage `json:"raw_response,omitempty"`
+}
@@ -13,6 +13,15 @@ const (
 	ReservationStatusCheckedOut ReservationStatus = "checked_out"
 )
 
+// ReservationPaymentStatus represents how much of a reservation has been paid.
+type ReservationPaymentStatus string
+
+const (
+	ReservationPaymentUnpaid        ReservationPaymentStatus = "unpaid"
+	ReservationPaymentPartiallyPaid ReservationPaymentStatus = "partially_paid"
+	ReservationPaymentPaid          ReservationPaymentStatus = "paid"
+)
+
 // Reservation represents a hotel reservation record.
 type Reservation struct {
 	ID                int               `db:"id"                 json:"id"`
@@ -26,9 +35,20 @@ type Reservation struct {
 	// CardToken is the Vaultera-issued reference token for the stored card.
 	// It is NOT raw card data and carries no PCI scope; however it is omitted
 	// from serialized output when empty.
-	CardToken   string    `db:"card_token"         json:"card_token,omitempty"`
-	TotalAmount float64   `db:"total_amount"       json:"total_amount"`
-	Currency    string    `db:"currency"           json:"currency"`
-	CreatedAt   time.Time `db:"created_at"         json:"created_at"`
-	UpdatedAt   time.Time `db:"updated_at"         json:"updated_at"`
+	CardToken   string `db:"card_token"         json:"card_token,omitempty"`
+	TotalAmount int64  `db:"total_amount"       json:"total_amount"` // minor units of Currency
+	Currency    string `db:"currency"           json:"currency"`
+	// AmountPaid is the sum of successful charges, and AmountHeld the sum of
+	// charges in progress, both in minor units of Currency.
+	AmountPaid    int64                    `db:"amount_paid"    json:"amount_paid"`
+	AmountHeld    int64                    `db:"amount_held"    json:"amount_held,omitempty"`
+	PaymentStatus ReservationPaymentStatus `db:"payment_status" json:"payment_status"`
+	CreatedAt     time.Time                `db:"created_at"     json:"created_at"`
+	UpdatedAt     time.Time                `db:"updated_at"     json:"updated_at"`
+}
+
+// Outstanding returns the part of TotalAmount that is neither paid nor held
+// by a charge in progress.
+func (r *Reservation) Outstanding() int64 {
+	return r.TotalAmount - r.AmountPaid - r.AmountHeld
 }
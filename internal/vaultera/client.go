@@ -15,7 +15,7 @@ import (
 	"github.com/CentraGlobal/backend-payment-go/internal/processor"
 )
 
-var _ processor.Processor = (*Client)(nil)
+var _ processor.Relayer = (*Client)(nil)
 
 type Client struct {
 	apiKey     string
@@ -37,6 +37,18 @@ func (c *Client) Name() string {
 	return "vaultera"
 }
 
+// Capabilities returns the operations supported by Vaultera. UPG is not offered.
+func (c *Client) Capabilities() processor.Capabilities {
+	return processor.Capabilities{
+		processor.CapabilityTokenize,
+		processor.CapabilityCardRead,
+		processor.CapabilityCardDelete,
+		processor.CapabilityRelay,
+		processor.CapabilitySessionToken,
+		processor.CapabilityCaptureForm,
+	}
+}
+
 func (c *Client) do(ctx context.Context, method, path string, queryParams url.Values, body any) ([]byte, int, error) {
 	endpoint := c.baseURL + path
 
@@ -69,17 +81,17 @@ func (c *Client) do(ctx context.Context, method, path string, queryParams url.Va
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, 0, fmt.Errorf("vaultera: http do: %w", err)
+		return nil, 0, processor.TransportError("vaultera", err)
 	}
 	defer resp.Body.Close()
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, resp.StatusCode, fmt.Errorf("vaultera: read response: %w", err)
+		return nil, resp.StatusCode, processor.NewError(processor.ErrUpstreamUnavailable, "vaultera", fmt.Errorf("vaultera: read response: %w", err))
 	}
 
 	if resp.StatusCode >= 400 {
-		return nil, resp.StatusCode, fmt.Errorf("vaultera: API error %d: %s", resp.StatusCode, string(data))
+		return nil, resp.StatusCode, processor.StatusError("vaultera", resp.StatusCode, data)
 	}
 
 	return data, resp.StatusCode, nil
@@ -156,7 +168,9 @@ func (c *Client) CreateCard(ctx context.Context, card processor.Card) (*processo
 	}
 	data, _, err := c.do(ctx, http.MethodPost, "/cards", nil, payload)
 	if err != nil {
-		return nil, err
+		// The request carries only the card, so a rejected request is a
+		// rejected card.
+		return nil, processor.CardRejected(err)
 	}
 	var wrapper cardResponseWrapper
 	if err := json.Unmarshal(data, &wrapper); err != nil {
@@ -245,21 +259,3 @@ func (c *Client) CaptureFormURL(sessionToken string) string {
 	params.Set("session_token", sessionToken)
 	return c.baseURL + "/capture_form?" + params.Encode()
 }
-
-// GetPaymentGateways is not supported by the vaultera provider.
-// UPG is only available via the pci_booking_upg provider.
-func (c *Client) GetPaymentGateways(_ context.Context) ([]processor.GatewayInfo, error) {
-	return nil, fmt.Errorf("vaultera: UPG is not supported; use the pci_booking_upg provider instead")
-}
-
-// GetCredentialsStructure is not supported by the vaultera provider.
-// UPG is only available via the pci_booking_upg provider.
-func (c *Client) GetCredentialsStructure(_ context.Context, _ string) (map[string]any, error) {
-	return nil, fmt.Errorf("vaultera: UPG is not supported; use the pci_booking_upg provider instead")
-}
-
-// ChargeUPG is not supported by the vaultera provider.
-// UPG is only available via the pci_booking_upg provider.
-func (c *Client) ChargeUPG(_ context.Context, _ processor.UPGChargeRequest) (*processor.UPGChargeResponse, error) {
-	return nil, fmt.Errorf("vaultera: UPG is not supported; use the pci_booking_upg provider instead")
-}
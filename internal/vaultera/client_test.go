@@ -3,6 +3,7 @@ package vaultera_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -195,4 +196,27 @@ func TestAPIError(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
+	if !errors.Is(err, processor.ErrAuthFailure) {
+		t.Errorf("expected ErrAuthFailure, got %v", err)
+	}
+}
+
+func TestUPG_Unsupported(t *testing.T) {
+	client := vaultera.NewClient("key", "https://example.com")
+
+	_, err := processor.As[processor.UPG](client, processor.CapabilityUPGCharge)
+	if !errors.Is(err, processor.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close() // nothing is listening any more
+
+	client := newTestClient(srv.URL)
+	_, err := client.GetCard(context.Background(), "tok")
+	if !errors.Is(err, processor.ErrUpstreamUnavailable) {
+		t.Errorf("expected ErrUpstreamUnavailable, got %v", err)
+	}
 }
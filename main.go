@@ -2,20 +2,39 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"log"
+	"net"
 	"strings"
 
+	"github.com/CentraGlobal/backend-payment-go/internal/apiclients"
 	"github.com/CentraGlobal/backend-payment-go/internal/config"
+	"github.com/CentraGlobal/backend-payment-go/internal/credentials"
 	"github.com/CentraGlobal/backend-payment-go/internal/db"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway/payzone"
+	"github.com/CentraGlobal/backend-payment-go/internal/gateway/stripe"
 	"github.com/CentraGlobal/backend-payment-go/internal/handlers"
+	"github.com/CentraGlobal/backend-payment-go/internal/idempotency"
 	"github.com/CentraGlobal/backend-payment-go/internal/infisical"
+	"github.com/CentraGlobal/backend-payment-go/internal/ledger"
 	"github.com/CentraGlobal/backend-payment-go/internal/middleware"
+	"github.com/CentraGlobal/backend-payment-go/internal/mtls"
+	"github.com/CentraGlobal/backend-payment-go/internal/nonce"
 	"github.com/CentraGlobal/backend-payment-go/internal/pcibooking"
 	"github.com/CentraGlobal/backend-payment-go/internal/processor"
+	"github.com/CentraGlobal/backend-payment-go/internal/ratelimit"
 	redisclient "github.com/CentraGlobal/backend-payment-go/internal/redis"
+	"github.com/CentraGlobal/backend-payment-go/internal/reservations"
+	"github.com/CentraGlobal/backend-payment-go/internal/resilience"
+	"github.com/CentraGlobal/backend-payment-go/internal/routing"
+	"github.com/CentraGlobal/backend-payment-go/internal/sandbox"
+	"github.com/CentraGlobal/backend-payment-go/internal/tokenmirror"
 	"github.com/CentraGlobal/backend-payment-go/internal/vaultera"
 	"github.com/gofiber/fiber/v2"
+	expvarmw "github.com/gofiber/fiber/v2/middleware/expvar"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/jackc/pgx/v5/pgxpool"
 	goredis "github.com/redis/go-redis/v9"
 )
@@ -33,8 +52,8 @@ func main() {
 	}
 
 	// Validate auth configuration.
-	if cfg.Auth.Require && cfg.Auth.SharedSecret == "" {
-		log.Fatalf("AUTH_SHARED_SECRET is required when AUTH_REQUIRE=true")
+	if cfg.Auth.Require && cfg.Auth.SharedSecret == "" && middleware.UsesSharedSecret(cfg.Auth.Mode) {
+		log.Fatalf("AUTH_SHARED_SECRET is required when AUTH_REQUIRE=true and AUTH_MODE=%s", cfg.Auth.Mode)
 	}
 	if cfg.App.Env != "development" && !cfg.Auth.Require {
 		log.Printf("warning: AUTH_REQUIRE=false in non-development environment")
@@ -61,64 +80,274 @@ func main() {
 		log.Printf("warning: failed to connect to Redis: %v", pingErr)
 	}
 
-	// Processor selection
-	var proc processor.Processor
+	// Processor selection. PROCESSOR_NAME picks the default processor, which
+	// must be fully configured. Any other provider with credentials set is also
+	// registered so properties can be routed to it via property_processors.
 	procName := strings.TrimSpace(strings.ToLower(cfg.Processor.Name))
-	switch procName {
-	case "pcibooking":
-		if cfg.PCIBooking.APIKey == "" {
-			log.Fatalf("PCIBOOKING_API_KEY (or cfg.PCIBooking.APIKey) must be set when PROCESSOR_NAME=pcibooking")
+	available, circuits := configuredProcessors(cfg, rdb)
+	defaultProc, ok := available[procName]
+	if !ok {
+		switch procName {
+		case "pcibooking", "pci_booking_upg":
+			if cfg.PCIBooking.APIKey == "" {
+				log.Fatalf("PCI_BOOKING_API_KEY must be set when PROCESSOR_NAME=%s", procName)
+			}
+			log.Fatalf("PCI_BOOKING_BASE_URL must be set when PROCESSOR_NAME=%s", procName)
+		case "sandbox":
+			log.Fatalf("PROCESSOR_NAME=sandbox is not allowed when APP_ENV=production")
+		case "vaultera":
+			if cfg.Vaultera.APIKey == "" {
+				log.Fatalf("VAULTERA_API_KEY (or cfg.Vaultera.APIKey) must be set when PROCESSOR_NAME=vaultera")
+			}
+			log.Fatalf("VAULTERA_BASE_URL (or cfg.Vaultera.BaseURL) must be set when PROCESSOR_NAME=vaultera")
+		default:
+			log.Fatalf("unknown processor: %s (supported: vaultera, pcibooking, pci_booking_upg, sandbox)", cfg.Processor.Name)
 		}
-		if cfg.PCIBooking.BaseURL == "" {
-			log.Fatalf("PCIBOOKING_BASE_URL (or cfg.PCIBooking.BaseURL) must be set when PROCESSOR_NAME=pcibooking")
+	}
+
+	var routes processor.RouteStore
+	var mirror processor.TokenMirror
+	var idempotencyFallback idempotency.Store
+	var paymentOpts []handlers.PaymentOption
+	var apiClients apiclients.Store
+	if dbPool != nil {
+		routes = routing.NewStore(dbPool)
+		apiClients = apiclients.NewPostgresStore(dbPool)
+		mirror = tokenmirror.NewStore(dbPool)
+		idempotencyFallback = idempotency.NewPostgresStore(dbPool)
+		paymentOpts = append(paymentOpts,
+			handlers.WithLedger(ledger.NewPostgresStore(dbPool)),
+			handlers.WithReservations(reservations.NewPostgresStore(dbPool)))
+	}
+
+	// Optional failover: every processor other than the secondary itself fails
+	// over to the secondary when it is unavailable.
+	if secondaryName := strings.TrimSpace(strings.ToLower(cfg.Processor.Secondary)); secondaryName != "" {
+		secondary, ok := available[secondaryName]
+		if !ok {
+			log.Fatalf("PROCESSOR_SECONDARY=%s is not configured (supported: vaultera, pcibooking, pci_booking_upg, sandbox)", secondaryName)
 		}
-		proc = pcibooking.NewClient(cfg.PCIBooking.APIKey, cfg.PCIBooking.BaseURL)
-	case "pci_booking_upg":
-		if cfg.PCIBooking.APIKey == "" {
-			log.Fatalf("PCI_BOOKING_API_KEY must be set when PROCESSOR_NAME=pci_booking_upg")
+		for name, p := range available {
+			if p != secondary {
+				available[name] = processor.NewFailover(p, secondary, mirror)
+			}
 		}
-		if cfg.PCIBooking.BaseURL == "" {
-			log.Fatalf("PCI_BOOKING_BASE_URL must be set when PROCESSOR_NAME=pci_booking_upg")
+		defaultProc = available[procName]
+		log.Printf("failover to secondary processor %s enabled", secondary.Name())
+	}
+	registry := processor.NewRegistry(procName, defaultProc, routes)
+	for name, p := range available {
+		if name != procName {
+			registry.Register(name, p)
 		}
-		proc = pcibooking.NewClient(cfg.PCIBooking.APIKey, cfg.PCIBooking.BaseURL)
-	case "vaultera":
-		if cfg.Vaultera.APIKey == "" {
-			log.Fatalf("VAULTERA_API_KEY (or cfg.Vaultera.APIKey) must be set when PROCESSOR_NAME=vaultera")
+	}
+	log.Printf("using default processor: %s (registered: %s)", defaultProc.Name(), strings.Join(registry.Names(), ", "))
+
+	// Stored BYOK gateway credentials need both the database and the master key.
+	var credentialService *credentials.Service
+	var keyRotator *credentials.Rotator
+	if cfg.Credentials.MasterKey != "" {
+		key, err := credentials.ParseMasterKey(cfg.Credentials.MasterKey)
+		if err != nil {
+			log.Fatalf("invalid CREDENTIALS_MASTER_KEY: %v", err)
 		}
-		if cfg.Vaultera.BaseURL == "" {
-			log.Fatalf("VAULTERA_BASE_URL (or cfg.Vaultera.BaseURL) must be set when PROCESSOR_NAME=vaultera")
+		keys := map[int][]byte{cfg.Credentials.MasterKeyVersion: key}
+		for version, encoded := range cfg.Credentials.PreviousKeys {
+			if version == cfg.Credentials.MasterKeyVersion {
+				log.Fatalf("invalid CREDENTIALS_PREVIOUS_KEYS: version %d is the current master key version", version)
+			}
+			if keys[version], err = credentials.ParseMasterKey(encoded); err != nil {
+				log.Fatalf("invalid CREDENTIALS_PREVIOUS_KEYS version %d: %v", version, err)
+			}
+		}
+		sealer, err := credentials.NewKeyRing(cfg.Credentials.MasterKeyVersion, keys)
+		if err != nil {
+			log.Fatalf("invalid credentials key ring: %v", err)
+		}
+		if dbPool != nil {
+			credentialService = credentials.NewService(credentials.NewPostgresStore(dbPool), sealer)
+			keyRotator = credentials.NewRotator(credentialService, cfg.Credentials.RotationBatchSize)
+			log.Printf("credentials key ring: current version %d (versions %v)", sealer.CurrentVersion(), sealer.Versions())
 		}
-		proc = vaultera.NewClient(cfg.Vaultera.APIKey, cfg.Vaultera.BaseURL)
-	default:
-		log.Fatalf("unknown processor: %s (supported: vaultera, pcibooking, pci_booking_upg)", cfg.Processor.Name)
 	}
-	log.Printf("using processor: %s", proc.Name())
+	if credentialService == nil {
+		log.Printf("warning: credential storage disabled (requires the database and CREDENTIALS_MASTER_KEY)")
+	}
 
 	// HTTP handlers
-	paymentHandler := handlers.NewPaymentHandler(proc)
+	gateways := gateway.NewRegistry(stripe.New(""), payzone.New(""))
+	paymentOpts = append(paymentOpts, handlers.WithGateways(gateways), handlers.WithCredentials(credentialService))
+	paymentHandler := handlers.NewPaymentHandler(registry, paymentOpts...)
+	credentialHandler := handlers.NewCredentialHandler(registry, credentialService,
+		credentials.NewSchemaCache(cfg.Credentials.SchemaTTL), gateways)
+	keyRotationHandler := handlers.NewKeyRotationHandler(keyRotator)
+	apiClientHandler := handlers.NewAPIClientHandler(apiClients)
+	idempotencyStore := idempotency.NewFallbackStore(idempotency.NewRedisStore(rdb), idempotencyFallback)
+	requireIdempotency := middleware.Idempotency(idempotencyStore, cfg.Idempotency)
+	// Rate limits are shared through Redis, and counted per instance while it
+	// is unreachable.
+	limiter := ratelimit.NewFallbackStore(ratelimit.NewRedisStore(rdb), ratelimit.NewMemoryStore())
+	limits := routeLimits{
+		tokenize: middleware.RateLimit(limiter, "tokenize", middleware.RateLimits{
+			Window:      cfg.RateLimit.Window,
+			PerCaller:   cfg.RateLimit.TokenizePerCaller,
+			PerProperty: cfg.RateLimit.TokenizePerProperty,
+		}),
+		charge: middleware.RateLimit(limiter, "charge", middleware.RateLimits{
+			Window:      cfg.RateLimit.Window,
+			PerCaller:   cfg.RateLimit.ChargePerCaller,
+			PerProperty: cfg.RateLimit.ChargePerProperty,
+			PerCard:     cfg.RateLimit.ChargePerCard,
+		}),
+	}
 
-	app := fiber.New()
-	app.Use(logger.New())
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Use(requestid.New())
+	app.Use(logger.New(logger.Config{
+		Format: "${time} | ${locals:requestid} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${locals:auth_secret} | ${error}\n",
+	}))
+	// expvar metrics, including auth_previous_secret_requests, at /debug/vars.
+	app.Use(expvarmw.New())
 
 	// Health
-	app.Get("/health", handlers.HealthHandler(dbPool, ariPool, rdb))
+	app.Get("/health", handlers.HealthHandler(dbPool, ariPool, rdb, circuits))
+
+	// All /v1 routes require shared secret, signed request, API key or client
+	// certificate auth (AUTH_MODE). API clients are further limited to the routes their scopes
+	// allow; shared secret and signed callers have every scope.
+	if cfg.Auth.Mode == middleware.AuthModeMTLS && !cfg.MTLS.Enabled {
+		log.Fatalf("AUTH_MODE=mtls requires MTLS_ENABLED=true")
+	}
+	auth, err := middleware.Authenticate(cfg.Auth, nonce.NewRedisStore(rdb), apiClients)
+	if err != nil {
+		log.Fatalf("invalid AUTH_MODE: %v", err)
+	}
+	v1 := app.Group("/v1", auth)
+
+	// Routes are served both unscoped (property taken from the X-Property-ID
+	// header, or the default processor) and scoped under /v1/properties/:propertyId.
+	registerPaymentRoutes(v1, paymentHandler, requireIdempotency, limits)
+	property := v1.Group("/properties/:propertyId")
+	registerPaymentRoutes(property, paymentHandler, requireIdempotency, limits)
+
+	// Gateway credentials are only served under a property scope.
+	creds := property.Group("/credentials", scope(apiclients.ScopeCredentialsManage))
+	creds.Post("/", credentialHandler.Create)
+	creds.Get("/", credentialHandler.List)
+	creds.Get("/:credId", credentialHandler.Get)
+	creds.Patch("/:credId", credentialHandler.Update)
+	creds.Delete("/:credId", credentialHandler.Delete)
+	creds.Post("/:credId/test", credentialHandler.Test)
 
-	// All /v1 routes require shared secret auth
-	v1 := app.Group("/v1", middleware.RequireSharedSecret(cfg.Auth))
-	v1.Get("/session", paymentHandler.GetSession)
+	// Reservations are only served under a property scope.
+	res := property.Group("/reservations")
+	res.Post("/", scope(apiclients.ScopeReservationsWrite), paymentHandler.CreateReservation)
+	res.Get("/:number", scope(apiclients.ScopeReservationsRead), paymentHandler.GetReservation)
+	res.Post("/:number/charge", scope(apiclients.ScopeChargeCreate), paymentHandler.ReservationCard, limits.charge,
+		requireIdempotency, paymentHandler.ChargeReservation)
+	res.Post("/:number/reconcile", scope(apiclients.ScopeReservationsWrite), paymentHandler.ReconcileReservation)
+
+	admin := v1.Group("/admin", scope(apiclients.ScopeAdmin))
+	// Master key rotation of stored credentials.
+	admin.Post("/credentials/key-rotation", keyRotationHandler.Start)
+	admin.Get("/credentials/key-rotation", keyRotationHandler.Status)
+	// API clients and their keys.
+	admin.Post("/clients", apiClientHandler.Create)
+	admin.Get("/clients", apiClientHandler.List)
+	admin.Delete("/clients/:clientId", apiClientHandler.Revoke)
+
+	if !cfg.MTLS.Enabled {
+		log.Fatal(app.Listen(":" + cfg.App.Port))
+	}
+
+	// Mutual TLS: every connection must present a client certificate signed by
+	// MTLS_CLIENT_CA_FILE. Certificates are reloaded from disk when they change.
+	certs, err := mtls.NewReloader(cfg.MTLS)
+	if err != nil {
+		log.Fatalf("invalid mTLS configuration: %v", err)
+	}
+	go certs.Watch(ctx)
+	ln, err := net.Listen("tcp", ":"+cfg.App.Port)
+	if err != nil {
+		log.Fatalf("listen: %v", err)
+	}
+	log.Printf("serving mutual TLS on :%s", cfg.App.Port)
+	log.Fatal(app.Listener(tls.NewListener(ln, certs.TLSConfig())))
+}
+
+// scope is shorthand for middleware.RequireScope.
+var scope = middleware.RequireScope
+
+// routeLimits are the rate limiting middlewares of the routes that reach a
+// card: tokenizing one, and charging or authorizing one.
+type routeLimits struct {
+	tokenize fiber.Handler
+	charge   fiber.Handler
+}
+
+// registerPaymentRoutes mounts the session, capabilities, payment and UPG routes
+// on r, each behind the scope it requires. idempotent deduplicates charges by
+// Idempotency-Key; it runs after the scope check and rate limit so that refused
+// requests do not lock their key.
+func registerPaymentRoutes(r fiber.Router, h *handlers.PaymentHandler, idempotent fiber.Handler, limits routeLimits) {
+	r.Get("/session", scope(apiclients.ScopeSessionCreate), h.GetSession)
+	r.Get("/capabilities", h.GetCapabilities)
 
 	// Payment routes
-	payments := v1.Group("/payments")
-	payments.Post("/tokenize", paymentHandler.Tokenize)
-	payments.Post("/charge", paymentHandler.Charge)
-	payments.Get("/cards/:token", paymentHandler.GetCard)
-	payments.Delete("/cards/:token", paymentHandler.DeleteCard)
-
-	// UPG-only gateway metadata routes. These endpoints are only functional when the service is
-	// configured with the pci_booking_upg processor. All other processors return 503 UPG_NOT_AVAILABLE.
-	gateways := v1.Group("/upg/gateways")
-	gateways.Get("/", paymentHandler.GetGateways)
-	gateways.Get("/:name/structure", paymentHandler.GetGatewayStructure)
-
-	log.Fatal(app.Listen(":" + cfg.App.Port))
+	payments := r.Group("/payments")
+	payments.Post("/tokenize", scope(apiclients.ScopeCardsCreate), limits.tokenize, h.Tokenize)
+	payments.Post("/charge", scope(apiclients.ScopeChargeCreate), limits.charge, idempotent, h.Charge)
+	payments.Post("/authorize", scope(apiclients.ScopeChargeCreate), limits.charge, idempotent, h.Authorize)
+	payments.Post("/:id/capture", scope(apiclients.ScopeChargeCreate), idempotent, h.Capture)
+	payments.Post("/:id/void", scope(apiclients.ScopeChargeCreate), idempotent, h.Void)
+	payments.Post("/:id/refunds", scope(apiclients.ScopeRefundCreate), idempotent, h.Refund)
+	payments.Get("/cards/:token", scope(apiclients.ScopeCardsRead), h.GetCard)
+	payments.Delete("/cards/:token", scope(apiclients.ScopeCardsDelete), h.DeleteCard)
+	payments.Get("/transactions", scope(apiclients.ScopeTransactionsRead), h.ListTransactions)
+	payments.Get("/transactions/:id", scope(apiclients.ScopeTransactionsRead), h.GetTransaction)
+
+	// UPG-only gateway metadata routes. These endpoints are only functional when the
+	// resolved processor supports UPG. All other processors return 501 UNSUPPORTED_OPERATION.
+	gateways := r.Group("/upg/gateways", scope(apiclients.ScopeUPGRead))
+	gateways.Get("/", h.GetGateways)
+	gateways.Get("/:name/structure", h.GetGatewayStructure)
+}
+
+// configuredProcessors builds every processor whose credentials are present in
+// cfg, keyed by the name used in PROCESSOR_NAME and the routing table. The
+// sandbox processor, which approves fake charges, is only built when
+// explicitly selected (see sandboxSelected) and never in production. Each
+// provider client is wrapped once with timeouts, retries and a circuit breaker.
+func configuredProcessors(cfg *config.Config, rdb *goredis.Client) (map[string]processor.Processor, resilience.Circuits) {
+	procs := map[string]processor.Processor{}
+	var circuits resilience.Circuits
+	if cfg.Vaultera.APIKey != "" && cfg.Vaultera.BaseURL != "" {
+		client := resilience.Wrap(vaultera.NewClient(cfg.Vaultera.APIKey, cfg.Vaultera.BaseURL), cfg.Resilience)
+		procs["vaultera"] = client
+		circuits = append(circuits, client)
+	}
+	if cfg.PCIBooking.APIKey != "" && cfg.PCIBooking.BaseURL != "" {
+		client := resilience.Wrap(pcibooking.NewClient(cfg.PCIBooking.APIKey, cfg.PCIBooking.BaseURL), cfg.Resilience)
+		procs["pcibooking"] = client
+		procs["pci_booking_upg"] = client
+		circuits = append(circuits, client)
+	}
+	if sandboxSelected(cfg) && cfg.App.Env != "production" {
+		var store sandbox.Store = sandbox.NewMemoryStore()
+		if strings.EqualFold(cfg.Sandbox.Store, "redis") {
+			store = sandbox.NewRedisStore(rdb, cfg.Sandbox.TokenTTL)
+		}
+		client := resilience.Wrap(sandbox.NewClient(store), cfg.Resilience)
+		procs["sandbox"] = client
+		circuits = append(circuits, client)
+	}
+	return procs, circuits
+}
+
+// sandboxSelected reports whether the sandbox processor was asked for by
+// SANDBOX_ENABLED, PROCESSOR_NAME or PROCESSOR_SECONDARY.
+func sandboxSelected(cfg *config.Config) bool {
+	return cfg.Sandbox.Enabled ||
+		strings.EqualFold(strings.TrimSpace(cfg.Processor.Name), "sandbox") ||
+		strings.EqualFold(strings.TrimSpace(cfg.Processor.Secondary), "sandbox")
 }
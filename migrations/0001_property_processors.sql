@@ -0,0 +1,8 @@
+-- Per-property processor routing. Properties without a row use the service
+-- default processor (PROCESSOR_NAME).
+CREATE TABLE IF NOT EXISTS property_processors (
+    property_id    BIGINT      PRIMARY KEY,
+    processor_name TEXT        NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
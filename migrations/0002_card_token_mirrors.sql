@@ -0,0 +1,10 @@
+-- Card tokens known to exist in two vaults. Used by the failover processor to
+-- retry relay charges on the secondary vault (PROCESSOR_SECONDARY).
+CREATE TABLE IF NOT EXISTS card_token_mirrors (
+    primary_processor   TEXT        NOT NULL,
+    secondary_processor TEXT        NOT NULL,
+    primary_token       TEXT        NOT NULL,
+    secondary_token     TEXT        NOT NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (primary_processor, secondary_processor, primary_token)
+);
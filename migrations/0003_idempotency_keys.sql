@@ -0,0 +1,14 @@
+-- Idempotency-Key records for POST /v1/payments/charge, used when Redis is
+-- unavailable. Expired rows are taken over by new requests and may be purged.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key          TEXT        PRIMARY KEY,
+    request_hash TEXT        NOT NULL,
+    state        TEXT        NOT NULL,
+    status_code  INTEGER,
+    content_type TEXT,
+    body         BYTEA,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,24 @@
+-- Ledger of every charge attempt made through the service. Rows are inserted
+-- as Pending before the processor is called and updated with the outcome.
+CREATE TABLE IF NOT EXISTS transactions (
+    id                 UUID        PRIMARY KEY,
+    property_id        BIGINT,
+    mode               TEXT        NOT NULL,
+    processor          TEXT        NOT NULL,
+    gateway            TEXT        NOT NULL DEFAULT '',
+    amount_minor       BIGINT      NOT NULL DEFAULT 0,
+    currency           TEXT        NOT NULL DEFAULT '',
+    status             TEXT        NOT NULL,
+    transaction_id     TEXT        NOT NULL DEFAULT '',
+    decline_code       TEXT        NOT NULL DEFAULT '',
+    message            TEXT        NOT NULL DEFAULT '',
+    card_token         TEXT        NOT NULL,
+    reservation_number TEXT        NOT NULL DEFAULT '',
+    request_id         TEXT        NOT NULL DEFAULT '',
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS transactions_property_created_idx ON transactions (property_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS transactions_reservation_idx ON transactions (reservation_number) WHERE reservation_number <> '';
+CREATE INDEX IF NOT EXISTS transactions_card_token_idx ON transactions (card_token);
@@ -0,0 +1,7 @@
+-- Pre-authorizations and the captures and voids that follow them. Captures
+-- and voids reference the authorization through parent_id.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS operation      TEXT NOT NULL DEFAULT 'charge';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS parent_id      UUID REFERENCES transactions (id);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS credentials_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS transactions_parent_idx ON transactions (parent_id) WHERE parent_id IS NOT NULL;
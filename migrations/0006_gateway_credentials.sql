@@ -0,0 +1,16 @@
+-- Hotels' own (BYOK) gateway credentials. Secrets are stored only as
+-- ciphertext under a per-row data key, which is itself stored wrapped by the
+-- master key (CREDENTIALS_MASTER_KEY). fields lists the secret field names.
+CREATE TABLE IF NOT EXISTS gateway_credentials (
+    id          UUID        PRIMARY KEY,
+    property_id BIGINT      NOT NULL,
+    gateway     TEXT        NOT NULL,
+    label       TEXT        NOT NULL DEFAULT '',
+    fields      TEXT[]      NOT NULL DEFAULT '{}',
+    ciphertext  BYTEA       NOT NULL,
+    wrapped_key BYTEA       NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS gateway_credentials_property_idx ON gateway_credentials (property_id, created_at DESC);
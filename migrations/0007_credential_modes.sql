@@ -0,0 +1,4 @@
+-- Whether stored credentials are for a UPG gateway or a relay gateway
+-- adapter. UPG credentials are validated against the gateway's credential
+-- structure when saved.
+ALTER TABLE gateway_credentials ADD COLUMN IF NOT EXISTS mode TEXT NOT NULL DEFAULT 'upg';
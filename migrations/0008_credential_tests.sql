@@ -0,0 +1,4 @@
+-- Outcome of the most recent connection test of each stored credential.
+ALTER TABLE gateway_credentials ADD COLUMN IF NOT EXISTS last_tested_at   TIMESTAMPTZ;
+ALTER TABLE gateway_credentials ADD COLUMN IF NOT EXISTS last_test_passed BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE gateway_credentials ADD COLUMN IF NOT EXISTS last_test_reason TEXT    NOT NULL DEFAULT '';
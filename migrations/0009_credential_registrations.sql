@@ -0,0 +1,6 @@
+-- Processor registration of UPG credentials: the credentials ID under which
+-- the processor holds a copy of the secrets, for charging by property and
+-- gateway alone.
+ALTER TABLE gateway_credentials ADD COLUMN IF NOT EXISTS upg_processor      TEXT NOT NULL DEFAULT '';
+ALTER TABLE gateway_credentials ADD COLUMN IF NOT EXISTS upg_credentials_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE gateway_credentials ADD COLUMN IF NOT EXISTS upg_registered_at  TIMESTAMPTZ;
@@ -0,0 +1,6 @@
+-- Version of the master key wrapping each record's data key, so the master
+-- key can be rotated by re-wrapping data keys in the background. Existing
+-- records were wrapped by the only key there was, version 1.
+ALTER TABLE gateway_credentials ADD COLUMN IF NOT EXISTS key_version INTEGER NOT NULL DEFAULT 1;
+
+CREATE INDEX IF NOT EXISTS gateway_credentials_key_version_idx ON gateway_credentials (key_version, id);
@@ -0,0 +1,23 @@
+-- Reservations that charges can be made against by reservation number.
+-- amount_paid and amount_held (charges in progress) are in minor units of
+-- currency and never exceed total_amount between them.
+CREATE TABLE IF NOT EXISTS reservations (
+    id                 BIGSERIAL   PRIMARY KEY,
+    reservation_number TEXT        NOT NULL,
+    property_id        BIGINT      NOT NULL,
+    guest_name         TEXT        NOT NULL DEFAULT '',
+    guest_email        TEXT        NOT NULL DEFAULT '',
+    check_in           DATE        NOT NULL,
+    check_out          DATE        NOT NULL,
+    status             TEXT        NOT NULL,
+    card_token         TEXT        NOT NULL DEFAULT '',
+    total_amount       BIGINT      NOT NULL CHECK (total_amount >= 0),
+    currency           TEXT        NOT NULL,
+    amount_paid        BIGINT      NOT NULL DEFAULT 0,
+    amount_held        BIGINT      NOT NULL DEFAULT 0,
+    payment_status     TEXT        NOT NULL DEFAULT 'unpaid',
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (property_id, reservation_number),
+    CHECK (amount_paid + amount_held <= total_amount)
+);
@@ -0,0 +1,11 @@
+-- Named API clients calling /v1 with their own key (AUTH_MODE=api_key). Only
+-- the SHA-256 of each key is stored; key_prefix identifies it to operators.
+CREATE TABLE IF NOT EXISTS api_clients (
+    id         UUID        PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    key_prefix TEXT        NOT NULL,
+    key_hash   TEXT        NOT NULL UNIQUE,
+    scopes     TEXT[]      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
@@ -0,0 +1,2 @@
+-- Client certificates on the mTLS listener are matched to API clients by name.
+CREATE INDEX IF NOT EXISTS api_clients_name_idx ON api_clients (name, created_at DESC) WHERE revoked_at IS NULL;
@@ -0,0 +1,5 @@
+-- Client certificates on the mTLS listener name their API client by common
+-- name, so no two unrevoked clients may share a name. Creating the index fails
+-- while duplicates exist; revoke or rename them first.
+CREATE UNIQUE INDEX IF NOT EXISTS api_clients_active_name_key ON api_clients (name) WHERE revoked_at IS NULL;
+DROP INDEX IF EXISTS api_clients_name_idx;
@@ -0,0 +1,11 @@
+-- Holds on reservations by charges whose outcome is unknown (a timeout or 5xx
+-- after the charge was sent), keyed by the charge's ledger transaction. Their
+-- amounts stay in reservations.amount_held until the charge is reconciled.
+CREATE TABLE IF NOT EXISTS reservation_holds (
+    transaction_id     UUID        PRIMARY KEY REFERENCES transactions (id),
+    property_id        BIGINT      NOT NULL,
+    reservation_number TEXT        NOT NULL,
+    amount             BIGINT      NOT NULL CHECK (amount > 0),
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    FOREIGN KEY (property_id, reservation_number) REFERENCES reservations (property_id, reservation_number)
+);